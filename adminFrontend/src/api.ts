@@ -83,10 +83,21 @@ export const checkHealth = async () => {
 	return response.data;
 };
 
+// Paginated listings return at most 100 items a page; read every page
+const getAllPages = async (url: string) => {
+	const items: any[] = [];
+	for (let page = 1; ; page++) {
+		const response = await axios.get(url, { params: { page, limit: 100 } });
+		items.push(...response.data.data);
+		if (page >= response.data.totalPages) {
+			return items;
+		}
+	}
+};
+
 // Questions APIs
 export const getQuestions = async () => {
-	const response = await axios.get(`${API_URL}/questions`);
-	return response.data;
+	return getAllPages(`${API_URL}/questions`);
 };
 
 export const createQuestion = async (questionData: any) => {
@@ -147,8 +158,7 @@ export const getStudentResultsByChallenge = async (challengeId: string) => {
 
 // Coding Challenges APIs
 export const getChallenges = async () => {
-	const response = await axios.get(`${API_URL}/challenges`);
-	return response.data;
+	return getAllPages(`${API_URL}/challenges`);
 };
 
 export const getChallenge = async (id: string) => {
@@ -33,6 +33,20 @@ export const checkHealth = async () => {
 	}
 };
 
+// Paginated listings return at most 100 items a page; read every page
+const getAllPages = async (url: string, params?: Record<string, unknown>) => {
+	const items: any[] = [];
+	for (let page = 1; ; page++) {
+		const response = await api.get(url, {
+			params: { ...params, page, limit: 100 },
+		});
+		items.push(...response.data.data);
+		if (page >= response.data.totalPages) {
+			return items;
+		}
+	}
+};
+
 // Questions API
 export const createQuestion = async (data: any) => {
 	try {
@@ -47,8 +61,7 @@ export const createQuestion = async (data: any) => {
 
 export const getQuestions = async () => {
 	try {
-		const response = await api.get("/questions");
-		return response.data;
+		return await getAllPages("/questions");
 	} catch (error) {
 		console.error("Failed to fetch questions:", error);
 		throw error;
@@ -109,8 +122,7 @@ export const getChallenges = async (params?: {
 	category?: string;
 }) => {
 	try {
-		const response = await api.get("/challenges", { params });
-		return response.data;
+		return await getAllPages("/challenges", params);
 	} catch (error) {
 		console.error("Failed to fetch challenges:", error);
 		toast.error("Failed to load challenges. Using empty data.");
@@ -5,6 +5,7 @@ import (
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -19,6 +20,13 @@ var (
 	ChallengeAttemptsCollection *mongo.Collection
 	StudentsCollection          *mongo.Collection
 	SessionsCollection          *mongo.Collection
+	LeaderboardsCollection      *mongo.Collection
+	TestProgressCollection      *mongo.Collection
+	RevokedTokensCollection     *mongo.Collection
+	OAuthStatesCollection       *mongo.Collection
+	PointAwardsCollection       *mongo.Collection
+	AttemptProgressCollection   *mongo.Collection
+	SubmitCooldownsCollection   *mongo.Collection
 )
 
 // Connect establishes a connection to MongoDB
@@ -70,4 +78,181 @@ func InitDB(database *mongo.Database) {
 	ChallengesCollection = database.Collection("coding_challenges")
 	ChallengeAttemptsCollection = database.Collection("challenge_attempts")
 	StudentsCollection = database.Collection("students")
+	SessionsCollection = database.Collection("sessions")
+	LeaderboardsCollection = database.Collection("challenge_leaderboards")
+	TestProgressCollection = database.Collection("test_progress")
+	RevokedTokensCollection = database.Collection("revoked_tokens")
+	OAuthStatesCollection = database.Collection("oauth_states")
+	PointAwardsCollection = database.Collection("point_awards")
+	AttemptProgressCollection = database.Collection("attempt_progress")
+	SubmitCooldownsCollection = database.Collection("submission_cooldowns")
+
+	createIndexes()
+}
+
+// createIndexes ensures the indexes the handlers rely on exist.
+// Index creation is idempotent, so this is safe to run on every startup.
+func createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// Unique emails make concurrent first-time OAuth logins safe
+	_, err := UsersCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "email", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create unique email index on users: %v", err)
+	}
+
+	// Student emails must be unique. Partial so students without an email don't collide.
+	_, err = StudentsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "basicInfo.email", Value: 1}},
+		Options: options.Index().SetUnique(true).
+			SetPartialFilterExpression(bson.M{"basicInfo.email": bson.M{"$gt": ""}}),
+	})
+	if err != nil {
+		log.Printf("Failed to create unique email index on students (existing duplicates must be removed first): %v", err)
+	}
+
+	// Attempt reference codes are looked up directly and must never repeat.
+	// Sparse so submissions made before reference codes existed don't collide.
+	_, err = AttemptCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "referenceCode", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create unique reference code index on attempts: %v", err)
+	}
+
+	// Refresh tokens are looked up by hash; expired sessions are purged by Mongo
+	_, err = SessionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "refreshHash", Value: 1}},
+		Options: options.Index().SetUnique(true).SetSparse(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create refresh hash index on sessions: %v", err)
+	}
+	_, err = SessionsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create expiry index on sessions: %v", err)
+	}
+
+	// A student has a single in-progress record per sequential test
+	_, err = TestProgressCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "testId", Value: 1}, {Key: "studentId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create unique test/student index on test_progress: %v", err)
+	}
+
+	// Saved answers are kept as a single record per student and free-mode test
+	_, err = AttemptProgressCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "testId", Value: 1}, {Key: "studentId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create unique test/student index on attempt_progress: %v", err)
+	}
+
+	// Challenge search (?q=) uses $text; without this index it falls back to a slower regex scan
+	_, err = ChallengesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "title", Value: "text"}, {Key: "description", Value: "text"}},
+		Options: options.Index().SetName("challenge_text").SetWeights(bson.D{{Key: "title", Value: 3}, {Key: "description", Value: 1}}),
+	})
+	if err != nil {
+		log.Printf("Failed to create text index on coding_challenges: %v", err)
+	}
+
+	// The question bank is filtered by tag, category and difficulty
+	_, err = QuestionsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "tags", Value: 1}}},
+		{Keys: bson.D{{Key: "category", Value: 1}}},
+		{Keys: bson.D{{Key: "difficulty", Value: 1}, {Key: "type", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create tag/category/difficulty indexes on questions: %v", err)
+	}
+
+	// Points for a challenge are awarded once per student
+	_, err = PointAwardsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "userId", Value: 1}, {Key: "challengeId", Value: 1}},
+		Options: options.Index().SetUnique(true),
+	})
+	if err != nil {
+		log.Printf("Failed to create unique user/challenge index on point_awards: %v", err)
+	}
+
+	// A submission cooldown is claimed by upserting the single record per submitter
+	// and challenge; the unique index turns a concurrent claim into a duplicate key
+	// error. Records are only needed until the cooldown has passed.
+	_, err = SubmitCooldownsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{
+			Keys:    bson.D{{Key: "key", Value: 1}, {Key: "challengeId", Value: 1}},
+			Options: options.Index().SetUnique(true),
+		},
+		{
+			Keys:    bson.D{{Key: "nextAllowedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(0),
+		},
+	})
+	if err != nil {
+		log.Printf("Failed to create indexes on submission_cooldowns: %v", err)
+	}
+
+	// The global leaderboard sorts students by points
+	_, err = StudentsCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys: bson.D{{Key: "basicInfo.points", Value: -1}},
+	})
+	if err != nil {
+		log.Printf("Failed to create points index on students: %v", err)
+	}
+
+	// Revoked JWTs only need remembering until they expire
+	_, err = RevokedTokensCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create expiry index on revoked_tokens: %v", err)
+	}
+
+	// Unused OAuth states are purged once they expire
+	_, err = OAuthStatesCollection.Indexes().CreateOne(ctx, mongo.IndexModel{
+		Keys:    bson.D{{Key: "expiresAt", Value: 1}},
+		Options: options.Index().SetExpireAfterSeconds(0),
+	})
+	if err != nil {
+		log.Printf("Failed to create expiry index on oauth_states: %v", err)
+	}
+
+	// Results are listed per student or per challenge/test, newest first; the
+	// time field lets those listings sort from the index too
+	_, err = ChallengeAttemptsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "userId", Value: 1}, {Key: "createdAt", Value: -1}}},
+		{Keys: bson.D{{Key: "challengeId", Value: 1}, {Key: "createdAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create user/challenge indexes on challenge_attempts: %v", err)
+	}
+	_, err = AttemptCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "testId", Value: 1}, {Key: "submittedAt", Value: -1}}},
+		{Keys: bson.D{{Key: "studentId", Value: 1}, {Key: "submittedAt", Value: -1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create test/student indexes on attempts: %v", err)
+	}
+
+	// Active, scheduled and started tests are found by their window
+	_, err = TestsCollection.Indexes().CreateMany(ctx, []mongo.IndexModel{
+		{Keys: bson.D{{Key: "startTime", Value: 1}}},
+		{Keys: bson.D{{Key: "endTime", Value: 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to create window indexes on tests: %v", err)
+	}
 }
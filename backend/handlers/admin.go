@@ -28,6 +28,9 @@ type StudentResultResponse struct {
 	TotalPoints     float64 `json:"totalPoints"`
 	TimeSpent       int     `json:"timeSpent"` // in seconds
 	SubmittedAt     string  `json:"submittedAt"`
+	// Set when the code closely matches the reference solution; flagged for review only
+	MatchesSolution    bool    `json:"matchesSolution"`
+	SolutionSimilarity float64 `json:"solutionSimilarity,omitempty"`
 }
 
 // Get student name and email from the Student model
@@ -35,13 +38,19 @@ func getStudentInfo(student models.Student) (string, string) {
 	return student.BasicInfo.Name, student.BasicInfo.Email
 }
 
-// GetAllStudentResults retrieves all student challenge attempt results with student and challenge details
+// GetAllStudentResults retrieves all student challenge attempt results with
+// student and challenge details, optionally only those made between ?from= and ?to=
 func GetAllStudentResults(c *fiber.Ctx) error {
+	window, err := parseTimeWindow(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
 	// First get all challenge attempts
 	var attempts []models.ChallengeAttempt
 	cursor, err := db.ChallengeAttemptsCollection.Find(
 		context.Background(),
-		bson.M{},
+		window.apply(bson.M{}, "createdAt"),
 		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
 	)
 
@@ -111,17 +120,19 @@ func GetAllStudentResults(c *fiber.Ctx) error {
 		// Map the data to our response format
 		studentName, studentEmail := getStudentInfo(student)
 		result := StudentResultResponse{
-			StudentID:       studentID,
-			StudentName:     studentName,
-			StudentEmail:    studentEmail,
-			ChallengeID:     challengeID,
-			ChallengeTitle:  challenge.Title,
-			Status:          attempt.Status,
-			PercentageScore: attempt.Result.PercentageScore,
-			PointsScored:    attempt.Result.ScoredPoints,
-			TotalPoints:     attempt.Result.TotalPoints,
-			TimeSpent:       attempt.TimeSpent,
-			SubmittedAt:     attempt.CreatedAt.Format(time.RFC3339),
+			StudentID:          studentID,
+			StudentName:        studentName,
+			StudentEmail:       studentEmail,
+			ChallengeID:        challengeID,
+			ChallengeTitle:     challenge.Title,
+			Status:             attempt.Status,
+			PercentageScore:    roundPercent(attempt.Result.PercentageScore),
+			PointsScored:       roundPoints(attempt.Result.ScoredPoints),
+			TotalPoints:        roundPoints(attempt.Result.TotalPoints),
+			TimeSpent:          attempt.TimeSpent,
+			SubmittedAt:        attempt.CreatedAt.Format(time.RFC3339),
+			MatchesSolution:    attempt.MatchesSolution,
+			SolutionSimilarity: attempt.SolutionSimilarity,
 		}
 
 		results = append(results, result)
@@ -136,18 +147,23 @@ func GetAllStudentResults(c *fiber.Ctx) error {
 	return c.JSON(results)
 }
 
-// GetStudentResultsByStudent retrieves all results for a specific student
+// GetStudentResultsByStudent retrieves all results for a specific student,
+// optionally only those made between ?from= and ?to=
 func GetStudentResultsByStudent(c *fiber.Ctx) error {
 	studentID, err := primitive.ObjectIDFromHex(c.Params("studentId"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid student ID"})
 	}
+	window, err := parseTimeWindow(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	// First get all challenge attempts for this student
 	var attempts []models.ChallengeAttempt
 	cursor, err := db.ChallengeAttemptsCollection.Find(
 		context.Background(),
-		bson.M{"userId": studentID},
+		window.apply(bson.M{"userId": studentID}, "createdAt"),
 		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
 	)
 
@@ -187,17 +203,19 @@ func GetStudentResultsByStudent(c *fiber.Ctx) error {
 
 		studentName, studentEmail := getStudentInfo(student)
 		result := StudentResultResponse{
-			StudentID:       studentID.Hex(),
-			StudentName:     studentName,
-			StudentEmail:    studentEmail,
-			ChallengeID:     attempt.ChallengeID.Hex(),
-			ChallengeTitle:  challenge.Title,
-			Status:          attempt.Status,
-			PercentageScore: attempt.Result.PercentageScore,
-			PointsScored:    attempt.Result.ScoredPoints,
-			TotalPoints:     attempt.Result.TotalPoints,
-			TimeSpent:       attempt.TimeSpent,
-			SubmittedAt:     attempt.CreatedAt.Format(time.RFC3339),
+			StudentID:          studentID.Hex(),
+			StudentName:        studentName,
+			StudentEmail:       studentEmail,
+			ChallengeID:        attempt.ChallengeID.Hex(),
+			ChallengeTitle:     challenge.Title,
+			Status:             attempt.Status,
+			PercentageScore:    roundPercent(attempt.Result.PercentageScore),
+			PointsScored:       roundPoints(attempt.Result.ScoredPoints),
+			TotalPoints:        roundPoints(attempt.Result.TotalPoints),
+			TimeSpent:          attempt.TimeSpent,
+			SubmittedAt:        attempt.CreatedAt.Format(time.RFC3339),
+			MatchesSolution:    attempt.MatchesSolution,
+			SolutionSimilarity: attempt.SolutionSimilarity,
 		}
 
 		results = append(results, result)
@@ -211,18 +229,23 @@ func GetStudentResultsByStudent(c *fiber.Ctx) error {
 	return c.JSON(results)
 }
 
-// GetStudentResultsByChallenge retrieves all student results for a specific challenge
+// GetStudentResultsByChallenge retrieves all student results for a specific
+// challenge, optionally only those made between ?from= and ?to=
 func GetStudentResultsByChallenge(c *fiber.Ctx) error {
 	challengeID, err := primitive.ObjectIDFromHex(c.Params("challengeId"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge ID"})
 	}
+	window, err := parseTimeWindow(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	// First get all attempts for this challenge
 	var attempts []models.ChallengeAttempt
 	cursor, err := db.ChallengeAttemptsCollection.Find(
 		context.Background(),
-		bson.M{"challengeId": challengeID},
+		window.apply(bson.M{"challengeId": challengeID}, "createdAt"),
 		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
 	)
 
@@ -274,17 +297,19 @@ func GetStudentResultsByChallenge(c *fiber.Ctx) error {
 
 		studentName, studentEmail := getStudentInfo(student)
 		result := StudentResultResponse{
-			StudentID:       attempt.UserID.Hex(),
-			StudentName:     studentName,
-			StudentEmail:    studentEmail,
-			ChallengeID:     challengeID.Hex(),
-			ChallengeTitle:  challenge.Title,
-			Status:          attempt.Status,
-			PercentageScore: attempt.Result.PercentageScore,
-			PointsScored:    attempt.Result.ScoredPoints,
-			TotalPoints:     attempt.Result.TotalPoints,
-			TimeSpent:       attempt.TimeSpent,
-			SubmittedAt:     attempt.CreatedAt.Format(time.RFC3339),
+			StudentID:          attempt.UserID.Hex(),
+			StudentName:        studentName,
+			StudentEmail:       studentEmail,
+			ChallengeID:        challengeID.Hex(),
+			ChallengeTitle:     challenge.Title,
+			Status:             attempt.Status,
+			PercentageScore:    roundPercent(attempt.Result.PercentageScore),
+			PointsScored:       roundPoints(attempt.Result.ScoredPoints),
+			TotalPoints:        roundPoints(attempt.Result.TotalPoints),
+			TimeSpent:          attempt.TimeSpent,
+			SubmittedAt:        attempt.CreatedAt.Format(time.RFC3339),
+			MatchesSolution:    attempt.MatchesSolution,
+			SolutionSimilarity: attempt.SolutionSimilarity,
 		}
 
 		results = append(results, result)
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"math"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// findAttemptProgress returns the answers a student has saved on a free-mode
+// test, or nil if they haven't saved any
+func findAttemptProgress(testID, studentID string) (*models.AttemptProgress, error) {
+	var progress models.AttemptProgress
+	err := db.AttemptProgressCollection.FindOne(context.Background(), bson.M{
+		"testId":    testID,
+		"studentId": studentID,
+	}).Decode(&progress)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// mergeAnswers overlays answers on saved, one answer per question: an answer in
+// answers replaces the saved answer to the same question
+func mergeAnswers(saved, answers []models.Answer) []models.Answer {
+	merged := make([]models.Answer, 0, len(saved)+len(answers))
+	index := make(map[string]int, len(saved)+len(answers))
+	for _, list := range [][]models.Answer{saved, answers} {
+		for _, answer := range list {
+			if i, ok := index[answer.QuestionID]; ok {
+				merged[i] = answer
+				continue
+			}
+			index[answer.QuestionID] = len(merged)
+			merged = append(merged, answer)
+		}
+	}
+	return merged
+}
+
+// savedProgressResponse describes a student's saved answers together with the
+// time they have left, computed from the server's start time rather than the client's
+func savedProgressResponse(test models.TestBSON, studentID string, progress *models.AttemptProgress) fiber.Map {
+	resp := fiber.Map{
+		"testId":    test.ID.Hex(),
+		"studentId": studentID,
+		"answers":   []models.Answer{},
+	}
+	if progress == nil {
+		return resp
+	}
+	deadline := personalDeadline(test, progress.StartedAt)
+	resp["answers"] = progress.Answers
+	resp["startedAt"] = progress.StartedAt.UTC()
+	resp["updatedAt"] = progress.UpdatedAt.UTC()
+	resp["personalDeadline"] = deadline.UTC()
+	resp["remainingSeconds"] = int(math.Max(0, time.Until(deadline).Seconds()))
+	return resp
+}
+
+// getSavedProgress answers GetTestProgress for free-mode tests
+func getSavedProgress(c *fiber.Ctx, test models.TestBSON, studentID string) error {
+	progress, err := findAttemptProgress(test.ID.Hex(), studentID)
+	if err != nil {
+		log.Printf("Failed to fetch saved answers for student %s on test %s: %v", studentID, test.ID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch progress"})
+	}
+	return c.JSON(savedProgressResponse(test, studentID, progress))
+}
+
+// SaveTestProgress saves some or all of a student's answers to a free-mode test
+// before they submit it. Answers are merged into those already saved, so a client
+// may send only the questions that changed. The start time is recorded on the
+// first save and kept from then on. Answers are always saved for the caller.
+func SaveTestProgress(c *fiber.Ctx) error {
+	var req struct {
+		Answers []models.Answer `json:"answers"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	studentID, _ := c.Locals("userId").(string)
+
+	test, ok := loadProgressTest(c)
+	if !ok {
+		return nil
+	}
+	testID := test.ID.Hex()
+	if test.Mode == models.TestModeSequential {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "This test is answered one question at a time; use the answers endpoint",
+		})
+	}
+	if !enforceSubmissionWindow(c, test, studentID) {
+		return nil
+	}
+	if !enforcePersonalDeadline(c, test, studentID) {
+		return nil
+	}
+	if err := validateAnswersForTest(req.Answers, test); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	saved, err := findAttemptProgress(testID, studentID)
+	if err != nil {
+		log.Printf("Failed to fetch saved answers for student %s on test %s: %v", studentID, testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save progress"})
+	}
+	var savedAnswers []models.Answer
+	if saved != nil {
+		savedAnswers = saved.Answers
+	}
+
+	// The first save starts the clock for a student who hasn't started yet, so the
+	// saved start always matches the one deadlines are enforced against
+	now := time.Now()
+	started, err := recordTestStart(testID, studentID, now)
+	if err != nil {
+		log.Printf("Failed to record start for student %s on test %s: %v", studentID, testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save progress"})
+	}
+
+	var updated models.AttemptProgress
+	err = db.AttemptProgressCollection.FindOneAndUpdate(context.Background(),
+		bson.M{"testId": testID, "studentId": studentID},
+		bson.M{
+			"$set":         bson.M{"answers": mergeAnswers(savedAnswers, req.Answers), "updatedAt": now},
+			"$setOnInsert": bson.M{"startedAt": started.StartedAt},
+		},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&updated)
+	if err != nil {
+		log.Printf("Failed to save answers for student %s on test %s: %v", studentID, testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to save progress"})
+	}
+
+	return c.JSON(savedProgressResponse(test, studentID, &updated))
+}
+
+// clearAttemptProgress deletes a student's saved answers once they are part of a
+// submission. Failing to delete them only leaves a stale record behind.
+func clearAttemptProgress(testID, studentID string) {
+	_, err := db.AttemptProgressCollection.DeleteOne(context.Background(), bson.M{
+		"testId":    testID,
+		"studentId": studentID,
+	})
+	if err != nil {
+		log.Printf("Failed to clear saved answers for student %s on test %s: %v", studentID, testID, err)
+	}
+}
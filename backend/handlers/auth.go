@@ -3,8 +3,11 @@ package handlers
 import (
 	"context"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,11 +15,13 @@ import (
 	"path/filepath"
 	"qms-backend/db"
 	"qms-backend/models"
+	"strconv"
 	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -126,7 +131,6 @@ func init() {
 	err = json.Unmarshal(data, &config)
 	if err != nil {
 		log.Printf("Error parsing credential file: %v", err)
-		log.Printf("File content: %s", string(data))
 		return
 	}
 
@@ -147,22 +151,12 @@ func init() {
 			Endpoint:     google.Endpoint,
 		}
 
-		log.Printf("Google OAuth configured with ClientID: %s..., RedirectURL: %s",
-			truncateString(config.Web.ClientID, 10),
-			redirectURL)
+		log.Printf("Google OAuth configured with RedirectURL: %s", redirectURL)
 	} else {
 		log.Println("WARNING: Google OAuth client credentials are empty in the JSON file.")
 	}
 }
 
-// Helper function to safely truncate strings
-func truncateString(s string, maxLen int) string {
-	if len(s) <= maxLen {
-		return s
-	}
-	return s[:maxLen] + "..."
-}
-
 // Helper to get environment variable with default
 func getEnvWithDefault(key, defaultValue string) string {
 	if value := os.Getenv(key); value != "" {
@@ -171,6 +165,9 @@ func getEnvWithDefault(key, defaultValue string) string {
 	return defaultValue
 }
 
+// oauthStateTTL is how long a user has to complete the provider's login
+const oauthStateTTL = 15 * time.Minute
+
 // Generates a random state string for OAuth
 func generateState() (string, error) {
 	b := make([]byte, 32)
@@ -202,6 +199,7 @@ func GenerateJWT(user models.AuthUser) (string, error) {
 		"email":  user.Email,
 		"role":   user.Role,
 		"exp":    expirationTime.Unix(),
+		"jti":    uuid.NewString(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -210,6 +208,152 @@ func GenerateJWT(user models.AuthUser) (string, error) {
 	return tokenString, err
 }
 
+// accessTokenTTL is the access token lifetime, ACCESS_TOKEN_TTL_MINUTES (default 15)
+func accessTokenTTL() time.Duration {
+	if minutes, err := strconv.Atoi(getEnvWithDefault("ACCESS_TOKEN_TTL_MINUTES", "15")); err == nil && minutes > 0 {
+		return time.Duration(minutes) * time.Minute
+	}
+	return 15 * time.Minute
+}
+
+// refreshTokenTTL is the refresh token lifetime, REFRESH_TOKEN_TTL_HOURS (default 720, i.e. 30 days)
+func refreshTokenTTL() time.Duration {
+	if hours, err := strconv.Atoi(getEnvWithDefault("REFRESH_TOKEN_TTL_HOURS", "720")); err == nil && hours > 0 {
+		return time.Duration(hours) * time.Hour
+	}
+	return 720 * time.Hour
+}
+
+// generateAccessToken signs a JWT for user that expires after ttl
+func generateAccessToken(user models.AuthUser, ttl time.Duration) (string, error) {
+	claims := &jwt.MapClaims{
+		"userId": user.ID.Hex(),
+		"email":  user.Email,
+		"role":   user.Role,
+		"exp":    time.Now().Add(ttl).Unix(),
+		"jti":    uuid.NewString(),
+	}
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(jwtSecret)
+}
+
+// hashRefreshToken is what's stored in place of the refresh token itself
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// issueRefreshToken creates a new refresh session in familyID and returns its token
+func issueRefreshToken(userID primitive.ObjectID, familyID string) (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	now := time.Now()
+	_, err := db.SessionsCollection.InsertOne(context.Background(), models.Session{
+		UserID:      userID,
+		RefreshHash: hashRefreshToken(token),
+		FamilyID:    familyID,
+		CreatedAt:   now,
+		ExpiresAt:   now.Add(refreshTokenTTL()),
+	})
+	if err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
+// GenerateTokenPair issues a short-lived access token and a long-lived refresh
+// token that starts a new session family
+func GenerateTokenPair(user models.AuthUser) (accessToken, refreshToken string, err error) {
+	accessToken, err = generateAccessToken(user, accessTokenTTL())
+	if err != nil {
+		return "", "", err
+	}
+	refreshToken, err = issueRefreshToken(user.ID, primitive.NewObjectID().Hex())
+	if err != nil {
+		return "", "", err
+	}
+	return accessToken, refreshToken, nil
+}
+
+// presentedRefreshToken reads the refresh token from the request body, falling
+// back to the refresh_token cookie
+func presentedRefreshToken(c *fiber.Ctx) string {
+	var req struct {
+		RefreshToken string `json:"refreshToken"`
+	}
+	if err := c.BodyParser(&req); err == nil && req.RefreshToken != "" {
+		return req.RefreshToken
+	}
+	return c.Cookies("refresh_token")
+}
+
+// revokeSessionFamily deletes every refresh session descended from the same login
+func revokeSessionFamily(familyID string) {
+	if _, err := db.SessionsCollection.DeleteMany(context.Background(), bson.M{"familyId": familyID}); err != nil {
+		log.Printf("Failed to revoke session family %s: %v", familyID, err)
+	}
+}
+
+// RefreshToken exchanges a refresh token for a new access token and a rotated
+// refresh token. Each refresh token works once: presenting one that was already
+// rotated means it leaked, so the whole session family is revoked.
+func RefreshToken(c *fiber.Ctx) error {
+	token := presentedRefreshToken(c)
+	if token == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Refresh token is required"})
+	}
+
+	var session models.Session
+	err := db.SessionsCollection.FindOne(context.Background(), bson.M{"refreshHash": hashRefreshToken(token)}).Decode(&session)
+	if err != nil {
+		// Unknown, expired and purged, or deleted by logout
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid refresh token"})
+	}
+	if time.Now().After(session.ExpiresAt) {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token has expired"})
+	}
+
+	// Claim the token atomically so two concurrent refreshes can't both succeed
+	res, err := db.SessionsCollection.UpdateOne(context.Background(),
+		bson.M{"_id": session.ID, "rotatedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"rotatedAt": time.Now()}})
+	if err != nil {
+		log.Printf("Failed to rotate refresh token: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to refresh token"})
+	}
+	if res.ModifiedCount == 0 {
+		log.Printf("Refresh token reuse detected for user %s, revoking session family %s", session.UserID.Hex(), session.FamilyID)
+		revokeSessionFamily(session.FamilyID)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Refresh token has already been used"})
+	}
+
+	var user models.AuthUser
+	if err := db.UsersCollection.FindOne(context.Background(), bson.M{"_id": session.UserID}).Decode(&user); err != nil {
+		revokeSessionFamily(session.FamilyID)
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "User no longer exists"})
+	}
+
+	accessToken, err := generateAccessToken(user, accessTokenTTL())
+	if err != nil {
+		log.Printf("Failed to generate token: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
+	}
+	refreshToken, err := issueRefreshToken(user.ID, session.FamilyID)
+	if err != nil {
+		log.Printf("Failed to issue refresh token: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to refresh token"})
+	}
+
+	return c.JSON(fiber.Map{
+		"token":        accessToken,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(accessTokenTTL().Seconds()),
+	})
+}
+
 type LoginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -237,16 +381,18 @@ func Login(c *fiber.Ctx) error {
 		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid email or password"})
 	}
 
-	// Generate JWT token
-	token, err := GenerateJWT(user)
+	// Generate the access and refresh tokens
+	token, refreshToken, err := GenerateTokenPair(user)
 	if err != nil {
 		log.Printf("Failed to generate token: %v", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to generate token"})
 	}
 
-	// Return the user data and token
+	// Return the user data and tokens
 	return c.JSON(fiber.Map{
-		"token": token,
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(accessTokenTTL().Seconds()),
 		"user": fiber.Map{
 			"id":        user.ID,
 			"email":     user.Email,
@@ -257,19 +403,90 @@ func Login(c *fiber.Ctx) error {
 	})
 }
 
-// Logout handles user logout
+// jwtKeyFunc supplies the signing key, accepting only HMAC-signed tokens
+func jwtKeyFunc(token *jwt.Token) (interface{}, error) {
+	// Validate the algorithm
+	if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+		return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+	}
+	return jwtSecret, nil
+}
+
+// revokeBearerToken blacklists the request's bearer token by its jti until it
+// expires. It reports whether a valid token was presented.
+func revokeBearerToken(c *fiber.Ctx) (bool, error) {
+	tokenString, found := strings.CutPrefix(c.Get("Authorization"), "Bearer ")
+	if !found || tokenString == "" {
+		return false, nil
+	}
+	token, err := jwt.Parse(tokenString, jwtKeyFunc)
+	if err != nil || !token.Valid {
+		// Expired or forged tokens are already unusable
+		return false, nil
+	}
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return false, nil
+	}
+	jti, _ := claims["jti"].(string)
+	exp, _ := claims["exp"].(float64)
+	if jti == "" || exp == 0 {
+		return true, nil
+	}
+
+	revoked := models.RevokedToken{
+		JTI:       jti,
+		RevokedAt: time.Now(),
+		ExpiresAt: time.Unix(int64(exp), 0),
+	}
+	if userID, ok := claims["userId"].(string); ok {
+		revoked.UserID, _ = primitive.ObjectIDFromHex(userID)
+	}
+	_, err = db.RevokedTokensCollection.InsertOne(context.Background(), revoked)
+	if err != nil && !mongo.IsDuplicateKeyError(err) {
+		return true, err
+	}
+	return true, nil
+}
+
+// Logout handles user logout. It revokes the bearer token, ends the cookie
+// session and, when a refresh token is presented, every refresh session from
+// the same login.
 func Logout(c *fiber.Ctx) error {
+	hadBearer, err := revokeBearerToken(c)
+	if err != nil {
+		log.Printf("Failed to revoke token: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to logout"})
+	}
+
 	// Get the session token from the cookie
 	token := c.Cookies("session_token")
-	if token == "" {
+	refreshToken := presentedRefreshToken(c)
+	if token == "" && refreshToken == "" && !hadBearer {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "No session token found"})
 	}
 
 	// Delete the session from the database
-	_, err := db.SessionsCollection.DeleteOne(context.Background(), bson.M{"token": token})
-	if err != nil {
-		log.Printf("Failed to delete session: %v", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to logout"})
+	if token != "" {
+		_, err := db.SessionsCollection.DeleteOne(context.Background(), bson.M{"token": token})
+		if err != nil {
+			log.Printf("Failed to delete session: %v", err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to logout"})
+		}
+	}
+	if refreshToken != "" {
+		var session models.Session
+		err := db.SessionsCollection.FindOne(context.Background(), bson.M{"refreshHash": hashRefreshToken(refreshToken)}).Decode(&session)
+		if err == nil {
+			revokeSessionFamily(session.FamilyID)
+		}
+		c.Cookie(&fiber.Cookie{
+			Name:     "refresh_token",
+			Value:    "",
+			Path:     "/api/auth",
+			Expires:  time.Now().Add(-1 * time.Hour),
+			HTTPOnly: true,
+		})
 	}
 
 	// Clear the session cookie
@@ -386,20 +603,22 @@ func Register(c *fiber.Ctx) error {
 		})
 	}
 
-	// Generate JWT token
-	token, err := GenerateJWT(newUser)
+	// Generate the access and refresh tokens
+	token, refreshToken, err := GenerateTokenPair(newUser)
 	if err != nil {
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 			"error": "Failed to generate authentication token",
 		})
 	}
 
-	// Return the user and token
+	// Return the user and tokens
 	newUser.PasswordHash = "" // Don't send the password hash to the client
 	return c.Status(fiber.StatusCreated).JSON(fiber.Map{
-		"token": token,
-		"user":  newUser,
-		"role":  newUser.Role,
+		"token":        token,
+		"refreshToken": refreshToken,
+		"expiresIn":    int(accessTokenTTL().Seconds()),
+		"user":         newUser,
+		"role":         newUser.Role,
 	})
 }
 
@@ -416,12 +635,14 @@ func OAuthRedirect(c *fiber.Ctx) error {
 		})
 	}
 
-	// Detailed debug info
-	log.Printf("DEBUG - Checking OAuth config for %s:", provider)
-	log.Printf("  - ClientID: %s", truncateString(config.ClientID, 10))
-	log.Printf("  - ClientSecret: %s", truncateString(config.ClientSecret, 5))
-	log.Printf("  - RedirectURL: %s", config.RedirectURL)
-	log.Printf("  - Scopes: %v", config.Scopes)
+	// Detailed debug info; credentials are only reported as present or missing
+	if debugLogging() {
+		log.Printf("DEBUG - Checking OAuth config for %s:", provider)
+		log.Printf("  - ClientID set: %v", config.ClientID != "")
+		log.Printf("  - ClientSecret set: %v", config.ClientSecret != "")
+		log.Printf("  - RedirectURL: %s", config.RedirectURL)
+		log.Printf("  - Scopes: %v", config.Scopes)
+	}
 
 	// Check for empty OAuth credentials
 	if config.ClientID == "" || config.ClientSecret == "" {
@@ -440,23 +661,38 @@ func OAuthRedirect(c *fiber.Ctx) error {
 		})
 	}
 
-	// Store the state in a cookie
+	// The state is recorded server-side so it can only be used once
+	now := time.Now().UTC()
+	_, err = db.OAuthStatesCollection.InsertOne(context.Background(), models.OAuthState{
+		State:     state,
+		Provider:  provider,
+		CreatedAt: now,
+		ExpiresAt: now.Add(oauthStateTTL),
+	})
+	if err != nil {
+		log.Printf("Failed to store OAuth state: %v", err)
+		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+			"error": "Failed to generate state",
+		})
+	}
+
+	// The cookie ties the state to this browser, so a callback URL started by
+	// someone else can't be completed here. Lax still sends it on the provider's redirect.
 	cookie := &fiber.Cookie{
 		Name:     "oauth_state",
 		Value:    state,
-		Expires:  time.Now().Add(15 * time.Minute),
+		Expires:  now.Add(oauthStateTTL),
 		HTTPOnly: true,
 		SameSite: "Lax",
 	}
 
-	log.Printf("Setting OAuth state cookie: %s=%s, Expires: %v",
-		cookie.Name, truncateString(cookie.Value, 10), cookie.Expires)
+	log.Printf("Setting OAuth state cookie %s, Expires: %v", cookie.Name, cookie.Expires)
 
 	c.Cookie(cookie)
 
 	// Redirect to the OAuth provider
 	url := config.AuthCodeURL(state)
-	log.Printf("Redirecting to OAuth URL: %s", url)
+	log.Printf("Redirecting to OAuth URL: %s", SanitizeURL(url))
 
 	// Try-catch equivalent to handle panic during redirect
 	defer func() {
@@ -468,14 +704,87 @@ func OAuthRedirect(c *fiber.Ctx) error {
 	return c.Redirect(url, http.StatusTemporaryRedirect)
 }
 
+// consumeOAuthState reports whether state was issued by OAuthRedirect for
+// provider and hasn't expired, deleting it so it can't be replayed. The browser
+// must also send the matching state cookie set by OAuthRedirect.
+func consumeOAuthState(c *fiber.Ctx, provider, state string) bool {
+	if state == "" {
+		log.Printf("Missing state parameter")
+		return false
+	}
+
+	// Checked before the lookup so a mismatched request doesn't burn the state
+	cookie := c.Cookies("oauth_state")
+	if cookie == "" || cookie != state {
+		log.Printf("Invalid state parameter (state cookie missing or does not match)")
+		return false
+	}
+
+	var stored models.OAuthState
+	err := db.OAuthStatesCollection.FindOneAndDelete(context.Background(), bson.M{
+		"_id":       state,
+		"provider":  provider,
+		"expiresAt": bson.M{"$gt": time.Now().UTC()}, // Mongo purges expired states lazily
+	}).Decode(&stored)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to look up OAuth state: %v", err)
+		}
+		log.Printf("Invalid state parameter (unknown or expired)")
+		return false
+	}
+
+	c.Cookie(&fiber.Cookie{
+		Name:     "oauth_state",
+		Value:    "",
+		Expires:  time.Now().Add(-time.Hour),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+	return true
+}
+
+// errOAuthMethodMismatch means an account with the email signs in through another
+// provider, or another identity at the same provider
+var errOAuthMethodMismatch = errors.New("account exists with a different sign-in method")
+
+// linkOAuthAccount records an OAuth identity on an existing account with the same
+// email, so a password user signing in with a provider keeps one account. Accounts
+// already linked to another identity are never relinked, and an email the
+// provider hasn't verified is never trusted to claim an account.
+func linkOAuthAccount(user *models.AuthUser, provider, oauthID string, verified bool) error {
+	if user.OAuthProvider != "" || !verified {
+		return errOAuthMethodMismatch
+	}
+
+	now := time.Now()
+	result, err := db.UsersCollection.UpdateOne(
+		context.Background(),
+		// Guards against a concurrent login linking a different identity first
+		bson.M{"_id": user.ID, "oauthProvider": bson.M{"$in": bson.A{nil, ""}}},
+		bson.M{"$set": bson.M{"oauthId": oauthID, "oauthProvider": provider, "updatedAt": now}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.MatchedCount == 0 {
+		return errOAuthMethodMismatch
+	}
+
+	log.Printf("Linked %s sign-in to existing user %s", provider, user.ID.Hex())
+	user.OAuthID = oauthID
+	user.OAuthProvider = provider
+	user.UpdatedAt = now
+	return nil
+}
+
 // OAuthCallback handles the callback from the OAuth provider
 func OAuthCallback(c *fiber.Ctx) error {
 	provider := c.Params("provider")
 	log.Printf("OAuth callback received for provider: %s", provider)
 
 	// Get all request parameters for debugging
-	log.Printf("Callback URL: %s", c.OriginalURL())
-	log.Printf("All query parameters: %s", c.Query("*"))
+	log.Printf("Callback URL: %s", SanitizeURL(c.OriginalURL()))
 
 	config, ok := oauthConfigs[provider]
 	if !ok {
@@ -508,21 +817,10 @@ func OAuthCallback(c *fiber.Ctx) error {
 		})
 	}
 
-	// Safe logging that avoids index out of range errors
-	codePreview := "empty"
-	if len(code) > 5 {
-		codePreview = code[:5] + "..."
-	} else if len(code) > 0 {
-		codePreview = code + "..."
-	}
-	log.Printf("OAuth callback received with state: %s and code: %s", state, codePreview)
+	log.Printf("OAuth callback received with state and authorization code")
 
 	// Verify the state
-	cookie := c.Cookies("oauth_state")
-	log.Printf("OAuth state cookie value: %s", cookie)
-
-	if cookie == "" || cookie != state {
-		log.Printf("Invalid state parameter. Cookie: %s, State: %s", cookie, state)
+	if !consumeOAuthState(c, provider, state) {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": "Invalid state parameter",
 		})
@@ -568,18 +866,33 @@ func OAuthCallback(c *fiber.Ctx) error {
 	log.Printf("Successfully fetched user info: Email=%s, Name=%s",
 		userInfo.Email, userInfo.Name)
 
-	// Check if the user exists
+	// Check if the user exists. Returning OAuth users are matched by their provider
+	// identity first, so an email changed at the provider doesn't lose the account.
 	log.Printf("Checking if user exists in database...")
 	var user models.AuthUser
 	err = db.UsersCollection.FindOne(
 		context.Background(),
-		bson.M{
-			"$or": []bson.M{
-				{"email": strings.ToLower(userInfo.Email)},
-				{"oauthId": userInfo.ID, "oauthProvider": provider},
-			},
-		},
+		bson.M{"oauthId": userInfo.ID, "oauthProvider": provider},
 	).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		err = db.UsersCollection.FindOne(
+			context.Background(),
+			bson.M{"email": strings.ToLower(userInfo.Email)},
+		).Decode(&user)
+		if err == nil {
+			if linkErr := linkOAuthAccount(&user, provider, userInfo.ID, userInfo.Verified); linkErr == errOAuthMethodMismatch {
+				log.Printf("User %s already signs in with %q or the %s email is unverified, refusing login", user.ID.Hex(), user.OAuthProvider, provider)
+				return c.Status(fiber.StatusConflict).JSON(fiber.Map{
+					"error": "An account with this email already exists with a different sign-in method",
+				})
+			} else if linkErr != nil {
+				log.Printf("Failed to link %s account to user %s: %v", provider, user.ID.Hex(), linkErr)
+				return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+					"error": "Failed to link account",
+				})
+			}
+		}
+	}
 
 	// If the user doesn't exist, create a new one
 	if err == mongo.ErrNoDocuments {
@@ -612,13 +925,22 @@ func OAuthCallback(c *fiber.Ctx) error {
 			user.FirstName, user.LastName, user.Email)
 
 		_, err = db.UsersCollection.InsertOne(context.Background(), user)
+		if mongo.IsDuplicateKeyError(err) {
+			// A concurrent first login created this user between our lookup and
+			// insert (relies on the unique email index); use the existing record
+			log.Printf("User %s was created concurrently, loading existing record", user.Email)
+			err = db.UsersCollection.FindOne(
+				context.Background(),
+				bson.M{"email": user.Email},
+			).Decode(&user)
+		}
 		if err != nil {
 			log.Printf("Failed to create user: %v", err)
 			return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
 				"error": "Failed to create user",
 			})
 		}
-		log.Printf("New user created successfully with ID: %s", user.ID.Hex())
+		log.Printf("User ready with ID: %s", user.ID.Hex())
 	} else if err != nil {
 		log.Printf("Error checking if user exists: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -630,7 +952,7 @@ func OAuthCallback(c *fiber.Ctx) error {
 
 	// Generate JWT token
 	log.Printf("Generating JWT token for user ID: %s", user.ID.Hex())
-	jwtToken, err := GenerateJWT(user)
+	jwtToken, refreshToken, err := GenerateTokenPair(user)
 	if err != nil {
 		log.Printf("Failed to generate authentication token: %v", err)
 		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
@@ -643,7 +965,16 @@ func OAuthCallback(c *fiber.Ctx) error {
 	c.Cookie(&fiber.Cookie{
 		Name:     "auth_token",
 		Value:    jwtToken,
-		Expires:  time.Now().Add(24 * time.Hour),
+		Expires:  time.Now().Add(accessTokenTTL()),
+		HTTPOnly: true,
+		SameSite: "Lax",
+	})
+	// The refresh token never goes in the redirect URL; the SPA refreshes through the cookie
+	c.Cookie(&fiber.Cookie{
+		Name:     "refresh_token",
+		Value:    refreshToken,
+		Path:     "/api/auth",
+		Expires:  time.Now().Add(refreshTokenTTL()),
 		HTTPOnly: true,
 		SameSite: "Lax",
 	})
@@ -651,7 +982,7 @@ func OAuthCallback(c *fiber.Ctx) error {
 	// Redirect to the frontend with the token
 	frontendURL := getEnvWithDefault("FRONTEND_URL", "http://localhost:5176")
 	redirectURL := fmt.Sprintf("%s/oauth-callback?token=%s", frontendURL, jwtToken)
-	log.Printf("Redirecting to frontend: %s", redirectURL)
+	log.Printf("Redirecting to frontend: %s", SanitizeURL(redirectURL))
 	return c.Redirect(redirectURL, http.StatusTemporaryRedirect)
 }
 
@@ -676,6 +1007,7 @@ func getGoogleUserInfo(accessToken string) (models.OAuthUserInfo, error) {
 	userInfo.Email = fmt.Sprintf("%v", data["email"])
 	userInfo.Name = fmt.Sprintf("%v", data["name"])
 	userInfo.Picture = fmt.Sprintf("%v", data["picture"])
+	userInfo.Verified, _ = data["verified_email"].(bool)
 
 	// Try to get first and last name
 	if given, ok := data["given_name"]; ok {
@@ -734,19 +1066,30 @@ func getGithubUserInfo(accessToken string) (models.OAuthUserInfo, error) {
 		return userInfo, err
 	}
 
-	// Find the primary email
+	// Prefer the primary email when it's verified, then any verified email, and
+	// only then fall back to an unverified one
+	rank := func(email map[string]interface{}) int {
+		primary, _ := email["primary"].(bool)
+		verified, _ := email["verified"].(bool)
+		switch {
+		case primary && verified:
+			return 3
+		case verified:
+			return 2
+		case primary:
+			return 1
+		}
+		return 0
+	}
+	best := -1
 	for _, email := range emails {
-		if primary, ok := email["primary"].(bool); ok && primary {
+		if r := rank(email); r > best {
+			best = r
 			userInfo.Email = fmt.Sprintf("%v", email["email"])
-			break
+			userInfo.Verified, _ = email["verified"].(bool)
 		}
 	}
 
-	// If no primary email found, use the first one
-	if userInfo.Email == "" && len(emails) > 0 {
-		userInfo.Email = fmt.Sprintf("%v", emails[0]["email"])
-	}
-
 	// Parse the name into first and last name
 	if userInfo.Name != "" {
 		parts := strings.Split(userInfo.Name, " ")
@@ -762,71 +1105,114 @@ func getGithubUserInfo(accessToken string) (models.OAuthUserInfo, error) {
 // AuthMiddleware protects routes that require authentication
 func AuthMiddleware() fiber.Handler {
 	return func(c *fiber.Ctx) error {
-		// Get the Authorization header
-		authHeader := c.Get("Authorization")
-		if authHeader == "" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authorization header is required",
+		if status, message := authenticate(c); status != 0 {
+			return c.Status(status).JSON(fiber.Map{
+				"error": message,
 			})
 		}
 
-		// Check if the header is in the correct format
-		parts := strings.Split(authHeader, " ")
-		if len(parts) != 2 || parts[0] != "Bearer" {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Authorization header must be in the format: Bearer [token]",
-			})
+		// Continue to the next middleware/handler
+		return c.Next()
+	}
+}
+
+// OptionalAuthMiddleware identifies the caller when the request carries a valid
+// token and otherwise lets it through anonymously. It's for public routes whose
+// response or limits depend on who is asking.
+func OptionalAuthMiddleware() fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		if c.Get("Authorization") != "" {
+			authenticate(c)
 		}
+		return c.Next()
+	}
+}
 
-		// Parse the token
-		tokenString := parts[1]
-		token, err := jwt.Parse(tokenString, func(token *jwt.Token) (interface{}, error) {
-			// Validate the algorithm
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
-			}
-			return jwtSecret, nil
-		})
+// authenticate validates the request's bearer token and stores the user ID, role
+// and claims in the context. On failure it returns the status and error message
+// to respond with, and the context is left untouched.
+func authenticate(c *fiber.Ctx) (int, string) {
+	// Get the Authorization header
+	authHeader := c.Get("Authorization")
+	if authHeader == "" {
+		return fiber.StatusUnauthorized, "Authorization header is required"
+	}
 
-		// Check for errors
-		if err != nil {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid or expired token",
-			})
-		}
+	// Check if the header is in the correct format
+	parts := strings.Split(authHeader, " ")
+	if len(parts) != 2 || parts[0] != "Bearer" {
+		return fiber.StatusUnauthorized, "Authorization header must be in the format: Bearer [token]"
+	}
 
-		// Check if the token is valid
-		if !token.Valid {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token",
-			})
-		}
+	// Parse the token
+	tokenString := parts[1]
+	token, err := jwt.Parse(tokenString, jwtKeyFunc)
 
-		// Extract the claims
-		claims, ok := token.Claims.(jwt.MapClaims)
-		if !ok {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Invalid token claims",
-			})
-		}
+	// Check for errors
+	if err != nil {
+		return fiber.StatusUnauthorized, "Invalid or expired token"
+	}
 
-		// Check if the token is expired
-		exp, ok := claims["exp"].(float64)
-		if !ok || float64(time.Now().Unix()) > exp {
-			return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{
-				"error": "Token has expired",
-			})
+	// Check if the token is valid
+	if !token.Valid {
+		return fiber.StatusUnauthorized, "Invalid token"
+	}
+
+	// Extract the claims
+	claims, ok := token.Claims.(jwt.MapClaims)
+	if !ok {
+		return fiber.StatusUnauthorized, "Invalid token claims"
+	}
+
+	// Check if the token is expired
+	exp, ok := claims["exp"].(float64)
+	if !ok || float64(time.Now().Unix()) > exp {
+		return fiber.StatusUnauthorized, "Token has expired"
+	}
+
+	// Reject tokens revoked by logout. Tokens issued before jti was added carry
+	// none and stay valid until they expire.
+	if jti, _ := claims["jti"].(string); jti != "" {
+		revoked, err := db.RevokedTokensCollection.CountDocuments(context.Background(), bson.M{"_id": jti})
+		if err != nil {
+			log.Printf("Failed to check token revocation: %v", err)
+			return fiber.StatusInternalServerError, "Failed to verify token"
+		}
+		if revoked > 0 {
+			return fiber.StatusUnauthorized, "Token has been revoked"
 		}
+	}
 
-		// Set the user ID and role in the context
-		userID, _ := claims["userId"].(string)
-		role, _ := claims["role"].(string)
-		c.Locals("userId", userID)
-		c.Locals("userRole", role)
+	// Set the user ID and role in the context
+	userID, _ := claims["userId"].(string)
+	role, _ := claims["role"].(string)
+	c.Locals("userId", userID)
+	c.Locals("userRole", role)
+	c.Locals("tokenClaims", claims)
+	return 0, ""
+}
 
-		// Continue to the next middleware/handler
-		return c.Next()
+// VerifyToken returns the validated claims of the presented token. It runs
+// behind AuthMiddleware, so reaching it means the token is valid.
+func VerifyToken(c *fiber.Ctx) error {
+	claims, ok := c.Locals("tokenClaims").(jwt.MapClaims)
+	if !ok {
+		return c.Status(fiber.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid token claims"})
 	}
+
+	userID, _ := claims["userId"].(string)
+	email, _ := claims["email"].(string)
+	role, _ := claims["role"].(string)
+	exp, _ := claims["exp"].(float64)
+
+	return c.JSON(fiber.Map{
+		"valid":     true,
+		"userId":    userID,
+		"email":     email,
+		"role":      role,
+		"exp":       int64(exp),
+		"expiresAt": time.Unix(int64(exp), 0).UTC(),
+	})
 }
 
 // RoleMiddleware checks if the user has the required role
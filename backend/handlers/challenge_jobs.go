@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+	"qms-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// challengeJob is a stored pending attempt waiting to be executed
+type challengeJob struct {
+	attemptID primitive.ObjectID
+	// guardKey is the submission slot the attempt holds until it is graded;
+	// empty for attempts requeued after a restart
+	guardKey string
+	// cooldownUntil is the submission cooldown the attempt claimed, given back
+	// if it can't be graded; zero when none was claimed
+	cooldownUntil time.Time
+}
+
+// challengeJobs feeds asynchronous submissions to the job workers. It is nil
+// until StartChallengeJobWorkers runs, and async submissions are refused then.
+var challengeJobs chan challengeJob
+
+// jobHub pushes finished attempts to WebSocket subscribers
+var jobHub *Hub
+
+// attemptTopic is the topic a client subscribes to for the result of one attempt
+func attemptTopic(attemptID string) string {
+	return "attempt:" + attemptID
+}
+
+// gradedAttempts restricts filter to attempts that have a result, leaving out
+// those still queued and those whose execution failed
+func gradedAttempts(filter bson.M) bson.M {
+	filter["status"] = bson.M{"$nin": bson.A{models.AttemptStatusPending, models.AttemptStatusError}}
+	return filter
+}
+
+// StartChallengeJobWorkers starts concurrency workers executing asynchronous
+// challenge submissions, with room for queueSize waiting jobs. Attempts left
+// pending by a previous run are queued again. Workers stop when ctx is
+// cancelled; jobs still queued then stay pending and are picked up on the next start.
+func StartChallengeJobWorkers(ctx context.Context, hub *Hub, concurrency, queueSize int) {
+	challengeJobs = make(chan challengeJob, queueSize)
+	jobHub = hub
+
+	fmt.Printf("Starting %d challenge job workers (queue size %d)...\n", concurrency, queueSize)
+	for i := 0; i < concurrency; i++ {
+		workers.Add(1)
+		go func() {
+			defer workers.Done()
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case job := <-challengeJobs:
+					runChallengeJob(job)
+				}
+			}
+		}()
+	}
+
+	startedAt := time.Now()
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		requeuePendingAttempts(ctx, startedAt)
+	}()
+}
+
+// requeuePendingAttempts queues the attempts a previous run accepted but never
+// graded; attempts submitted since before are already queued
+func requeuePendingAttempts(ctx context.Context, before time.Time) {
+	cursor, err := db.ChallengeAttemptsCollection.Find(ctx, bson.M{
+		"status":    models.AttemptStatusPending,
+		"createdAt": bson.M{"$lt": before},
+	})
+	if err != nil {
+		log.Printf("Failed to fetch pending challenge attempts: %v", err)
+		return
+	}
+	var pending []models.ChallengeAttempt
+	if err := cursor.All(ctx, &pending); err != nil {
+		log.Printf("Failed to decode pending challenge attempts: %v", err)
+		return
+	}
+	for _, attempt := range pending {
+		select {
+		case challengeJobs <- challengeJob{attemptID: attempt.ID}:
+		case <-ctx.Done():
+			return
+		}
+	}
+	if len(pending) > 0 {
+		fmt.Printf("Requeued %d pending challenge attempts\n", len(pending))
+	}
+}
+
+// enqueueChallengeJob queues job without blocking, reporting false when async
+// execution isn't running or the queue is full
+func enqueueChallengeJob(job challengeJob) bool {
+	if challengeJobs == nil {
+		return false
+	}
+	select {
+	case challengeJobs <- job:
+		return true
+	default:
+		return false
+	}
+}
+
+// runChallengeJob executes a pending attempt, stores its result and tells the
+// attempt's subscribers it is done
+func runChallengeJob(job challengeJob) {
+	if job.guardKey != "" {
+		defer challengeSubmissions.release(job.guardKey)
+	}
+
+	var attempt models.ChallengeAttempt
+	err := db.ChallengeAttemptsCollection.FindOne(context.Background(), bson.M{
+		"_id":    job.attemptID,
+		"status": models.AttemptStatusPending,
+	}).Decode(&attempt)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to fetch pending attempt %s: %v", job.attemptID.Hex(), err)
+		}
+		return
+	}
+
+	// A challenge deleted after the submission was accepted still grades it
+	var challenge models.CodingChallenge
+	err = db.ChallengesCollection.FindOne(context.Background(), bson.M{"_id": attempt.ChallengeID}).Decode(&challenge)
+	if err == nil {
+		var validationResult *models.ValidationResult
+		validationResult, err = services.NewCodeExecutionService().ExecuteCode(context.Background(), &challenge, attempt.Language, attempt.Code)
+		if err == nil {
+			attempt.Result = *validationResult
+			attempt.Status = "Failed"
+			if validationResult.Passed {
+				attempt.Status = "Passed"
+			}
+			flagSolutionMatch(&attempt, &challenge)
+		}
+	}
+	if err != nil {
+		log.Printf("Failed to execute attempt %s: %v", job.attemptID.Hex(), err)
+		attempt.Status = models.AttemptStatusError
+		attempt.Error = err.Error()
+		releaseSubmissionCooldown(attempt.ChallengeID, job.guardKey, job.cooldownUntil)
+	}
+
+	// Only a still-pending attempt is updated, so a requeued duplicate can't overwrite it
+	res, err := db.ChallengeAttemptsCollection.UpdateOne(context.Background(),
+		bson.M{"_id": attempt.ID, "status": models.AttemptStatusPending},
+		bson.M{"$set": bson.M{
+			"status":             attempt.Status,
+			"result":             attempt.Result,
+			"solutionSimilarity": attempt.SolutionSimilarity,
+			"matchesSolution":    attempt.MatchesSolution,
+			"error":              attempt.Error,
+		}})
+	if err != nil {
+		log.Printf("Failed to store result of attempt %s: %v", attempt.ID.Hex(), err)
+		return
+	}
+	if res.ModifiedCount == 0 {
+		return
+	}
+
+	if attempt.Status != models.AttemptStatusError {
+		notifyLeaderboard(attempt.ChallengeID)
+		awardChallengePoints(&attempt, &challenge)
+	}
+	if jobHub != nil {
+		jobHub.BroadcastAttemptDone(attempt.ID.Hex(), attempt.Status)
+	}
+}
+
+// submitChallengeAttemptAsync stores attempt as pending and queues it for
+// execution, answering 202 straight away. The job releases guardKey when done.
+func submitChallengeAttemptAsync(c *fiber.Ctx, attempt *models.ChallengeAttempt, guardKey string, cooldownUntil time.Time) error {
+	attempt.ID = primitive.NewObjectID()
+	attempt.Status = models.AttemptStatusPending
+	if _, err := db.ChallengeAttemptsCollection.InsertOne(context.Background(), attempt); err != nil {
+		challengeSubmissions.release(guardKey)
+		releaseSubmissionCooldown(attempt.ChallengeID, guardKey, cooldownUntil)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+			"error":   "Failed to record challenge attempt",
+			"details": err.Error(),
+		})
+	}
+
+	if !enqueueChallengeJob(challengeJob{attemptID: attempt.ID, guardKey: guardKey, cooldownUntil: cooldownUntil}) {
+		challengeSubmissions.release(guardKey)
+		releaseSubmissionCooldown(attempt.ChallengeID, guardKey, cooldownUntil)
+		if _, err := db.ChallengeAttemptsCollection.DeleteOne(context.Background(), bson.M{"_id": attempt.ID}); err != nil {
+			log.Printf("Failed to remove unqueued attempt %s: %v", attempt.ID.Hex(), err)
+		}
+		c.Set(fiber.HeaderRetryAfter, "5")
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{
+			"error": "Too many submissions are waiting to run, please try again shortly",
+		})
+	}
+
+	id := attempt.ID.Hex()
+	return c.Status(http.StatusAccepted).JSON(fiber.Map{
+		"attemptId": id,
+		"status":    attempt.Status,
+		"statusUrl": "/api/challenges/attempts/" + id + "/status",
+		"topic":     attemptTopic(id),
+	})
+}
+
+// GetChallengeAttemptStatus reports whether an attempt submitted asynchronously
+// has been graded, with the attempt once it has
+func GetChallengeAttemptStatus(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid attempt ID"})
+	}
+
+	var attempt models.ChallengeAttempt
+	err = db.ChallengeAttemptsCollection.FindOne(context.Background(), bson.M{"_id": id}).Decode(&attempt)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Attempt not found"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch attempt"})
+	}
+
+	resp := fiber.Map{
+		"attemptId": id.Hex(),
+		"status":    attempt.Status,
+	}
+	switch attempt.Status {
+	case models.AttemptStatusPending:
+	case models.AttemptStatusError:
+		resp["error"] = attempt.Error
+	default:
+		resp["attempt"] = attemptForCaller(c, attempt)
+	}
+	return c.JSON(resp)
+}
@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"qms-backend/db"
+	"qms-backend/models"
+	"qms-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RunChallengeCode executes code against a challenge's visible test cases only,
+// so students can try it before submitting. Nothing is recorded: the run isn't
+// an attempt and doesn't count towards the cooldown or the leaderboard.
+func RunChallengeCode(c *fiber.Ctx) error {
+	var req struct {
+		Code     string `json:"code"`
+		Language string `json:"language"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.Code == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Code is required"})
+	}
+	if req.Language == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Language is required"})
+	}
+
+	challengeID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge ID format"})
+	}
+	var challenge models.CodingChallenge
+	err = db.ChallengesCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": challengeID})).Decode(&challenge)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Challenge not found"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenge"})
+	}
+	if !challenge.IsPublished() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Challenge is not open for submissions"})
+	}
+
+	language := strings.ToLower(req.Language)
+	if !challenge.AllowsLanguage(language) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":            "Language not allowed for this challenge",
+			"allowedLanguages": challenge.SubmissionLanguages(),
+		})
+	}
+
+	// Group rules refer to the full test suite, so the sample run is scored without them
+	var samples []models.ChallengeTestCase
+	for _, tc := range challenge.TestCases {
+		if !tc.Hidden {
+			samples = append(samples, tc)
+		}
+	}
+	if len(samples) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "This challenge has no sample test cases to run against"})
+	}
+	challenge.TestCases = samples
+	challenge.TestGroups = nil
+
+	// Runs share the submission slots, so they can't be used to flood the
+	// executor; callers without a token are limited per client IP
+	guardKey := "ip:" + c.IP()
+	if userID := callerID(c); !userID.IsZero() {
+		guardKey = "user:" + userID.Hex()
+	}
+	if !challengeSubmissions.acquire(guardKey) {
+		return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "A previous run is still in progress, please wait for it to finish",
+		})
+	}
+	defer challengeSubmissions.release(guardKey)
+
+	// fasthttp doesn't report a client going away, so the execution isn't tied to
+	// the request; the service's own deadline bounds it
+	validationResult, err := services.NewCodeExecutionService().ExecuteCode(context.Background(), &challenge, language, req.Code)
+	if err != nil {
+		fmt.Println("Sample run failed:", err)
+		return executionErrorResponse(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"challengeId": challengeID,
+		"result":      resultForCaller(c, *validationResult),
+	})
+}
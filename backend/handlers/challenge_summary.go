@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// GetUserChallengeSummary returns how many times a user has attempted a
+// challenge, their best score, their latest status and whether they ever passed.
+// The attempts are summarised in the database rather than loaded. Students may
+// only request their own summary; staff may request anyone's.
+func GetUserChallengeSummary(c *fiber.Ctx) error {
+	challengeID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge ID"})
+	}
+	userID, err := primitive.ObjectIDFromHex(c.Params("userId"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid user ID"})
+	}
+	if !isStaffRequest(c) && userID != callerID(c) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Access denied: you can only view your own attempts"})
+	}
+
+	summary, err := userChallengeSummary(challengeID, userID)
+	if err != nil {
+		log.Printf("Failed to summarise attempts of user %s on challenge %s: %v", userID.Hex(), challengeID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch attempt summary"})
+	}
+	return c.JSON(summary)
+}
+
+// userChallengeSummary groups a user's attempts on a challenge. A user without
+// attempts gets an empty summary.
+func userChallengeSummary(challengeID, userID primitive.ObjectID) (models.ChallengeAttemptSummary, error) {
+	summary := models.ChallengeAttemptSummary{ChallengeID: challengeID, UserID: userID}
+
+	passedCond := bson.M{"$eq": bson.A{"$status", "Passed"}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: gradedAttempts(bson.M{"challengeId": challengeID, "userId": userID})}},
+		{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}}}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           nil,
+			"attempts":      bson.M{"$sum": 1},
+			"bestScore":     bson.M{"$max": "$result.percentageScore"},
+			"latestStatus":  bson.M{"$first": "$status"},
+			"lastAttemptAt": bson.M{"$first": "$createdAt"},
+			"passed":        bson.M{"$max": passedCond},
+		}}},
+	}
+
+	cursor, err := db.ChallengeAttemptsCollection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return summary, err
+	}
+	defer cursor.Close(context.Background())
+
+	var rows []struct {
+		Attempts      int       `bson:"attempts"`
+		BestScore     float64   `bson:"bestScore"`
+		LatestStatus  string    `bson:"latestStatus"`
+		LastAttemptAt time.Time `bson:"lastAttemptAt"`
+		Passed        bool      `bson:"passed"`
+	}
+	if err := cursor.All(context.Background(), &rows); err != nil {
+		return summary, err
+	}
+	if len(rows) == 0 {
+		return summary, nil
+	}
+
+	row := rows[0]
+	lastAttemptAt := row.LastAttemptAt.UTC()
+	summary.Attempts = row.Attempts
+	summary.BestScore = roundPercent(row.BestScore)
+	summary.LatestStatus = row.LatestStatus
+	summary.LastAttemptAt = &lastAttemptAt
+	summary.Passed = row.Passed
+	return summary, nil
+}
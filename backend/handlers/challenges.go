@@ -2,8 +2,16 @@ package handlers
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"math"
 	"net/http"
+	"path"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"time"
 
 	"qms-backend/db"
@@ -25,6 +33,231 @@ func min(a, b int) int {
 	return b
 }
 
+// containsString reports whether values contains target (case-insensitive)
+func containsString(values []string, target string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, target) {
+			return true
+		}
+	}
+	return false
+}
+
+// validateCompareModes rejects test cases with an unknown output comparison mode
+func validateCompareModes(testCases []models.ChallengeTestCase) error {
+	for i, tc := range testCases {
+		if !models.IsValidCompareMode(tc.CompareMode) {
+			return fmt.Errorf("Test case %d has invalid compareMode %q (use %q or %q)", i+1, tc.CompareMode, models.CompareModeExact, models.CompareModeNumber)
+		}
+	}
+	return nil
+}
+
+// validateTestGroups checks that group rules only reference groups used by test cases
+func validateTestGroups(testCases []models.ChallengeTestCase, groups []models.TestGroup) error {
+	used := make(map[string]bool)
+	for _, tc := range testCases {
+		if tc.Group != "" {
+			used[tc.Group] = true
+		}
+	}
+	for _, group := range groups {
+		if !used[group.Name] {
+			return fmt.Errorf("Test group %q has no test cases", group.Name)
+		}
+		for _, required := range group.Requires {
+			if required == group.Name || !used[required] {
+				return fmt.Errorf("Test group %q requires unknown group %q", group.Name, required)
+			}
+		}
+	}
+	return nil
+}
+
+// validateChecker requires a configured checker to have code and a language
+func validateChecker(checker *models.ChallengeChecker) error {
+	if checker == nil {
+		return nil
+	}
+	if strings.TrimSpace(checker.Code) == "" {
+		return fmt.Errorf("Checker code is required")
+	}
+	if strings.TrimSpace(checker.Language) == "" {
+		return fmt.Errorf("Checker language is required")
+	}
+	return nil
+}
+
+// validateSetupFiles catches setup file paths the executor would refuse, so a
+// misconfigured challenge fails on save rather than on every submission
+func validateSetupFiles(files []models.SetupFile) error {
+	seen := make(map[string]bool, len(files))
+	for _, file := range files {
+		clean := path.Clean(file.Path)
+		if file.Path == "" || path.IsAbs(file.Path) || strings.Contains(file.Path, `\`) ||
+			clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+			return fmt.Errorf("Invalid setup file path %q: must be relative and stay inside the working directory", file.Path)
+		}
+		if seen[clean] {
+			return fmt.Errorf("Duplicate setup file %q", file.Path)
+		}
+		seen[clean] = true
+	}
+	return nil
+}
+
+// Upper bounds for a challenge's limits; larger values are clamped to these
+const (
+	maxChallengeTimeoutSec    = 30
+	maxChallengeMemoryLimitMB = 1024
+)
+
+// ChallengeFieldError reports why one field of a challenge was rejected
+type ChallengeFieldError struct {
+	Field string `json:"field"`
+	Error string `json:"error"`
+}
+
+// sanitizeNewChallenge trims free-text fields, normalizes language names and
+// clamps the limits to their maximums
+func sanitizeNewChallenge(challenge *models.CodingChallenge) {
+	challenge.Title = strings.TrimSpace(challenge.Title)
+	challenge.Description = strings.TrimSpace(challenge.Description)
+	challenge.Category = strings.TrimSpace(challenge.Category)
+	challenge.Language = strings.ToLower(strings.TrimSpace(challenge.Language))
+	for i, language := range challenge.AllowedLanguages {
+		challenge.AllowedLanguages[i] = strings.ToLower(strings.TrimSpace(language))
+	}
+	if challenge.Checker != nil {
+		challenge.Checker.Language = strings.ToLower(strings.TrimSpace(challenge.Checker.Language))
+	}
+	if challenge.TimeoutSec > maxChallengeTimeoutSec {
+		challenge.TimeoutSec = maxChallengeTimeoutSec
+	}
+	if challenge.MemoryLimitMB > maxChallengeMemoryLimitMB {
+		challenge.MemoryLimitMB = maxChallengeMemoryLimitMB
+	}
+	for i := range challenge.TestCases {
+		if challenge.TestCases[i].TimeoutSec > maxChallengeTimeoutSec {
+			challenge.TestCases[i].TimeoutSec = maxChallengeTimeoutSec
+		}
+	}
+}
+
+// validateNewChallenge checks every field of a challenge being created and
+// reports all the problems at once. Languages are checked against the execution
+// engine; if it can't be reached that check is skipped, as on submission.
+func validateNewChallenge(challenge *models.CodingChallenge) []ChallengeFieldError {
+	var problems []ChallengeFieldError
+	add := func(field string, err error) {
+		if err != nil {
+			problems = append(problems, ChallengeFieldError{Field: field, Error: err.Error()})
+		}
+	}
+
+	if challenge.Title == "" {
+		add("title", fmt.Errorf("Title is required"))
+	}
+	if challenge.Description == "" {
+		add("description", fmt.Errorf("Description is required"))
+	}
+	if challenge.Language == "" {
+		add("language", fmt.Errorf("Language is required"))
+	}
+	if len(challenge.TestCases) == 0 {
+		add("testCases", fmt.Errorf("At least one test case is required"))
+	}
+	if challenge.TimeoutSec < 0 {
+		add("timeoutSec", fmt.Errorf("timeoutSec cannot be negative"))
+	}
+	if challenge.MemoryLimitMB < 0 {
+		add("memoryLimitMB", fmt.Errorf("memoryLimitMB cannot be negative"))
+	}
+	for i, tc := range challenge.TestCases {
+		if tc.TimeoutSec < 0 {
+			add(fmt.Sprintf("testCases[%d].timeoutSec", i), fmt.Errorf("Test case %d: timeoutSec cannot be negative", i+1))
+		}
+	}
+	add("testCases", validateCompareModes(challenge.TestCases))
+	add("testGroups", validateTestGroups(challenge.TestCases, challenge.TestGroups))
+	add("setupFiles", validateSetupFiles(challenge.SetupFiles))
+	add("checker", validateChecker(challenge.Checker))
+	if !models.IsValidSolutionReveal(challenge.SolutionReveal) {
+		add("solutionReveal", fmt.Errorf("Invalid solutionReveal (use never, after-pass or after-deadline)"))
+	}
+	if !models.IsValidSimilarityMode(challenge.SimilarityMode) {
+		add("similarityMode", fmt.Errorf("Invalid similarityMode (use fuzzy or exact)"))
+	}
+	if challenge.GracePercent < 0 || challenge.GracePercent > 100 {
+		add("gracePercent", fmt.Errorf("gracePercent must be between 0 and 100"))
+	}
+	if challenge.SubmissionCooldownSec < 0 {
+		add("submissionCooldownSec", fmt.Errorf("submissionCooldownSec cannot be negative"))
+	}
+
+	if challenge.Language == "" && len(challenge.AllowedLanguages) == 0 && challenge.Checker == nil {
+		return problems
+	}
+	supported, err := services.NewCodeExecutionService().CachedSupportedLanguages()
+	if err != nil {
+		fmt.Println("Could not fetch supported languages, skipping language validation:", err)
+		return problems
+	}
+	if challenge.Language != "" && !containsString(supported, challenge.Language) {
+		add("language", fmt.Errorf("Language %q is not supported (use one of: %s)", challenge.Language, strings.Join(supported, ", ")))
+	}
+	for i, language := range challenge.AllowedLanguages {
+		if !containsString(supported, language) {
+			add(fmt.Sprintf("allowedLanguages[%d]", i), fmt.Errorf("Language %q is not supported", language))
+		}
+	}
+	// The checker runs on the executor too, on every test case of every submission
+	if checker := challenge.Checker; checker != nil && checker.Language != "" && !containsString(supported, checker.Language) {
+		add("checker", fmt.Errorf("Checker language %q is not supported (use one of: %s)", checker.Language, strings.Join(supported, ", ")))
+	}
+	return problems
+}
+
+// GetChallengeLanguages lists the languages challenges can be written in. When
+// the executor can't be reached the default list is returned, marked as such.
+func GetChallengeLanguages(c *fiber.Ctx) error {
+	languages, err := services.NewCodeExecutionService().CachedSupportedLanguages()
+	if err != nil {
+		fmt.Println("Could not fetch supported languages, using defaults:", err)
+		return c.JSON(fiber.Map{
+			"languages": services.DefaultLanguages,
+			"fallback":  true,
+		})
+	}
+	return c.JSON(fiber.Map{"languages": languages})
+}
+
+// isStaffRequest reports whether the request was authenticated as an admin or instructor
+func isStaffRequest(c *fiber.Ctx) bool {
+	role, _ := c.Locals("userRole").(string)
+	return role == "admin" || role == "instructor"
+}
+
+// studentChallengeView returns the challenge as students should see it: visible
+// test cases carry their point weight, hidden test cases don't reveal it
+func studentChallengeView(challenge models.CodingChallenge) models.CodingChallenge {
+	// The reference solution is only released through GetChallengeSolution, and
+	// a checker can give away as much as the solution
+	challenge.SolutionCode = ""
+	challenge.Checker = nil
+	testCases := make([]models.ChallengeTestCase, len(challenge.TestCases))
+	for i, tc := range challenge.TestCases {
+		if tc.Hidden {
+			tc.PointsAvailable = 0
+		} else {
+			tc.PointsAvailable = tc.EffectivePoints()
+		}
+		testCases[i] = tc
+	}
+	challenge.TestCases = testCases
+	return challenge
+}
+
 // CreateChallenge creates a new coding challenge
 func CreateChallenge(c *fiber.Ctx) error {
 	challenge := new(models.CodingChallenge)
@@ -32,7 +265,19 @@ func CreateChallenge(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
+	sanitizeNewChallenge(challenge)
+	if problems := validateNewChallenge(challenge); len(problems) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Invalid challenge",
+			"fields": problems,
+		})
+	}
+
+	// New challenges start as drafts until their reference solution is validated
+	challenge.Status = models.ChallengeStatusDraft
+	challenge.OwnerID = callerID(c)
 	challenge.CreatedAt = time.Now()
+	challenge.UpdatedAt = challenge.CreatedAt
 	result, err := db.ChallengesCollection.InsertOne(context.Background(), challenge)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to create challenge"})
@@ -42,28 +287,137 @@ func CreateChallenge(c *fiber.Ctx) error {
 	return c.Status(http.StatusCreated).JSON(challenge)
 }
 
-// GetChallenges retrieves all coding challenges
+// challengeSortKeys are the values accepted by GetChallenges' sort parameter
+var challengeSortKeys = []string{"newest", "oldest", "updated", "title", "difficulty", "popularity"}
+
+// challengeSortStages returns the aggregation stages implementing a sort key.
+// Difficulty sorts Easy, Medium, Hard; popularity sorts by attempt count, most first;
+// updated sorts recently modified first, treating never-edited challenges as modified at creation.
+func challengeSortStages(key string) (mongo.Pipeline, bool) {
+	switch key {
+	case "newest":
+		return mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: -1}}}}}, true
+	case "oldest":
+		return mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "createdAt", Value: 1}}}}}, true
+	case "updated":
+		return mongo.Pipeline{
+			{{Key: "$addFields", Value: bson.M{"lastModified": bson.M{"$ifNull": bson.A{"$updatedAt", "$createdAt"}}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "lastModified", Value: -1}, {Key: "createdAt", Value: -1}}}},
+		}, true
+	case "title":
+		return mongo.Pipeline{{{Key: "$sort", Value: bson.D{{Key: "title", Value: 1}, {Key: "createdAt", Value: -1}}}}}, true
+	case "difficulty":
+		return mongo.Pipeline{
+			{{Key: "$addFields", Value: bson.M{"difficultyRank": bson.M{"$switch": bson.M{
+				"branches": bson.A{
+					bson.M{"case": bson.M{"$eq": bson.A{bson.M{"$toLower": "$difficulty"}, "easy"}}, "then": 1},
+					bson.M{"case": bson.M{"$eq": bson.A{bson.M{"$toLower": "$difficulty"}, "medium"}}, "then": 2},
+					bson.M{"case": bson.M{"$eq": bson.A{bson.M{"$toLower": "$difficulty"}, "hard"}}, "then": 3},
+				},
+				"default": 4,
+			}}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "difficultyRank", Value: 1}, {Key: "createdAt", Value: -1}}}},
+		}, true
+	case "popularity":
+		return mongo.Pipeline{
+			{{Key: "$lookup", Value: bson.M{
+				"from": db.ChallengeAttemptsCollection.Name(),
+				"let":  bson.M{"challengeId": "$_id"},
+				"pipeline": bson.A{
+					bson.M{"$match": bson.M{"$expr": bson.M{"$eq": bson.A{"$challengeId", "$$challengeId"}}}},
+					bson.M{"$count": "count"},
+				},
+				"as": "attemptCounts",
+			}}},
+			{{Key: "$addFields", Value: bson.M{"attemptCount": bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$attemptCounts.count", 0}}, 0}}}}},
+			{{Key: "$sort", Value: bson.D{{Key: "attemptCount", Value: -1}, {Key: "createdAt", Value: -1}}}},
+		}, true
+	}
+	return nil, false
+}
+
+// errorCodeIndexNotFound is MongoDB's error for a $text query without a text index
+const errorCodeIndexNotFound = 27
+
+// textSearchStages orders $text matches by relevance, newest first among equals
+func textSearchStages() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$addFields", Value: bson.M{"textScore": bson.M{"$meta": "textScore"}}}},
+		{{Key: "$sort", Value: bson.D{{Key: "textScore", Value: -1}, {Key: "createdAt", Value: -1}}}},
+	}
+}
+
+// regexSearchFilter matches challenges whose title or description contains search,
+// ignoring case. It stands in for $text when the text index is missing.
+func regexSearchFilter(search string) []bson.M {
+	pattern := primitive.Regex{Pattern: regexp.QuoteMeta(search), Options: "i"}
+	return []bson.M{
+		{"title": pattern},
+		{"description": pattern},
+	}
+}
+
+// GetChallenges retrieves one page of coding challenges. Supports difficulty and
+// category filters, a ?q= search over title and description, a sort parameter
+// (see challengeSortKeys) and ?page=/?limit=. Searches are ordered by relevance
+// unless a sort is given.
 func GetChallenges(c *fiber.Ctx) error {
-	var challenges []models.CodingChallenge
+	challenges := []models.CodingChallenge{}
 
 	// Query parameters for filtering
 	difficulty := c.Query("difficulty")
 	category := c.Query("category")
+	search := strings.TrimSpace(c.Query("q"))
 
 	// Build the filter
-	filter := bson.M{}
+	filter := notDeleted(ownerScope(c, bson.M{}))
 	if difficulty != "" {
 		filter["difficulty"] = difficulty
 	}
 	if category != "" {
 		filter["category"] = category
 	}
+	if !isStaffRequest(c) {
+		// Students only see published challenges (legacy challenges have no status)
+		filter["status"] = bson.M{"$nin": []string{models.ChallengeStatusDraft, models.ChallengeStatusArchived}}
+	}
+
+	// Sorting is validated against an allow-list; newest first by default
+	sortKey := c.Query("sort", "newest")
+	sortStages, ok := challengeSortStages(sortKey)
+	if !ok {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":       "Invalid sort key",
+			"allowedSort": challengeSortKeys,
+		})
+	}
+
+	page, err := parsePagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
-	// Set up options for sorting
-	findOptions := options.Find()
-	findOptions.SetSort(bson.D{{Key: "createdAt", Value: -1}})
+	if search != "" {
+		filter["$text"] = bson.M{"$search": search}
+	}
+	total, err := db.ChallengesCollection.CountDocuments(context.Background(), filter)
+	var serverErr mongo.ServerError
+	if search != "" && errors.As(err, &serverErr) && serverErr.HasErrorCode(errorCodeIndexNotFound) {
+		fmt.Println("No text index on challenges, falling back to a regex search")
+		delete(filter, "$text")
+		filter["$or"] = regexSearchFilter(search)
+		total, err = db.ChallengesCollection.CountDocuments(context.Background(), filter)
+	}
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count challenges"})
+	}
+	if _, textSearch := filter["$text"]; textSearch && c.Query("sort") == "" {
+		sortStages = textSearchStages()
+	}
 
-	cursor, err := db.ChallengesCollection.Find(context.Background(), filter, findOptions)
+	pipeline := append(mongo.Pipeline{{{Key: "$match", Value: filter}}}, sortStages...)
+	pipeline = append(pipeline, page.stages()...)
+	cursor, err := db.ChallengesCollection.Aggregate(context.Background(), pipeline)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenges"})
 	}
@@ -73,7 +427,42 @@ func GetChallenges(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse challenges"})
 	}
 
-	return c.JSON(challenges)
+	if !isStaffRequest(c) {
+		for i := range challenges {
+			challenges[i] = studentChallengeView(challenges[i])
+		}
+	}
+
+	return c.JSON(page.response(challenges, total))
+}
+
+// GetMyChallenges lists one page of the challenges the caller created, newest
+// first, drafts and archived ones included. Unlike GetChallenges it is scoped to
+// the caller for admins too.
+func GetMyChallenges(c *fiber.Ctx) error {
+	page, err := parsePagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	filter := notDeleted(bson.M{"ownerId": callerID(c)})
+	total, err := db.ChallengesCollection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count challenges"})
+	}
+
+	challenges := []models.CodingChallenge{}
+	opts := page.findOptions().SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "_id", Value: -1}})
+	cursor, err := db.ChallengesCollection.Find(context.Background(), filter, opts)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenges"})
+	}
+	defer cursor.Close(context.Background())
+
+	if err := cursor.All(context.Background(), &challenges); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse challenges"})
+	}
+	return c.JSON(page.response(challenges, total))
 }
 
 // GetChallenge retrieves a single coding challenge by ID
@@ -88,7 +477,7 @@ func GetChallenge(c *fiber.Ctx) error {
 	}
 
 	var challenge models.CodingChallenge
-	err = db.ChallengesCollection.FindOne(c.Context(), bson.M{"_id": id}).Decode(&challenge)
+	err = db.ChallengesCollection.FindOne(c.Context(), notDeleted(bson.M{"_id": id})).Decode(&challenge)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(http.StatusNotFound).JSON(fiber.Map{
@@ -104,26 +493,77 @@ func GetChallenge(c *fiber.Ctx) error {
 		})
 	}
 
+	if !isStaffRequest(c) {
+		if challenge.Status == models.ChallengeStatusDraft {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{
+				"success": false,
+				"message": "Challenge not found",
+				"error":   "No challenge found with the provided ID",
+			})
+		}
+		challenge = studentChallengeView(challenge)
+	}
+
 	return c.Status(200).JSON(challenge)
 }
 
-// UpdateChallenge updates a coding challenge
+// UpdateChallenge updates a coding challenge. Only the fields present in the
+// request body are changed; omitted fields (e.g. testCases) keep their stored values.
 func UpdateChallenge(c *fiber.Ctx) error {
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	challenge := new(models.CodingChallenge)
-	if err := c.BodyParser(challenge); err != nil {
+	var existing models.CodingChallenge
+	err = db.ChallengesCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&existing)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Challenge not found"})
+		}
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenge"})
+	}
+	if !canManage(c, existing.OwnerID) {
+		return forbidden(c)
+	}
+
+	challenge, err := mergeChallengeUpdate(existing, c.Body())
+	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	update := bson.M{
-		"$set": challenge,
+	// Identity, ownership, creation and deletion are never client controlled
+	challenge.ID = existing.ID
+	challenge.OwnerID = existing.OwnerID
+	challenge.CreatedAt = existing.CreatedAt
+	challenge.DeletedAt = existing.DeletedAt
+
+	// The merged challenge has to hold up as well as a new one would
+	sanitizeNewChallenge(&challenge)
+	if problems := validateNewChallenge(&challenge); len(problems) > 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":  "Invalid challenge",
+			"fields": problems,
+		})
 	}
 
-	result, err := db.ChallengesCollection.UpdateOne(context.Background(), bson.M{"_id": id}, update)
+	// Publishing has to go through PublishChallenge so the solution gets validated
+	if challenge.Status != existing.Status {
+		switch challenge.Status {
+		case models.ChallengeStatusPublished:
+			if !existing.IsPublished() {
+				return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Use the publish endpoint to publish a challenge"})
+			}
+		case models.ChallengeStatusDraft, models.ChallengeStatusArchived:
+		default:
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge status"})
+		}
+	}
+
+	// The merged challenge is stored whole, so a field cleared in the body (e.g.
+	// "checker": null) is removed rather than skipped by omitempty
+	challenge.UpdatedAt = time.Now()
+	result, err := db.ChallengesCollection.ReplaceOne(context.Background(), notDeleted(bson.M{"_id": id}), challenge)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update challenge"})
 	}
@@ -135,28 +575,209 @@ func UpdateChallenge(c *fiber.Ctx) error {
 	return c.JSON(challenge)
 }
 
-// DeleteChallenge deletes a coding challenge
+// mergeChallengeUpdate returns existing with each field whose JSON key is present
+// in body replaced by the body's value. The body is decoded into a zero challenge,
+// so a replaced list or checker never inherits values from the stored one.
+func mergeChallengeUpdate(existing models.CodingChallenge, body []byte) (models.CodingChallenge, error) {
+	var present map[string]json.RawMessage
+	if err := json.Unmarshal(body, &present); err != nil {
+		return existing, err
+	}
+	var update models.CodingChallenge
+	if err := json.Unmarshal(body, &update); err != nil {
+		return existing, err
+	}
+
+	merged := existing
+	target := reflect.ValueOf(&merged).Elem()
+	source := reflect.ValueOf(update)
+	for i := 0; i < source.NumField(); i++ {
+		key, _, _ := strings.Cut(source.Type().Field(i).Tag.Get("json"), ",")
+		if _, ok := present[key]; ok && key != "" && key != "-" {
+			target.Field(i).Set(source.Field(i))
+		}
+	}
+	return merged, nil
+}
+
+// DeleteChallenge soft-deletes a coding challenge, keeping it for the attempts
+// that reference it; see RestoreChallenge
 func DeleteChallenge(c *fiber.Ctx) error {
-	id, err := primitive.ObjectIDFromHex(c.Params("id"))
-	if err != nil {
-		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	challenge, err := findChallenge(c)
+	if challenge == nil {
+		return err
+	}
+	if !canManage(c, challenge.OwnerID) {
+		return forbidden(c)
 	}
 
-	result, err := db.ChallengesCollection.DeleteOne(context.Background(), bson.M{"_id": id})
+	now := time.Now().UTC()
+	result, err := db.ChallengesCollection.UpdateOne(context.Background(), notDeleted(bson.M{"_id": challenge.ID}),
+		bson.M{"$set": bson.M{"deletedAt": now, "updatedAt": now}})
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete challenge"})
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Challenge not found"})
 	}
 
 	return c.SendStatus(204)
 }
 
+// Reasons a reference solution can't be validated
+var (
+	errNoSolutionCode = errors.New("A reference solution is required")
+	errNoTestCases    = errors.New("At least one test case is required")
+)
+
+// referenceRunTimeout bounds a run of a challenge's reference solution
+const referenceRunTimeout = 2 * time.Minute
+
+// runReferenceSolution executes the challenge's SolutionCode against its test cases
+func runReferenceSolution(challenge *models.CodingChallenge) (*models.ValidationResult, error) {
+	if challenge.SolutionCode == "" {
+		return nil, errNoSolutionCode
+	}
+	if len(challenge.TestCases) == 0 {
+		return nil, errNoTestCases
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), referenceRunTimeout)
+	defer cancel()
+	executionService := services.NewCodeExecutionService()
+	return executionService.ExecuteCode(ctx, challenge, challenge.Language, challenge.SolutionCode)
+}
+
+// referenceSolutionError converts a runReferenceSolution error into a response
+func referenceSolutionError(c *fiber.Ctx, err error) error {
+	if err == errNoSolutionCode || err == errNoTestCases {
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{"error": err.Error()})
+	}
+	if errors.Is(err, context.DeadlineExceeded) {
+		return c.Status(http.StatusGatewayTimeout).JSON(fiber.Map{"error": "The reference solution didn't finish in time"})
+	}
+	var execErr *services.ExecutorError
+	if errors.As(err, &execErr) {
+		return executionErrorResponse(c, err)
+	}
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "Failed to validate reference solution",
+		"details": err.Error(),
+	})
+}
+
+// executionErrorResponse maps an ExecuteCode error to a response: submissions the
+// executor rejects are the caller's fault (400), executor failures are 503
+func executionErrorResponse(c *fiber.Ctx, err error) error {
+	var execErr *services.ExecutorError
+	if errors.As(err, &execErr) {
+		if execErr.IsClientError() {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+				"error":     "The code execution engine rejected the submission",
+				"details":   execErr.Body,
+				"requestId": execErr.RequestID,
+			})
+		}
+		return c.Status(http.StatusServiceUnavailable).JSON(fiber.Map{
+			"error":     "The code execution engine is unavailable, please try again later",
+			"requestId": execErr.RequestID,
+		})
+	}
+	if errors.Is(err, services.ErrIncompleteExecutorResponse) {
+		return c.Status(http.StatusBadGateway).JSON(fiber.Map{
+			"error":   "The code execution engine returned an incomplete result, please try again",
+			"details": err.Error(),
+		})
+	}
+	return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
+		"error":   "Code execution failed",
+		"details": err.Error(),
+	})
+}
+
+// findChallenge loads a challenge by the :id route parameter, writing the error
+// response itself when it can't
+func findChallenge(c *fiber.Ctx) (*models.CodingChallenge, error) {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return nil, c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var challenge models.CodingChallenge
+	err = db.ChallengesCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&challenge)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return nil, c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Challenge not found"})
+		}
+		return nil, c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenge"})
+	}
+	return &challenge, nil
+}
+
+// ValidateChallengeSolution runs the reference solution against the test cases
+// without changing the challenge, so instructors can check it before publishing
+func ValidateChallengeSolution(c *fiber.Ctx) error {
+	challenge, err := findChallenge(c)
+	if challenge == nil {
+		return err
+	}
+	if !canManage(c, challenge.OwnerID) {
+		return forbidden(c)
+	}
+
+	validationResult, err := runReferenceSolution(challenge)
+	if err != nil {
+		return referenceSolutionError(c, err)
+	}
+
+	return c.JSON(fiber.Map{
+		"challengeId": challenge.ID.Hex(),
+		"passed":      validationResult.Passed,
+		"result":      resultForCaller(c, *validationResult),
+	})
+}
+
+// PublishChallenge makes a draft challenge visible to students once its
+// reference solution passes every test case
+func PublishChallenge(c *fiber.Ctx) error {
+	challenge, err := findChallenge(c)
+	if challenge == nil {
+		return err
+	}
+	if !canManage(c, challenge.OwnerID) {
+		return forbidden(c)
+	}
+
+	validationResult, err := runReferenceSolution(challenge)
+	if err != nil {
+		return referenceSolutionError(c, err)
+	}
+	if !validationResult.Passed {
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":  "Reference solution does not pass all test cases",
+			"result": validationResult,
+		})
+	}
+
+	now := time.Now()
+	_, err = db.ChallengesCollection.UpdateOne(
+		context.Background(),
+		bson.M{"_id": challenge.ID},
+		bson.M{"$set": bson.M{"status": models.ChallengeStatusPublished, "updatedAt": now}},
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to publish challenge"})
+	}
+
+	challenge.Status = models.ChallengeStatusPublished
+	challenge.UpdatedAt = now
+	return c.JSON(challenge)
+}
+
 // SubmitChallengeAttempt handles a user's submission for a coding challenge
 func SubmitChallengeAttempt(c *fiber.Ctx) error {
-	// note: debug
+	// The raw body lets a string userId be converted below
 	var rawBody map[string]interface{}
 	if err := c.BodyParser(&rawBody); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
@@ -165,8 +786,6 @@ func SubmitChallengeAttempt(c *fiber.Ctx) error {
 		})
 	}
 
-	fmt.Printf("Received challenge submission body: %+v\n", rawBody)
-
 	// Now parse into the proper struct
 	attempt := new(models.ChallengeAttempt)
 	if err := c.BodyParser(attempt); err != nil {
@@ -198,6 +817,16 @@ func SubmitChallengeAttempt(c *fiber.Ctx) error {
 	}
 	attempt.ChallengeID = challengeID
 
+	// A signed-in caller always submits as themselves. Without a token the body's
+	// userId is only recorded against the attempt.
+	if signedIn := callerID(c); !signedIn.IsZero() {
+		if !attempt.UserID.IsZero() && attempt.UserID != signedIn {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You can only submit as yourself"})
+		}
+		attempt.UserID = signedIn
+		attempt.Authenticated = true
+	}
+
 	// Handle the userId - if it's empty or invalid, create a default ObjectID
 	if attempt.UserID.IsZero() {
 		// Check if we got a userId as string that we need to convert
@@ -218,7 +847,7 @@ func SubmitChallengeAttempt(c *fiber.Ctx) error {
 
 	// Validate the challenge ID
 	var challenge models.CodingChallenge
-	err = db.ChallengesCollection.FindOne(context.Background(), bson.M{"_id": challengeID}).Decode(&challenge)
+	err = db.ChallengesCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": challengeID})).Decode(&challenge)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
 			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Challenge not found"})
@@ -229,17 +858,86 @@ func SubmitChallengeAttempt(c *fiber.Ctx) error {
 		})
 	}
 
-	// Execute the code and get the validation result
+	// Drafts and archived challenges don't accept submissions
+	if !challenge.IsPublished() {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Challenge is not open for submissions"})
+	}
+
+	// Only accept languages the challenge allows
+	attempt.Language = strings.ToLower(attempt.Language)
+	if !challenge.AllowsLanguage(attempt.Language) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":            "Language not allowed for this challenge",
+			"allowedLanguages": challenge.SubmissionLanguages(),
+		})
+	}
+
 	executionService := services.NewCodeExecutionService()
+
+	// Make sure the execution engine can actually run the language
+	if supported, err := executionService.CachedSupportedLanguages(); err != nil {
+		fmt.Println("Could not fetch supported languages, continuing:", err)
+	} else if !containsString(supported, attempt.Language) {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error":              "Language is not supported by the code execution engine",
+			"supportedLanguages": supported,
+		})
+	}
+
+	// One execution at a time per user. A body-supplied user ID can be varied
+	// freely, so callers without a token are limited per client IP instead.
+	guardKey := "ip:" + c.IP()
+	if attempt.Authenticated {
+		guardKey = "user:" + attempt.UserID.Hex()
+	}
+	if !challengeSubmissions.acquire(guardKey) {
+		return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+			"error": "A previous submission is still running, please wait for it to finish",
+		})
+	}
+
+	// Enforce the minimum interval between submissions. It is keyed like the
+	// guard, so a body-supplied user ID can neither dodge a cooldown nor start
+	// someone else's, and it is only claimed once the slot is held. The claim is
+	// atomic, so parallel requests can't all slip through.
+	var cooldownUntil time.Time
+	if challenge.SubmissionCooldownSec > 0 {
+		cooldown := time.Duration(challenge.SubmissionCooldownSec) * time.Second
+		until, remaining, err := claimSubmissionCooldown(challengeID, guardKey, cooldown)
+		if err != nil {
+			challengeSubmissions.release(guardKey)
+			fmt.Println("Failed to check submission cooldown:", err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check submission cooldown"})
+		}
+		if remaining > 0 {
+			challengeSubmissions.release(guardKey)
+			retryAfter := int(math.Ceil(remaining.Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return c.Status(http.StatusTooManyRequests).JSON(fiber.Map{
+				"error":             "Please wait before submitting again",
+				"retryAfterSeconds": retryAfter,
+			})
+		}
+		cooldownUntil = until
+	}
+
+	// ?async=true answers 202 at once; the queued job holds the slot until it is graded
+	if c.QueryBool("async") {
+		return submitChallengeAttemptAsync(c, attempt, guardKey, cooldownUntil)
+	}
+	defer challengeSubmissions.release(guardKey)
+
+	// Execute the code and get the validation result
 	fmt.Println("Executing code for challenge:", challengeID.Hex())
 	fmt.Println("Code snippet:", attempt.Code[:min(100, len(attempt.Code))]+"...")
-	validationResult, err := executionService.ExecuteCode(&challenge, attempt.Code)
+	// fasthttp doesn't report a client going away, so the execution isn't tied to
+	// the request; the service's own deadline bounds it
+	validationResult, err := executionService.ExecuteCode(context.Background(), &challenge, attempt.Language, attempt.Code)
 	if err != nil {
 		fmt.Println("Code execution failed:", err)
-		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
-			"error":   "Code execution failed",
-			"details": err.Error(),
-		})
+		// The submission was never graded, so it doesn't count against the cooldown
+		releaseSubmissionCooldown(challengeID, guardKey, cooldownUntil)
+		return executionErrorResponse(c, err)
 	}
 
 	// Log validation results for debugging
@@ -266,6 +964,8 @@ func SubmitChallengeAttempt(c *fiber.Ctx) error {
 		attempt.Status = "Failed"
 	}
 
+	flagSolutionMatch(attempt, &challenge)
+
 	// Save the attempt to the database
 	result, err := db.ChallengeAttemptsCollection.InsertOne(context.Background(), attempt)
 	if err != nil {
@@ -276,7 +976,57 @@ func SubmitChallengeAttempt(c *fiber.Ctx) error {
 	}
 
 	attempt.ID = result.InsertedID.(primitive.ObjectID)
-	return c.Status(http.StatusCreated).JSON(attempt)
+	notifyLeaderboard(attempt.ChallengeID)
+	awardChallengePoints(attempt, &challenge)
+
+	// The stored attempt keeps full detail; the response is redacted for students
+	return c.Status(http.StatusCreated).JSON(attemptForCaller(c, *attempt))
+}
+
+// claimSubmissionCooldown starts a cooldown for key (the submission guard's key)
+// on the challenge and returns when it ends, unless one is still running, in
+// which case it returns the time left. A concurrent claim fails the upsert on
+// the unique key/challenge index and counts as running.
+func claimSubmissionCooldown(challengeID primitive.ObjectID, key string, cooldown time.Duration) (time.Time, time.Duration, error) {
+	// Mongo keeps milliseconds; truncating lets releaseSubmissionCooldown match the stored time
+	now := time.Now().UTC().Truncate(time.Millisecond)
+	until := now.Add(cooldown)
+	_, err := db.SubmitCooldownsCollection.UpdateOne(context.Background(),
+		bson.M{"key": key, "challengeId": challengeID, "nextAllowedAt": bson.M{"$lte": now}},
+		bson.M{"$set": bson.M{"nextAllowedAt": until}},
+		options.Update().SetUpsert(true),
+	)
+	if err == nil {
+		return until, 0, nil
+	}
+	if !mongo.IsDuplicateKeyError(err) {
+		return time.Time{}, 0, err
+	}
+
+	var running models.SubmissionCooldown
+	err = db.SubmitCooldownsCollection.FindOne(context.Background(),
+		bson.M{"key": key, "challengeId": challengeID}).Decode(&running)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return time.Time{}, 0, err
+	}
+	// A claim that lost a race still waits, even if the winner's record is gone
+	if remaining := time.Until(running.NextAllowedAt); remaining > time.Second {
+		return time.Time{}, remaining, nil
+	}
+	return time.Time{}, time.Second, nil
+}
+
+// releaseSubmissionCooldown gives back a cooldown claimed until the given time,
+// for a submission that couldn't be graded. A zero time means none was claimed.
+func releaseSubmissionCooldown(challengeID primitive.ObjectID, key string, until time.Time) {
+	if until.IsZero() {
+		return
+	}
+	_, err := db.SubmitCooldownsCollection.DeleteOne(context.Background(),
+		bson.M{"key": key, "challengeId": challengeID, "nextAllowedAt": until})
+	if err != nil {
+		fmt.Printf("Failed to release submission cooldown for %s on challenge %s: %v\n", key, challengeID.Hex(), err)
+	}
 }
 
 // GetChallengeAttempts retrieves all attempts for a specific challenge
@@ -302,7 +1052,7 @@ func GetChallengeAttempts(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse challenge attempts"})
 	}
 
-	return c.JSON(attempts)
+	return c.JSON(attemptsForCaller(c, attempts))
 }
 
 // GetUserChallengeAttempts retrieves all attempts by a specific user
@@ -328,7 +1078,7 @@ func GetUserChallengeAttempts(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse user challenge attempts"})
 	}
 
-	return c.JSON(attempts)
+	return c.JSON(attemptsForCaller(c, attempts))
 }
 
 // GetChallengeResults handles fetching all challenge results
@@ -530,3 +1280,63 @@ func GetChallengeResultsByChallenge(c *fiber.Ctx) error {
 
 	return c.JSON(results)
 }
+
+// GetChallengeSolution returns a challenge's reference solution when its reveal
+// policy allows it for the caller. Staff who can manage the challenge always can.
+func GetChallengeSolution(c *fiber.Ctx) error {
+	challenge, err := findChallenge(c)
+	if challenge == nil {
+		return err
+	}
+
+	if !canManage(c, challenge.OwnerID) {
+		if !challenge.IsPublished() {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Challenge not found"})
+		}
+
+		switch challenge.SolutionReveal {
+		case models.SolutionRevealAfterPass:
+			passed, err := db.ChallengeAttemptsCollection.CountDocuments(context.Background(), bson.M{
+				"challengeId": challenge.ID,
+				"userId":      callerID(c),
+				"status":      "Passed",
+			})
+			if err != nil {
+				fmt.Println("Failed to check attempts for solution reveal:", err)
+				return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch solution"})
+			}
+			if passed == 0 {
+				return c.Status(http.StatusForbidden).JSON(fiber.Map{
+					"error":          "The solution is available after you pass this challenge",
+					"solutionReveal": challenge.SolutionReveal,
+				})
+			}
+		case models.SolutionRevealAfterDeadline:
+			if challenge.EndTime == nil || time.Now().Before(*challenge.EndTime) {
+				response := fiber.Map{
+					"error":          "The solution is available after the challenge deadline",
+					"solutionReveal": challenge.SolutionReveal,
+				}
+				if challenge.EndTime != nil {
+					response["availableAt"] = challenge.EndTime.UTC()
+				}
+				return c.Status(http.StatusForbidden).JSON(response)
+			}
+		default:
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{
+				"error":          "The solution for this challenge is not released",
+				"solutionReveal": models.SolutionRevealNever,
+			})
+		}
+	}
+
+	if challenge.SolutionCode == "" {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "This challenge has no reference solution"})
+	}
+
+	return c.JSON(fiber.Map{
+		"challengeId":  challenge.ID,
+		"language":     challenge.Language,
+		"solutionCode": challenge.SolutionCode,
+	})
+}
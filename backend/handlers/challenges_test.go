@@ -0,0 +1,80 @@
+package handlers
+
+import (
+	"testing"
+	"time"
+
+	"qms-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func storedChallenge() models.CodingChallenge {
+	return models.CodingChallenge{
+		ID:       primitive.NewObjectID(),
+		Title:    "Two Sum",
+		Language: "python",
+		TestCases: []models.ChallengeTestCase{
+			{Input: "1 2", ExpectedOutput: "3", Hidden: true},
+			{Input: "2 2", ExpectedOutput: "4"},
+		},
+		Checker:   &models.ChallengeChecker{Language: "python", Code: "exit(0)"},
+		CreatedAt: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func TestMergeChallengeUpdateKeepsOmittedFields(t *testing.T) {
+	existing := storedChallenge()
+
+	merged, err := mergeChallengeUpdate(existing, []byte(`{"title": "Three Sum"}`))
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if merged.Title != "Three Sum" {
+		t.Errorf("title = %q, want %q", merged.Title, "Three Sum")
+	}
+	if len(merged.TestCases) != 2 || merged.TestCases[0].ExpectedOutput != "3" {
+		t.Errorf("test cases were not kept: %+v", merged.TestCases)
+	}
+	if merged.Checker == nil || merged.Checker.Code != "exit(0)" {
+		t.Errorf("checker was not kept: %+v", merged.Checker)
+	}
+	if !merged.CreatedAt.Equal(existing.CreatedAt) {
+		t.Errorf("createdAt = %v, want %v", merged.CreatedAt, existing.CreatedAt)
+	}
+}
+
+func TestMergeChallengeUpdateReplacesListsWholesale(t *testing.T) {
+	existing := storedChallenge()
+
+	merged, err := mergeChallengeUpdate(existing, []byte(`{"testCases": [{"input": "5 5", "expectedOutput": "10"}]}`))
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if len(merged.TestCases) != 1 {
+		t.Fatalf("got %d test cases, want 1", len(merged.TestCases))
+	}
+	// The new test case doesn't say it's hidden, so it mustn't inherit that from the stored one
+	if merged.TestCases[0].Hidden {
+		t.Error("replacement test case inherited hidden from the stored test case")
+	}
+	if !existing.TestCases[0].Hidden || existing.TestCases[0].Input != "1 2" {
+		t.Errorf("stored challenge was modified: %+v", existing.TestCases[0])
+	}
+}
+
+func TestMergeChallengeUpdateClearsExplicitNull(t *testing.T) {
+	merged, err := mergeChallengeUpdate(storedChallenge(), []byte(`{"checker": null}`))
+	if err != nil {
+		t.Fatalf("merge failed: %v", err)
+	}
+	if merged.Checker != nil {
+		t.Errorf("checker = %+v, want nil", merged.Checker)
+	}
+}
+
+func TestMergeChallengeUpdateRejectsInvalidJSON(t *testing.T) {
+	if _, err := mergeChallengeUpdate(storedChallenge(), []byte(`{"title": `)); err == nil {
+		t.Error("expected an error for a malformed body")
+	}
+}
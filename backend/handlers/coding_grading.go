@@ -0,0 +1,210 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+	"qms-backend/services"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// defaultCodingLanguage is used when neither the answer nor the question names a language
+const defaultCodingLanguage = "python"
+
+// pendingGradingBatch caps how many submissions one retry cycle works through
+const pendingGradingBatch = 50
+
+// executorUnavailable reports whether err means the executor couldn't run the
+// code right now: it couldn't be reached, timed out, failed with a 5xx, or sent
+// back a response that was cut short. Anything else is an answer about the code
+// itself, and only the former is worth retrying.
+func executorUnavailable(err error) bool {
+	var execErr *services.ExecutorError
+	if errors.As(err, &execErr) {
+		return execErr.StatusCode >= 500
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, services.ErrIncompleteExecutorResponse) {
+		return true
+	}
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// gradeCodingAnswer runs a coding answer against the question's test cases and
+// awards the question's points in proportion to the share of points scored
+func gradeCodingAnswer(question models.Question, answer models.Answer) (models.QuestionResult, error) {
+	result := models.QuestionResult{QuestionID: answer.QuestionID}
+	if len(question.TestCases) == 0 {
+		return result, nil
+	}
+
+	challenge := &models.CodingChallenge{ID: question.ID, Title: question.Content}
+	for _, tc := range question.TestCases {
+		challenge.TestCases = append(challenge.TestCases, models.ChallengeTestCase{
+			Input:          tc.Input,
+			ExpectedOutput: tc.Output,
+			Hidden:         tc.Hidden,
+		})
+	}
+	language := answer.Language
+	if language == "" {
+		language = question.Language
+	}
+	if language == "" {
+		language = defaultCodingLanguage
+	}
+
+	validation, err := services.NewCodeExecutionService().ExecuteCode(context.Background(), challenge, language, answer.Answer)
+	if err != nil {
+		if executorUnavailable(err) {
+			return result, err
+		}
+		// The executor rejected the code itself (e.g. an unsupported language): that's a 0
+		log.Printf("Coding answer to question %s rejected by executor: %v", answer.QuestionID, err)
+		return result, nil
+	}
+	result.Correct = validation.Passed
+	result.AwardedPoints = roundPoints(float64(question.Points) * validation.PercentageScore / 100)
+	return result, nil
+}
+
+// gradePendingAnswers executes every pending coding answer in submission and
+// updates its grading status. It stops at the first executor failure, leaving
+// the rest pending for the retry worker, and reports how many are still pending.
+func gradePendingAnswers(submission *models.TestSubmission, questions map[string]models.Question) int {
+	answers := make(map[string]models.Answer, len(submission.Answers))
+	for _, answer := range submission.Answers {
+		answers[answer.QuestionID] = answer
+	}
+
+	pending := 0
+	executorDown := false
+	for i, result := range submission.QuestionResults {
+		if result.Status != models.GradingPending {
+			continue
+		}
+		question, ok := questions[result.QuestionID]
+		if executorDown || !ok {
+			pending++
+			continue
+		}
+		graded, err := gradeCodingAnswer(question, answers[result.QuestionID])
+		if err != nil {
+			log.Printf("Executor unavailable, leaving question %s of submission %s pending: %v", result.QuestionID, submission.ID, err)
+			executorDown = true
+			pending++
+			continue
+		}
+		submission.QuestionResults[i] = graded
+	}
+
+	submission.GradingStatus = models.GradingComplete
+	if pending > 0 {
+		submission.GradingStatus = models.GradingPending
+	}
+	return pending
+}
+
+// StartPendingGradingWorker periodically retries coding answers that couldn't be
+// executed at submission time
+func StartPendingGradingWorker(ctx context.Context, interval time.Duration) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		fmt.Printf("Starting pending grading worker (retry every %s)...\n", interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				fmt.Println("Stopping pending grading worker")
+				return
+			case <-ticker.C:
+				retryPendingGrading()
+			}
+		}
+	}()
+}
+
+// retryPendingGrading regrades the oldest pending submissions and stores the
+// results. Totals are derived from QuestionResults, so they update with them.
+func retryPendingGrading() {
+	cursor, err := db.AttemptCollection.Find(context.Background(),
+		bson.M{"gradingStatus": models.GradingPending},
+		options.Find().SetSort(bson.D{{Key: "submittedAt", Value: 1}}).SetLimit(pendingGradingBatch))
+	if err != nil {
+		log.Printf("Failed to fetch submissions pending grading: %v", err)
+		return
+	}
+	var submissions []models.TestSubmission
+	if err := cursor.All(context.Background(), &submissions); err != nil {
+		log.Printf("Failed to decode submissions pending grading: %v", err)
+		return
+	}
+
+	for i := range submissions {
+		submission := &submissions[i]
+		questions, err := fetchQuestionsForAnswers(submission.Answers)
+		if err != nil {
+			log.Printf("Failed to fetch questions for pending submission %s: %v", submission.ID, err)
+			continue
+		}
+		// Questions deleted since submission can never be graded; score them 0
+		for j, result := range submission.QuestionResults {
+			if _, ok := questions[result.QuestionID]; !ok && result.Status == models.GradingPending {
+				submission.QuestionResults[j] = models.QuestionResult{QuestionID: result.QuestionID}
+			}
+		}
+
+		remaining := gradePendingAnswers(submission, questions)
+		id, err := primitive.ObjectIDFromHex(submission.ID)
+		if err != nil {
+			continue
+		}
+
+		// The stored score includes the newly graded answers
+		var test models.TestBSON
+		if testID, err := primitive.ObjectIDFromHex(submission.TestID); err == nil {
+			err = db.TestsCollection.FindOne(context.Background(), bson.M{"_id": testID}).Decode(&test)
+			if err != nil && err != mongo.ErrNoDocuments {
+				log.Printf("Failed to fetch test of pending submission %s: %v", submission.ID, err)
+				continue
+			}
+		}
+		scoreSubmission(submission, questions, test)
+
+		_, err = db.AttemptCollection.UpdateOne(context.Background(),
+			bson.M{"_id": id, "gradingStatus": models.GradingPending},
+			bson.M{"$set": bson.M{
+				"questionResults": submission.QuestionResults,
+				"gradingStatus":   submission.GradingStatus,
+				"pointsScored":    submission.PointsScored,
+				"totalPoints":     submission.TotalPoints,
+				"percentageScore": submission.PercentageScore,
+				"status":          submission.Status,
+			}})
+		if err != nil {
+			log.Printf("Failed to store regraded submission %s: %v", submission.ID, err)
+			continue
+		}
+		if remaining > 0 {
+			// Still waiting on the executor; later submissions get their own try
+			continue
+		}
+		log.Printf("Finished grading pending submission %s", submission.ID)
+	}
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"qms-backend/services"
+)
+
+// clientTimeoutError returns the error an http.Client gives when its Timeout
+// runs out before the server answers
+func clientTimeoutError(t *testing.T) error {
+	t.Helper()
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	client := &http.Client{Timeout: 10 * time.Millisecond}
+	_, err := client.Get(server.URL)
+	if err == nil {
+		t.Fatal("expected the request to time out")
+	}
+	return err
+}
+
+func TestExecutorUnavailable(t *testing.T) {
+	decodeErr := json.Unmarshal([]byte(`{"validation": `), &struct{}{})
+	if decodeErr == nil {
+		t.Fatal("expected a decode error")
+	}
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"5xx from the executor", &services.ExecutorError{StatusCode: http.StatusServiceUnavailable}, true},
+		{"500 from the executor", &services.ExecutorError{StatusCode: http.StatusInternalServerError}, true},
+		{"4xx from the executor", &services.ExecutorError{StatusCode: http.StatusBadRequest}, false},
+		{"connection refused", fmt.Errorf("error sending execution request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"http client timeout", fmt.Errorf("error sending execution request: %w", clientTimeoutError(t)), true},
+		{"context deadline", fmt.Errorf("error sending execution request: %w", context.DeadlineExceeded), true},
+		{"incomplete response", fmt.Errorf("%w: validation summary missing", services.ErrIncompleteExecutorResponse), true},
+		{"undecodable response", fmt.Errorf("%w: error parsing execution response: %w", services.ErrIncompleteExecutorResponse, decodeErr), true},
+		{"anything else", errors.New("unsupported language"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := executorUnavailable(tt.err); got != tt.want {
+				t.Errorf("executorUnavailable(%v) = %v, want %v", tt.err, got, tt.want)
+			}
+		})
+	}
+}
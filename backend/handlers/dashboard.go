@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// studentTestScope limits a test query to tests open to everyone or assigned to the student
+func studentTestScope(studentID string) bson.M {
+	return bson.M{
+		"$or": []bson.M{
+			{"allowedStudents": nil},
+			{"allowedStudents": bson.M{"$size": 0}},
+			{"allowedStudents": studentID},
+		},
+	}
+}
+
+// fetchHydratedTests loads the tests matching filter with full question details,
+// skipping any test that fails to hydrate
+func fetchHydratedTests(filter bson.M) ([]models.Test, error) {
+	cursor, err := db.TestsCollection.Find(context.Background(), notDeleted(filter))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var testsBSON []models.TestBSON
+	if err := cursor.All(context.Background(), &testsBSON); err != nil {
+		return nil, err
+	}
+
+	tests := []models.Test{}
+	for _, testBSON := range testsBSON {
+		test, err := hydrateTest(testBSON)
+		if err != nil {
+			log.Printf("Failed to hydrate test %s: %v", testBSON.ID.Hex(), err)
+			continue
+		}
+		tests = append(tests, test)
+	}
+	return tests, nil
+}
+
+// GetDashboard returns everything the frontend needs right after login in one
+// request: the user's profile, their active and scheduled tests, and the
+// challenges available to them. Students only see tests assigned to them and
+// published challenges; admins and instructors see everything.
+func GetDashboard(c *fiber.Ctx) error {
+	userID, _ := c.Locals("userId").(string)
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Invalid user in token"})
+	}
+
+	var user models.AuthUser
+	err = db.UsersCollection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&user)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "User not found"})
+		}
+		log.Printf("Failed to fetch user %s for dashboard: %v", userID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch user"})
+	}
+
+	staff := isStaffRequest(c)
+	now := time.Now().UTC()
+
+	activeFilter := bson.M{
+		"startTime": bson.M{"$lte": now},
+		"endTime":   bson.M{"$gt": now},
+	}
+	scheduledFilter := bson.M{
+		"startTime": bson.M{"$gt": now},
+	}
+	if !staff {
+		activeFilter = bson.M{"$and": []bson.M{activeFilter, studentTestScope(userID)}}
+		scheduledFilter = bson.M{"$and": []bson.M{scheduledFilter, studentTestScope(userID)}}
+	}
+
+	activeTests, err := fetchHydratedTests(activeFilter)
+	if err != nil {
+		log.Printf("Failed to fetch active tests for dashboard: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch active tests"})
+	}
+	scheduledTests, err := fetchHydratedTests(scheduledFilter)
+	if err != nil {
+		log.Printf("Failed to fetch scheduled tests for dashboard: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch scheduled tests"})
+	}
+	if !staff {
+		for i := range activeTests {
+			activeTests[i] = testWithoutAnswerKeys(activeTests[i])
+		}
+		for i := range scheduledTests {
+			scheduledTests[i] = testWithoutAnswerKeys(scheduledTests[i])
+		}
+	}
+
+	challengeFilter := notDeleted(bson.M{})
+	if !staff {
+		challengeFilter["status"] = bson.M{"$nin": []string{models.ChallengeStatusDraft, models.ChallengeStatusArchived}}
+	}
+	cursor, err := db.ChallengesCollection.Find(
+		context.Background(),
+		challengeFilter,
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: -1}}),
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenges"})
+	}
+	defer cursor.Close(context.Background())
+
+	challenges := []models.CodingChallenge{}
+	if err := cursor.All(context.Background(), &challenges); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse challenges"})
+	}
+	if !staff {
+		for i := range challenges {
+			challenges[i] = studentChallengeView(challenges[i])
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"user": fiber.Map{
+			"id":        user.ID,
+			"email":     user.Email,
+			"firstName": user.FirstName,
+			"lastName":  user.LastName,
+			"role":      user.Role,
+		},
+		"activeTests":    activeTests,
+		"scheduledTests": scheduledTests,
+		"challenges":     challenges,
+	})
+}
@@ -0,0 +1,266 @@
+package handlers
+
+import (
+	"context"
+	"strconv"
+	"strings"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// gradeAnswer scores a single answer against its question and reports the points
+// awarded and whether the answer is correct. This is the one place answer
+// correctness is decided, so submission-time results and recomputed results agree.
+func gradeAnswer(question models.Question, answer string) (float64, bool) {
+	switch question.Type {
+	case "mcq":
+		selectedIndex, err := strconv.ParseInt(answer, 10, 64)
+		correctIndex, _ := correctOptionIndex(question)
+		if err == nil && int(selectedIndex) == correctIndex {
+			return float64(question.Points), true
+		}
+	case "multi_mcq":
+		return gradeMultiSelect(question, answer)
+	case "subjective":
+		if _, ok := matchAcceptedAnswer(question, answer); ok {
+			return float64(question.Points), true
+		}
+	case "short_answer":
+		// The correct answer counts as a case-insensitive variant alongside the accepted ones
+		if _, ok := matchAcceptedAnswer(question, answer); ok {
+			return float64(question.Points), true
+		}
+		if (models.AcceptedAnswer{Answer: question.CorrectAnswer}).Matches(answer) {
+			return float64(question.Points), true
+		}
+	}
+	return 0, false
+}
+
+// gradeMultiSelect gives partial credit on a multi-select question. answer is a
+// comma-separated list of option indices; each correct option selected earns an
+// equal share of the points and each wrong one takes a share away, floored at
+// zero. Only selecting exactly the correct options counts as correct.
+func gradeMultiSelect(question models.Question, answer string) (float64, bool) {
+	correct := make(map[int]bool, len(question.CorrectOptions))
+	for _, index := range question.CorrectOptions {
+		correct[index] = true
+	}
+	if len(correct) == 0 {
+		return 0, false
+	}
+
+	hits, misses := 0, 0
+	for index := range selectedOptions(answer) {
+		if correct[index] {
+			hits++
+		} else {
+			misses++
+		}
+	}
+	credit := float64(hits-misses) / float64(len(correct))
+	if credit <= 0 {
+		return 0, false
+	}
+	return credit * float64(question.Points), hits == len(correct) && misses == 0
+}
+
+// selectedOptions parses a comma-separated list of option indices. Repeats count
+// once and entries that aren't indices are ignored.
+func selectedOptions(answer string) map[int]bool {
+	selected := make(map[int]bool)
+	for _, part := range strings.Split(answer, ",") {
+		if index, err := strconv.Atoi(strings.TrimSpace(part)); err == nil && index >= 0 {
+			selected[index] = true
+		}
+	}
+	return selected
+}
+
+// correctOptionIndex returns the index of an MCQ question's correct option.
+// Older questions store the answer in CorrectAnswer, either as the option's text
+// or as its index; text is matched first, exactly and then ignoring case and
+// surrounding space. ambiguous reports that the text matched several options,
+// in which case CorrectOption breaks the tie if it's one of them.
+func correctOptionIndex(question models.Question) (index int, ambiguous bool) {
+	if question.CorrectAnswer == "" || len(question.Options) == 0 {
+		return question.CorrectOption, false
+	}
+
+	matches := optionsMatching(question.Options, func(option string) bool {
+		return option == question.CorrectAnswer
+	})
+	if len(matches) == 0 {
+		expected := strings.TrimSpace(question.CorrectAnswer)
+		matches = optionsMatching(question.Options, func(option string) bool {
+			return strings.EqualFold(strings.TrimSpace(option), expected)
+		})
+	}
+	switch {
+	case len(matches) == 1:
+		return matches[0], false
+	case len(matches) > 1:
+		for _, match := range matches {
+			if match == question.CorrectOption {
+				return match, true
+			}
+		}
+		return matches[0], true
+	}
+
+	if i, err := strconv.Atoi(strings.TrimSpace(question.CorrectAnswer)); err == nil && i >= 0 && i < len(question.Options) {
+		return i, false
+	}
+	return question.CorrectOption, false
+}
+
+func optionsMatching(options []string, match func(string) bool) []int {
+	var indexes []int
+	for i, option := range options {
+		if match(option) {
+			indexes = append(indexes, i)
+		}
+	}
+	return indexes
+}
+
+// matchAcceptedAnswer returns the first accepted short-answer variant that answer
+// matches. Questions without accepted answers are left for manual review.
+func matchAcceptedAnswer(question models.Question, answer string) (models.AcceptedAnswer, bool) {
+	for _, variant := range question.AcceptedAnswers {
+		if variant.Matches(answer) {
+			return variant, true
+		}
+	}
+	return models.AcceptedAnswer{}, false
+}
+
+// gradeAnswers grades every answer whose question is in questions (keyed by hex ID).
+// Answers referencing unknown questions are skipped. Coding answers need the
+// executor, so they are marked pending here and graded by gradePendingAnswers.
+func gradeAnswers(answers []models.Answer, questions map[string]models.Question) []models.QuestionResult {
+	results := make([]models.QuestionResult, 0, len(answers))
+	for _, answer := range answers {
+		question, ok := questions[answer.QuestionID]
+		if !ok {
+			continue
+		}
+		awarded, correct := gradeAnswer(question, answer.Answer)
+		result := models.QuestionResult{
+			QuestionID:    answer.QuestionID,
+			AwardedPoints: awarded,
+			Correct:       correct,
+		}
+		if question.Type == "subjective" {
+			if variant, ok := matchAcceptedAnswer(question, answer.Answer); ok {
+				result.MatchedAnswer = variant.Answer
+			}
+		}
+		if question.Type == "coding" {
+			result.Status = models.GradingPending
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+// scoreSubmission stores a graded submission's score. It runs again whenever
+// pending coding answers are graded.
+func scoreSubmission(submission *models.TestSubmission, questions map[string]models.Question, test models.TestBSON) {
+	scored, total, pending := submissionScore(*submission, questions)
+	submission.PointsScored = roundPoints(scored)
+	submission.TotalPoints = total
+	submission.PercentageScore = percentOf(scored, float64(total))
+	submission.Status = submissionStatus(scored, total, test.PassMark(), pending)
+}
+
+// submissionScore totals the points of the answered questions found in questions,
+// and counts the coding answers still waiting to be executed
+func submissionScore(attempt models.TestSubmission, questions map[string]models.Question) (scored float64, total int, pending int) {
+	for _, answer := range attempt.Answers {
+		question, ok := questions[answer.QuestionID]
+		if !ok {
+			continue
+		}
+		total += question.Points
+		awarded, isPending := submissionAward(attempt, question, answer)
+		if isPending {
+			pending++
+		}
+		scored += awarded
+	}
+	return scored, total, pending
+}
+
+// submissionStatus is Passed at or above the pass mark, Failed below it, and
+// Pending until every coding answer has been executed. The pass mark is compared
+// with the exact share of points, so a score just under it can't round up to a pass.
+func submissionStatus(scored float64, total int, passMark float64, pending int) string {
+	percentage := 0.0
+	if total > 0 {
+		percentage = scored / float64(total) * 100
+	}
+	switch {
+	case pending > 0:
+		return "Pending"
+	case percentage >= passMark:
+		return "Passed"
+	case percentage > 0:
+		return "Failed"
+	}
+	return "Submitted"
+}
+
+// pendingResults counts the question results still waiting to be graded
+func pendingResults(results []models.QuestionResult) int {
+	pending := 0
+	for _, result := range results {
+		if result.Status == models.GradingPending {
+			pending++
+		}
+	}
+	return pending
+}
+
+// fetchQuestionsForAnswers loads the questions referenced by answers in one query,
+// keyed by hex ID. Malformed question IDs are ignored.
+func fetchQuestionsForAnswers(answers []models.Answer) (map[string]models.Question, error) {
+	questionIDs := make([]primitive.ObjectID, 0, len(answers))
+	for _, answer := range answers {
+		if objID, err := primitive.ObjectIDFromHex(answer.QuestionID); err == nil {
+			questionIDs = append(questionIDs, objID)
+		}
+	}
+
+	questions := make(map[string]models.Question)
+	if len(questionIDs) == 0 {
+		return questions, nil
+	}
+
+	cursor, err := db.QuestionsCollection.Find(context.Background(), bson.M{"_id": bson.M{"$in": questionIDs}})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var fetched []models.Question
+	if err := cursor.All(context.Background(), &fetched); err != nil {
+		return nil, err
+	}
+	for _, question := range fetched {
+		questions[question.ID.Hex()] = question
+	}
+	return questions, nil
+}
+
+// countTestQuestions returns how many of a test's referenced questions still exist
+func countTestQuestions(testBSON models.TestBSON) (int64, error) {
+	if len(testBSON.Questions) == 0 {
+		return 0, nil
+	}
+	return db.QuestionsCollection.CountDocuments(context.Background(), bson.M{"_id": bson.M{"$in": testBSON.Questions}})
+}
@@ -0,0 +1,39 @@
+package handlers
+
+import (
+	"testing"
+
+	"qms-backend/models"
+)
+
+func TestGradeAnswerShortAnswerUsesAcceptedAnswers(t *testing.T) {
+	question := models.Question{
+		Type:          "short_answer",
+		Points:        5,
+		CorrectAnswer: "Paris",
+		AcceptedAnswers: []models.AcceptedAnswer{
+			{Answer: "paris, france"},
+			{Answer: "Île-de-France", MatchMode: models.AnswerMatchContains},
+		},
+	}
+
+	tests := []struct {
+		answer  string
+		correct bool
+	}{
+		{" paris ", true},
+		{"Paris, France", true},
+		{"the Île-de-France region", true},
+		{"Lyon", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		points, correct := gradeAnswer(question, tt.answer)
+		if correct != tt.correct {
+			t.Errorf("answer %q: correct = %v, want %v", tt.answer, correct, tt.correct)
+		}
+		if correct && points != 5 {
+			t.Errorf("answer %q: points = %v, want 5", tt.answer, points)
+		}
+	}
+}
@@ -1,6 +1,7 @@
 package handlers
 
 import (
+	"net/http"
 	"time"
 
 	"qms-backend/services"
@@ -8,6 +9,10 @@ import (
 	"github.com/gofiber/fiber/v2"
 )
 
+// HealthCheck reports the API's dependencies for humans; orchestrators should use
+// Liveness and Readiness instead. The status is "unhealthy" (503)
+// when the database is down and "degraded" when only the code execution engine
+// is, since everything but challenges still works then.
 func HealthCheck(c *fiber.Ctx) error {
 	// Get real-time status for database
 	dbStatus, dbErr := services.CheckDatabaseHealth()
@@ -21,13 +26,67 @@ func HealthCheck(c *fiber.Ctx) error {
 		apiStatus = "error: " + apiErr.Error()
 	}
 
-	return c.JSON(fiber.Map{
-		"status":    "healthy",
+	// Get real-time status for the code execution engine
+	executorStatus, executorErr := services.CheckExecutorHealth()
+	if executorErr != nil {
+		executorStatus = "error: " + executorErr.Error()
+	}
+
+	status, code := "healthy", http.StatusOK
+	if dbStatus != "connected" {
+		status, code = "unhealthy", http.StatusServiceUnavailable
+	} else if executorStatus != "connected" {
+		status = "degraded"
+	}
+
+	resp := fiber.Map{
+		"status":    status,
 		"timestamp": time.Now().Format(time.RFC3339),
 		"version":   "1.0.0",
 		"services": fiber.Map{
 			"database": dbStatus,
 			"api":      apiStatus,
+			"executor": executorStatus,
 		},
-	})
+	}
+	// A steadily rising drop count points at chronically slow WebSocket clients
+	if hub, ok := c.Locals("hub").(*Hub); ok {
+		resp["websocket"] = fiber.Map{
+			"clients":         hub.ClientCount(),
+			"droppedMessages": hub.DroppedMessages(),
+		}
+	}
+	return c.Status(code).JSON(resp)
+}
+
+// Liveness answers 200 as long as the process can serve requests. It checks no
+// dependencies, so a database blip doesn't get the process restarted.
+func Liveness(c *fiber.Ctx) error {
+	return c.JSON(fiber.Map{"status": "alive"})
+}
+
+// Readiness answers 503 while the database or the code execution engine is
+// unreachable, so traffic is only routed to an instance that can serve it
+func Readiness(c *fiber.Ctx) error {
+	dbStatus, dbErr := services.CheckDatabaseHealth()
+	executorStatus, executorErr := services.CheckExecutorHealth()
+
+	resp := fiber.Map{
+		"status": "ready",
+		"services": fiber.Map{
+			"database": dbStatus,
+			"executor": executorStatus,
+		},
+	}
+	if dbStatus != "connected" || executorStatus != "connected" {
+		resp["status"] = "not ready"
+		if dbErr != nil {
+			resp["databaseError"] = dbErr.Error()
+		}
+		if executorErr != nil {
+			resp["executorError"] = executorErr.Error()
+		}
+		return c.Status(http.StatusServiceUnavailable).JSON(resp)
+	}
+	return c.JSON(resp)
 }
@@ -0,0 +1,345 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// leaderboardSize caps how many ranked entries are stored per challenge
+const leaderboardSize = 100
+
+// LeaderboardWorker keeps the precomputed challenge leaderboards up to date.
+// It rebuilds every leaderboard on a fixed interval and individual ones as soon
+// as a new attempt is recorded.
+type LeaderboardWorker struct {
+	interval time.Duration
+
+	// Challenges with new attempts waiting to be recomputed
+	refresh chan primitive.ObjectID
+}
+
+// leaderboardWorker is the running worker, if any; submissions notify it
+var leaderboardWorker *LeaderboardWorker
+
+// StartLeaderboardWorker starts the background leaderboard worker, which runs
+// until ctx is cancelled
+func StartLeaderboardWorker(ctx context.Context, interval time.Duration) *LeaderboardWorker {
+	w := &LeaderboardWorker{
+		interval: interval,
+		refresh:  make(chan primitive.ObjectID, 64),
+	}
+	leaderboardWorker = w
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		w.Run(ctx)
+	}()
+	return w
+}
+
+// Run is the worker's event loop
+func (w *LeaderboardWorker) Run(ctx context.Context) {
+	fmt.Printf("Starting leaderboard worker (refresh every %s)...\n", w.interval)
+	refreshAllLeaderboards()
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			fmt.Println("Stopping leaderboard worker")
+			return
+
+		case <-ticker.C:
+			refreshAllLeaderboards()
+
+		case challengeID := <-w.refresh:
+			if _, err := refreshLeaderboard(challengeID); err != nil {
+				log.Printf("Failed to refresh leaderboard for challenge %s: %v", challengeID.Hex(), err)
+			}
+		}
+	}
+}
+
+// notifyLeaderboard queues a challenge's leaderboard for recomputation. It never
+// blocks the request; if the queue is full the next periodic refresh catches up.
+func notifyLeaderboard(challengeID primitive.ObjectID) {
+	if leaderboardWorker == nil {
+		return
+	}
+	select {
+	case leaderboardWorker.refresh <- challengeID:
+	default:
+		log.Printf("Leaderboard refresh queue full, deferring challenge %s to the next cycle", challengeID.Hex())
+	}
+}
+
+// refreshAllLeaderboards recomputes the leaderboard of every challenge with attempts
+func refreshAllLeaderboards() int {
+	challengeIDs, err := db.ChallengeAttemptsCollection.Distinct(context.Background(), "challengeId", bson.M{})
+	if err != nil {
+		log.Printf("Failed to list challenges for leaderboard refresh: %v", err)
+		return 0
+	}
+
+	refreshed := 0
+	for _, raw := range challengeIDs {
+		challengeID, ok := raw.(primitive.ObjectID)
+		if !ok {
+			continue
+		}
+		if _, err := refreshLeaderboard(challengeID); err != nil {
+			log.Printf("Failed to refresh leaderboard for challenge %s: %v", challengeID.Hex(), err)
+			continue
+		}
+		refreshed++
+	}
+	return refreshed
+}
+
+// refreshLeaderboard aggregates a challenge's attempts per user and stores the
+// result. Users are ranked by best score, then by their fastest passing attempt,
+// then by who passed first; each user appears once.
+func refreshLeaderboard(challengeID primitive.ObjectID) (*models.ChallengeLeaderboard, error) {
+	passedCond := bson.M{"$eq": bson.A{"$status", "Passed"}}
+	pipeline := mongo.Pipeline{
+		{{Key: "$match", Value: gradedAttempts(bson.M{"challengeId": challengeID})}},
+		{{Key: "$group", Value: bson.M{
+			"_id":           "$userId",
+			"attempts":      bson.M{"$sum": 1},
+			"passedCount":   bson.M{"$sum": bson.M{"$cond": bson.A{passedCond, 1, 0}}},
+			"bestScore":     bson.M{"$max": "$result.percentageScore"},
+			"passed":        bson.M{"$max": passedCond},
+			"fastestTime":   bson.M{"$min": bson.M{"$cond": bson.A{passedCond, "$timeSpent", nil}}},
+			"firstPassedAt": bson.M{"$min": bson.M{"$cond": bson.A{passedCond, "$createdAt", nil}}},
+		}}},
+		{{Key: "$facet", Value: bson.M{
+			"stats": bson.A{
+				bson.M{"$group": bson.M{
+					"_id":            nil,
+					"uniqueUsers":    bson.M{"$sum": 1},
+					"passedUsers":    bson.M{"$sum": bson.M{"$cond": bson.A{"$passed", 1, 0}}},
+					"totalAttempts":  bson.M{"$sum": "$attempts"},
+					"passedAttempts": bson.M{"$sum": "$passedCount"},
+					"averageScore":   bson.M{"$avg": "$bestScore"},
+				}},
+			},
+			"entries": bson.A{
+				// Users who never passed have no fastest time or pass date; they sort
+				// after those who did among equal scores
+				bson.M{"$addFields": bson.M{"notPassed": bson.M{"$cond": bson.A{"$passed", 0, 1}}}},
+				bson.M{"$sort": bson.D{
+					{Key: "bestScore", Value: -1},
+					{Key: "notPassed", Value: 1},
+					{Key: "fastestTime", Value: 1},
+					{Key: "firstPassedAt", Value: 1},
+					{Key: "attempts", Value: 1},
+					{Key: "_id", Value: 1},
+				}},
+				bson.M{"$limit": leaderboardSize},
+				// Submissions may come from student profiles or from user accounts
+				bson.M{"$lookup": bson.M{
+					"from":         db.StudentsCollection.Name(),
+					"localField":   "_id",
+					"foreignField": "_id",
+					"as":           "student",
+				}},
+				bson.M{"$lookup": bson.M{
+					"from":         db.UsersCollection.Name(),
+					"localField":   "_id",
+					"foreignField": "_id",
+					"as":           "user",
+				}},
+				bson.M{"$project": bson.M{
+					"attempts":      1,
+					"bestScore":     1,
+					"passed":        1,
+					"fastestTime":   1,
+					"firstPassedAt": 1,
+					"userName": bson.M{"$ifNull": bson.A{
+						bson.M{"$arrayElemAt": bson.A{"$student.basicInfo.name", 0}},
+						bson.M{"$arrayElemAt": bson.A{"$user.fullName", 0}},
+						// Accounts created through sign-up or OAuth only have first and last names
+						bson.M{"$trim": bson.M{"input": bson.M{"$concat": bson.A{
+							bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$user.firstName", 0}}, ""}},
+							" ",
+							bson.M{"$ifNull": bson.A{bson.M{"$arrayElemAt": bson.A{"$user.lastName", 0}}, ""}},
+						}}}},
+					}},
+				}},
+			},
+		}}},
+	}
+
+	cursor, err := db.ChallengeAttemptsCollection.Aggregate(context.Background(), pipeline)
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var facets []struct {
+		Stats []struct {
+			UniqueUsers    int     `bson:"uniqueUsers"`
+			PassedUsers    int     `bson:"passedUsers"`
+			TotalAttempts  int     `bson:"totalAttempts"`
+			PassedAttempts int     `bson:"passedAttempts"`
+			AverageScore   float64 `bson:"averageScore"`
+		} `bson:"stats"`
+		Entries []struct {
+			UserID        primitive.ObjectID `bson:"_id"`
+			UserName      string             `bson:"userName"`
+			Attempts      int                `bson:"attempts"`
+			BestScore     float64            `bson:"bestScore"`
+			Passed        bool               `bson:"passed"`
+			FastestTime   *int               `bson:"fastestTime"`
+			FirstPassedAt *time.Time         `bson:"firstPassedAt"`
+		} `bson:"entries"`
+	}
+	if err := cursor.All(context.Background(), &facets); err != nil {
+		return nil, err
+	}
+
+	stats := models.ChallengeStats{}
+	entries := []models.LeaderboardEntry{}
+	if len(facets) > 0 {
+		if len(facets[0].Stats) > 0 {
+			row := facets[0].Stats[0]
+			stats.UniqueUsers = row.UniqueUsers
+			stats.PassedUsers = row.PassedUsers
+			stats.TotalAttempts = row.TotalAttempts
+			stats.PassedAttempts = row.PassedAttempts
+			stats.AverageScore = roundPercent(row.AverageScore)
+		}
+		for i, row := range facets[0].Entries {
+			entry := models.LeaderboardEntry{
+				Rank:             i + 1,
+				UserID:           row.UserID,
+				UserName:         row.UserName,
+				BestScore:        roundPercent(row.BestScore),
+				Passed:           row.Passed,
+				Attempts:         row.Attempts,
+				FastestTimeSpent: row.FastestTime,
+			}
+			if row.FirstPassedAt != nil {
+				passedAt := row.FirstPassedAt.UTC()
+				entry.FirstPassedAt = &passedAt
+			}
+			entries = append(entries, entry)
+		}
+	}
+	stats.AcceptanceRate = percentOf(float64(stats.PassedAttempts), float64(stats.TotalAttempts))
+	stats.PassRate = percentOf(float64(stats.PassedUsers), float64(stats.UniqueUsers))
+
+	leaderboard := &models.ChallengeLeaderboard{
+		ChallengeID: challengeID,
+		Entries:     entries,
+		Stats:       stats,
+		ComputedAt:  time.Now().UTC(),
+	}
+	_, err = db.LeaderboardsCollection.ReplaceOne(context.Background(),
+		bson.M{"_id": challengeID}, leaderboard, options.Replace().SetUpsert(true))
+	if err != nil {
+		return nil, err
+	}
+	return leaderboard, nil
+}
+
+// loadLeaderboard returns the stored leaderboard for a challenge, computing and
+// storing it first if it hasn't been built yet
+func loadLeaderboard(challengeID primitive.ObjectID) (*models.ChallengeLeaderboard, error) {
+	var leaderboard models.ChallengeLeaderboard
+	err := db.LeaderboardsCollection.FindOne(context.Background(), bson.M{"_id": challengeID}).Decode(&leaderboard)
+	if err == nil {
+		leaderboard.ComputedAt = leaderboard.ComputedAt.UTC()
+		return &leaderboard, nil
+	}
+	if err != mongo.ErrNoDocuments {
+		return nil, err
+	}
+	return refreshLeaderboard(challengeID)
+}
+
+// GetChallengeStats returns submission count, distinct users and acceptance rate
+// for a challenge. Stats come from the precomputed leaderboard document, which
+// the leaderboard worker refreshes on every submission and on its interval.
+func GetChallengeStats(c *fiber.Ctx) error {
+	challengeID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge ID"})
+	}
+
+	leaderboard, err := loadLeaderboard(challengeID)
+	if err != nil {
+		log.Printf("Failed to load stats for challenge %s: %v", challengeID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenge stats"})
+	}
+
+	return c.JSON(fiber.Map{
+		"challengeId": challengeID,
+		"stats":       leaderboard.Stats,
+		"computedAt":  leaderboard.ComputedAt,
+	})
+}
+
+// GetChallengeLeaderboard serves the precomputed leaderboard for a challenge.
+// A leaderboard that hasn't been built yet is computed on first request.
+// ?limit= returns only the top entries, up to leaderboardSize.
+func GetChallengeLeaderboard(c *fiber.Ctx) error {
+	challengeID, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge ID"})
+	}
+	limit := leaderboardSize
+	if raw := c.Query("limit"); raw != "" {
+		limit, err = strconv.Atoi(raw)
+		if err != nil || limit < 1 {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "limit must be a positive integer"})
+		}
+	}
+
+	leaderboard, err := loadLeaderboard(challengeID)
+	if err != nil {
+		log.Printf("Failed to load leaderboard for challenge %s: %v", challengeID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch leaderboard"})
+	}
+	if len(leaderboard.Entries) > limit {
+		leaderboard.Entries = leaderboard.Entries[:limit]
+	}
+	return c.JSON(leaderboard)
+}
+
+// RefreshLeaderboards recomputes leaderboards immediately. Pass ?challengeId= to
+// refresh a single challenge; otherwise every challenge with attempts is rebuilt.
+func RefreshLeaderboards(c *fiber.Ctx) error {
+	if hexID := c.Query("challengeId"); hexID != "" {
+		challengeID, err := primitive.ObjectIDFromHex(hexID)
+		if err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid challenge ID"})
+		}
+		leaderboard, err := refreshLeaderboard(challengeID)
+		if err != nil {
+			log.Printf("Failed to refresh leaderboard for challenge %s: %v", challengeID.Hex(), err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to refresh leaderboard"})
+		}
+		return c.JSON(leaderboard)
+	}
+
+	refreshed := refreshAllLeaderboards()
+	return c.JSON(fiber.Map{
+		"refreshed":  refreshed,
+		"computedAt": time.Now().UTC(),
+	})
+}
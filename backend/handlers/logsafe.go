@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// redactedValue replaces sensitive values in log output
+const redactedValue = "[REDACTED]"
+
+// sensitiveKeyParts marks a field, header or query parameter as sensitive when
+// its lowercased name contains any of them
+var sensitiveKeyParts = []string{
+	"password", "passwd", "secret", "token", "authorization",
+	"cookie", "apikey", "api_key", "api-key", "session", "code", "state",
+}
+
+// isSensitiveKey reports whether a field name is likely to hold a credential
+func isSensitiveKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, part := range sensitiveKeyParts {
+		if strings.Contains(key, part) {
+			return true
+		}
+	}
+	return false
+}
+
+// sanitizeForLog returns a copy of a decoded JSON value with sensitive fields
+// redacted at any depth. Submitted code lives under "code" and is redacted too,
+// which also keeps logs from filling up with source listings.
+func sanitizeForLog(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		sanitized := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if isSensitiveKey(key) {
+				sanitized[key] = redactedValue
+			} else {
+				sanitized[key] = sanitizeForLog(item)
+			}
+		}
+		return sanitized
+	case []interface{}:
+		sanitized := make([]interface{}, len(v))
+		for i, item := range v {
+			sanitized[i] = sanitizeForLog(item)
+		}
+		return sanitized
+	default:
+		return value
+	}
+}
+
+// sanitizeBodyForLog decodes a JSON request body and redacts it with
+// sanitizeForLog. A body that isn't JSON is summarized by its size instead.
+func sanitizeBodyForLog(body []byte) interface{} {
+	var decoded interface{}
+	if err := json.Unmarshal(body, &decoded); err != nil {
+		return fmt.Sprintf("[unparseable body, %d bytes]", len(body))
+	}
+	return sanitizeForLog(decoded)
+}
+
+// sanitizeHeaders returns a copy of request headers with credentials redacted
+func sanitizeHeaders(headers map[string][]string) map[string][]string {
+	sanitized := make(map[string][]string, len(headers))
+	for key, values := range headers {
+		if isSensitiveKey(key) {
+			sanitized[key] = []string{redactedValue}
+		} else {
+			sanitized[key] = values
+		}
+	}
+	return sanitized
+}
+
+// SanitizeURL redacts passwords in userinfo and sensitive query parameters
+// (tokens, OAuth codes and state) so URLs can be logged safely
+func SanitizeURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redactedValue
+	}
+
+	query := u.Query()
+	for key := range query {
+		if isSensitiveKey(key) {
+			query.Set(key, redactedValue)
+		}
+	}
+	u.RawQuery = query.Encode()
+	return u.Redacted()
+}
+
+// debugLogging reports whether verbose diagnostics may be logged. It is never
+// true in production, whatever LOG_LEVEL says.
+func debugLogging() bool {
+	return getEnvWithDefault("GO_ENV", "development") != "production" &&
+		getEnvWithDefault("LOG_LEVEL", "debug") == "debug"
+}
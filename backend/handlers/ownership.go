@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// callerID returns the authenticated user's ID, or a zero ObjectID when the
+// request isn't authenticated
+func callerID(c *fiber.Ctx) primitive.ObjectID {
+	userID, _ := c.Locals("userId").(string)
+	objID, err := primitive.ObjectIDFromHex(userID)
+	if err != nil {
+		return primitive.NilObjectID
+	}
+	return objID
+}
+
+// canManage reports whether the caller may modify content owned by ownerID.
+// Admins manage everything; instructors only what they created. Content without
+// an owner predates ownership tracking and is admin-only.
+func canManage(c *fiber.Ctx, ownerID primitive.ObjectID) bool {
+	role, _ := c.Locals("userRole").(string)
+	switch role {
+	case "admin":
+		return true
+	case "instructor":
+		return !ownerID.IsZero() && ownerID == callerID(c)
+	}
+	return false
+}
+
+// ownerScope adds an ownership restriction to filter for instructors so they only
+// list their own content; other roles are left unrestricted
+func ownerScope(c *fiber.Ctx, filter bson.M) bson.M {
+	if role, _ := c.Locals("userRole").(string); role == "instructor" {
+		filter["ownerId"] = callerID(c)
+	}
+	return filter
+}
+
+// forbidden writes the standard response for ownership violations
+func forbidden(c *fiber.Ctx) error {
+	return c.Status(fiber.StatusForbidden).JSON(fiber.Map{
+		"error": "Access denied: you can only manage content you created",
+	})
+}
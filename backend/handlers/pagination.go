@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Page sizes for paginated listings
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// pagination is a validated ?page= and ?limit= pair; page is 1-based
+type pagination struct {
+	page  int64
+	limit int64
+}
+
+// parsePagination reads ?page= (default 1) and ?limit= (default 20). Limits
+// above the maximum are capped rather than rejected.
+func parsePagination(c *fiber.Ctx) (pagination, error) {
+	p := pagination{page: 1, limit: defaultPageLimit}
+	if raw := c.Query("page"); raw != "" {
+		page, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || page < 1 {
+			return p, fmt.Errorf("page must be a positive integer")
+		}
+		p.page = page
+	}
+	if raw := c.Query("limit"); raw != "" {
+		limit, err := strconv.ParseInt(raw, 10, 64)
+		if err != nil || limit < 1 {
+			return p, fmt.Errorf("limit must be a positive integer")
+		}
+		p.limit = min64(limit, maxPageLimit)
+	}
+	return p, nil
+}
+
+func (p pagination) skip() int64 {
+	return (p.page - 1) * p.limit
+}
+
+// findOptions applies the page to a Find
+func (p pagination) findOptions() *options.FindOptions {
+	return options.Find().SetSkip(p.skip()).SetLimit(p.limit)
+}
+
+// stages applies the page to an aggregation; they go after the sort
+func (p pagination) stages() mongo.Pipeline {
+	return mongo.Pipeline{
+		{{Key: "$skip", Value: p.skip()}},
+		{{Key: "$limit", Value: p.limit}},
+	}
+}
+
+// response wraps one page of data with the metadata clients need to page through it
+func (p pagination) response(data interface{}, total int64) fiber.Map {
+	return fiber.Map{
+		"data":       data,
+		"total":      total,
+		"page":       p.page,
+		"limit":      p.limit,
+		"totalPages": (total + p.limit - 1) / p.limit,
+	}
+}
+
+func min64(a, b int64) int64 {
+	if a < b {
+		return a
+	}
+	return b
+}
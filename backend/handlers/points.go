@@ -0,0 +1,143 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// challengePoints is what passing a challenge is worth. Challenges with an
+// unrecognised difficulty count as easy.
+func challengePoints(difficulty string) int {
+	switch strings.ToLower(difficulty) {
+	case "medium":
+		return 20
+	case "hard":
+		return 30
+	}
+	return 10
+}
+
+// awardChallengePoints credits a student for passing a challenge, once per
+// challenge. The unique index on point_awards makes the award itself the guard,
+// so resubmissions and concurrent passes can't award twice. Attempts whose user
+// ID came from the request body rather than a token never earn points.
+func awardChallengePoints(attempt *models.ChallengeAttempt, challenge *models.CodingChallenge) {
+	if attempt.Status != "Passed" || !attempt.Authenticated {
+		return
+	}
+
+	award := models.PointAward{
+		UserID:      attempt.UserID,
+		ChallengeID: challenge.ID,
+		AttemptID:   attempt.ID,
+		Points:      challengePoints(challenge.Difficulty),
+		AwardedAt:   time.Now().UTC(),
+	}
+	result, err := db.PointAwardsCollection.InsertOne(context.Background(), award)
+	if mongo.IsDuplicateKeyError(err) {
+		return
+	}
+	if err != nil {
+		log.Printf("Failed to record points for user %s on challenge %s: %v", attempt.UserID.Hex(), challenge.ID.Hex(), err)
+		return
+	}
+
+	filter, err := studentFilterForUser(attempt.UserID)
+	if err != nil {
+		log.Printf("Failed to find the student profile of user %s: %v", attempt.UserID.Hex(), err)
+		filter = bson.M{"_id": attempt.UserID}
+	}
+	update, err := db.StudentsCollection.UpdateOne(context.Background(),
+		filter,
+		bson.M{"$inc": bson.M{"basicInfo.points": award.Points}})
+	if err == nil && update.MatchedCount > 0 {
+		return
+	}
+
+	// Without a student profile there is nothing to credit; drop the award so a
+	// later pass can still earn the points
+	if err != nil {
+		log.Printf("Failed to add points for user %s: %v", attempt.UserID.Hex(), err)
+	}
+	if _, err := db.PointAwardsCollection.DeleteOne(context.Background(), bson.M{"_id": result.InsertedID}); err != nil {
+		log.Printf("Failed to remove uncredited point award %v: %v", result.InsertedID, err)
+	}
+}
+
+// studentFilterForUser matches the student profile points are credited to. A
+// signed-in user's ID belongs to the users collection, so their profile is the
+// one their account links by studentId or shares an email with; an ID with no
+// account behind it is taken to be a student's own.
+func studentFilterForUser(userID primitive.ObjectID) (bson.M, error) {
+	var user struct {
+		Email     string `bson:"email"`
+		StudentID string `bson:"studentId"`
+	}
+	err := db.UsersCollection.FindOne(context.Background(), bson.M{"_id": userID}).Decode(&user)
+	if err == mongo.ErrNoDocuments {
+		return bson.M{"_id": userID}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	matches := bson.A{bson.M{"_id": userID}}
+	if studentID, err := primitive.ObjectIDFromHex(user.StudentID); err == nil {
+		matches = append(matches, bson.M{"_id": studentID})
+	}
+	if email := normalizeStudentEmail(user.Email); email != "" {
+		matches = append(matches, bson.M{"basicInfo.email": email})
+	}
+	return bson.M{"$or": matches}, nil
+}
+
+// GetStudentLeaderboard lists students by points, most first, a page at a time
+// (?page=/?limit=)
+func GetStudentLeaderboard(c *fiber.Ctx) error {
+	page, err := parsePagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	total, err := db.StudentsCollection.CountDocuments(context.Background(), bson.M{})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count students"})
+	}
+
+	opts := page.findOptions().
+		SetSort(bson.D{{Key: "basicInfo.points", Value: -1}, {Key: "_id", Value: 1}}).
+		SetProjection(bson.M{"basicInfo.name": 1, "basicInfo.points": 1})
+	cursor, err := db.StudentsCollection.Find(context.Background(), bson.M{}, opts)
+	if err != nil {
+		log.Printf("Failed to fetch student leaderboard: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch leaderboard"})
+	}
+	defer cursor.Close(context.Background())
+
+	var students []models.Student
+	if err := cursor.All(context.Background(), &students); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse leaderboard"})
+	}
+
+	rankings := make([]models.StudentRanking, len(students))
+	for i, student := range students {
+		rankings[i] = models.StudentRanking{
+			Rank:      int(page.skip()) + i + 1,
+			StudentID: student.ID,
+			Name:      student.BasicInfo.Name,
+			Points:    student.BasicInfo.Points,
+		}
+	}
+	return c.JSON(page.response(rankings, total))
+}
@@ -0,0 +1,76 @@
+package handlers
+
+import (
+	"testing"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/integration/mtest"
+)
+
+func TestAwardChallengePointsCreditsSignedInUsersStudentProfile(t *testing.T) {
+	mt := mtest.New(t, mtest.NewOptions().ClientType(mtest.Mock))
+
+	mt.Run("authenticated pass", func(mt *mtest.T) {
+		db.PointAwardsCollection = mt.DB.Collection("point_awards")
+		db.UsersCollection = mt.DB.Collection("users")
+		db.StudentsCollection = mt.DB.Collection("students")
+
+		userID := primitive.NewObjectID()
+		mt.AddMockResponses(
+			// The award is recorded
+			mtest.CreateSuccessResponse(),
+			// The signed-in account, which only exists in users
+			mtest.CreateCursorResponse(0, "qms.users", mtest.FirstBatch, bson.D{
+				{Key: "_id", Value: userID},
+				{Key: "email", Value: " Student@Example.com "},
+			}),
+			// The linked student profile is found and credited
+			mtest.CreateSuccessResponse(bson.E{Key: "n", Value: 1}, bson.E{Key: "nModified", Value: 1}),
+		)
+
+		attempt := &models.ChallengeAttempt{
+			ID:            primitive.NewObjectID(),
+			UserID:        userID,
+			Status:        "Passed",
+			Authenticated: true,
+		}
+		challenge := &models.CodingChallenge{ID: primitive.NewObjectID(), Difficulty: "Medium"}
+		awardChallengePoints(attempt, challenge)
+
+		var update bson.Raw
+		for evt := mt.GetStartedEvent(); evt != nil; evt = mt.GetStartedEvent() {
+			if evt.CommandName == "delete" {
+				t.Fatal("the award was dropped as uncredited")
+			}
+			if evt.CommandName == "update" {
+				update = evt.Command
+			}
+		}
+		if update == nil {
+			t.Fatal("no student profile was updated")
+		}
+
+		statement := update.Lookup("updates").Array().Index(0).Value().Document()
+		matches := statement.Lookup("q", "$or")
+		if !matchesByEmail(matches, "student@example.com") {
+			t.Errorf("filter %v doesn't match the profile by the account's email", statement.Lookup("q"))
+		}
+		if points := statement.Lookup("u", "$inc", "basicInfo.points").AsInt64(); points != 20 {
+			t.Errorf("incremented points by %d, want 20", points)
+		}
+	})
+}
+
+func matchesByEmail(matches bson.RawValue, email string) bool {
+	values, _ := matches.Array().Values()
+	for _, match := range values {
+		if candidate, ok := match.Document().Lookup("basicInfo.email").StringValueOK(); ok && candidate == email {
+			return true
+		}
+	}
+	return false
+}
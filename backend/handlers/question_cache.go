@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"golang.org/x/sync/singleflight"
+)
+
+// questionCache keeps recently used question documents in memory for test
+// hydration. Questions rarely change during an exam, and when a whole class
+// opens the same test at once the concurrent hydrations share one fetch
+// instead of each querying Mongo. Updates and deletes invalidate entries.
+type questionCache struct {
+	once    sync.Once
+	ttl     time.Duration
+	mu      sync.RWMutex
+	entries map[string]questionCacheEntry
+	// generation changes on every invalidation so a fetch that raced with an
+	// update doesn't store the stale document
+	generation uint64
+	fetches    singleflight.Group
+}
+
+type questionCacheEntry struct {
+	question  models.Question
+	expiresAt time.Time
+}
+
+// testQuestions is the cache used by hydrateTest
+var testQuestions = &questionCache{}
+
+// init reads the TTL lazily, after main has loaded the .env file.
+// QUESTION_CACHE_TTL_SECONDS defaults to 300; 0 disables the cache.
+func (qc *questionCache) init() {
+	qc.once.Do(func() {
+		qc.ttl = 300 * time.Second
+		if ttl, err := strconv.Atoi(getEnvWithDefault("QUESTION_CACHE_TTL_SECONDS", "300")); err == nil && ttl >= 0 {
+			qc.ttl = time.Duration(ttl) * time.Second
+		}
+		qc.entries = make(map[string]questionCacheEntry)
+	})
+}
+
+// getMany returns the questions with the given IDs in that order, skipping any
+// that no longer exist
+func (qc *questionCache) getMany(ids []primitive.ObjectID) ([]models.Question, error) {
+	qc.init()
+	if len(ids) == 0 {
+		return nil, nil
+	}
+
+	found := make(map[string]models.Question, len(ids))
+	var missing []primitive.ObjectID
+	now := time.Now()
+	qc.mu.RLock()
+	generation := qc.generation
+	for _, id := range ids {
+		entry, ok := qc.entries[id.Hex()]
+		if ok && now.Before(entry.expiresAt) {
+			found[id.Hex()] = entry.question
+		} else {
+			missing = append(missing, id)
+		}
+	}
+	qc.mu.RUnlock()
+
+	if len(missing) > 0 {
+		fetched, err := qc.fetch(missing, generation)
+		if err != nil {
+			return nil, err
+		}
+		for _, q := range fetched {
+			found[q.ID.Hex()] = q
+		}
+	}
+
+	questions := make([]models.Question, 0, len(ids))
+	for _, id := range ids {
+		if q, ok := found[id.Hex()]; ok {
+			questions = append(questions, q)
+		}
+	}
+	return questions, nil
+}
+
+// fetch loads ids from Mongo, sharing the query with any concurrent caller
+// asking for the same set
+func (qc *questionCache) fetch(ids []primitive.ObjectID, generation uint64) ([]models.Question, error) {
+	keys := make([]string, len(ids))
+	for i, id := range ids {
+		keys[i] = id.Hex()
+	}
+	sort.Strings(keys)
+
+	shared, err, _ := qc.fetches.Do(strings.Join(keys, ","), func() (interface{}, error) {
+		cursor, err := db.QuestionsCollection.Find(context.Background(), bson.M{"_id": bson.M{"$in": ids}})
+		if err != nil {
+			return nil, err
+		}
+		defer cursor.Close(context.Background())
+
+		var questions []models.Question
+		if err := cursor.All(context.Background(), &questions); err != nil {
+			return nil, err
+		}
+
+		if qc.ttl > 0 {
+			expiresAt := time.Now().Add(qc.ttl)
+			qc.mu.Lock()
+			if qc.generation == generation {
+				for _, q := range questions {
+					qc.entries[q.ID.Hex()] = questionCacheEntry{question: q, expiresAt: expiresAt}
+				}
+			}
+			qc.mu.Unlock()
+		}
+		return questions, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return shared.([]models.Question), nil
+}
+
+// invalidate drops a question from the cache after it has been changed or deleted
+func (qc *questionCache) invalidate(id primitive.ObjectID) {
+	qc.init()
+	qc.mu.Lock()
+	delete(qc.entries, id.Hex())
+	qc.generation++
+	qc.mu.Unlock()
+}
@@ -5,6 +5,8 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"net/url"
+	"sort"
 	"strings"
 	"time"
 
@@ -25,6 +27,20 @@ func CreateQuestion(c *fiber.Ctx) error {
 
 	// Ensure question type is lowercase
 	question.Type = strings.ToLower(question.Type)
+	normalizeQuestionTopics(question)
+
+	if err := validateAcceptedAnswers(question.AcceptedAnswers); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateReferences(question.References); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateCorrectOptions(question); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateDifficulty(question.Difficulty); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	question.CreatedAt = time.Now()
 	result, err := db.QuestionsCollection.InsertOne(context.Background(), question)
@@ -36,9 +52,140 @@ func CreateQuestion(c *fiber.Ctx) error {
 	return c.Status(http.StatusCreated).JSON(question)
 }
 
-func GetQuestions(c *fiber.Ctx) error {
+// maxBulkQuestions caps how many questions one bulk import may contain
+const maxBulkQuestions = 500
+
+// validQuestionTypes are the question types the grader knows how to handle
+var validQuestionTypes = map[string]bool{"mcq": true, "multi_mcq": true, "subjective": true, "short_answer": true, "coding": true}
+
+// BulkQuestionError reports why one question of a bulk import was rejected
+type BulkQuestionError struct {
+	Index int    `json:"index"`
+	Error string `json:"error"`
+}
+
+// BulkCreateQuestions imports an array of questions in one insert. Invalid
+// questions are reported by their index and skipped; the valid ones are still
+// imported, so one bad entry doesn't fail the whole batch.
+func BulkCreateQuestions(c *fiber.Ctx) error {
 	var questions []models.Question
-	cursor, err := db.QuestionsCollection.Find(context.Background(), bson.M{})
+	if err := c.BodyParser(&questions); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body: expected an array of questions"})
+	}
+	if len(questions) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "No questions provided"})
+	}
+	if len(questions) > maxBulkQuestions {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("At most %d questions can be imported at once", maxBulkQuestions)})
+	}
+
+	now := time.Now()
+	docs := make([]interface{}, 0, len(questions))
+	accepted := make([]int, 0, len(questions))
+	rejected := []BulkQuestionError{}
+	for i := range questions {
+		question := &questions[i]
+		question.ID = primitive.NilObjectID
+		question.Type = strings.ToLower(question.Type)
+		normalizeQuestionTopics(question)
+		if err := validateImportedQuestion(question); err != nil {
+			rejected = append(rejected, BulkQuestionError{Index: i, Error: err.Error()})
+			continue
+		}
+		question.CreatedAt = now
+		docs = append(docs, question)
+		accepted = append(accepted, i)
+	}
+
+	if len(docs) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"inserted": 0,
+			"errors":   rejected,
+		})
+	}
+
+	result, err := db.QuestionsCollection.InsertMany(context.Background(), docs)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to import questions"})
+	}
+
+	ids := make([]fiber.Map, len(result.InsertedIDs))
+	for i, id := range result.InsertedIDs {
+		ids[i] = fiber.Map{"index": accepted[i], "id": id}
+	}
+	return c.Status(http.StatusCreated).JSON(fiber.Map{
+		"inserted": len(result.InsertedIDs),
+		"ids":      ids,
+		"errors":   rejected,
+	})
+}
+
+// validateImportedQuestion applies CreateQuestion's checks plus the basic shape
+// checks a hand-built import file needs
+func validateImportedQuestion(question *models.Question) error {
+	if !validQuestionTypes[question.Type] {
+		return fmt.Errorf("Invalid type %q (use mcq, multi_mcq, subjective, short_answer or coding)", question.Type)
+	}
+	if strings.TrimSpace(question.Content) == "" {
+		return fmt.Errorf("Content is required")
+	}
+	if question.Points <= 0 {
+		return fmt.Errorf("Points must be greater than 0")
+	}
+	if question.Type == "mcq" || question.Type == "multi_mcq" {
+		if len(question.Options) == 0 {
+			return fmt.Errorf("MCQ questions need at least one option")
+		}
+		for j, option := range question.Options {
+			if strings.TrimSpace(option) == "" {
+				return fmt.Errorf("Option %d is empty", j+1)
+			}
+		}
+	}
+	if err := validateCorrectOptions(question); err != nil {
+		return err
+	}
+	if err := validateDifficulty(question.Difficulty); err != nil {
+		return err
+	}
+	if question.Type == "short_answer" && strings.TrimSpace(question.CorrectAnswer) == "" {
+		return fmt.Errorf("Short-answer questions need a correct answer")
+	}
+	if err := validateAcceptedAnswers(question.AcceptedAnswers); err != nil {
+		return err
+	}
+	return validateReferences(question.References)
+}
+
+// GetQuestions lists one page of the question bank, in creation order.
+// ?tag= and ?category= narrow it to one topic, ?difficulty= to one level.
+func GetQuestions(c *fiber.Ctx) error {
+	page, err := parsePagination(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	filter := bson.M{}
+	if tag := strings.ToLower(strings.TrimSpace(c.Query("tag"))); tag != "" {
+		filter["tags"] = tag
+	}
+	if category := strings.TrimSpace(c.Query("category")); category != "" {
+		filter["category"] = category
+	}
+	if difficulty := models.NormalizeQuestionDifficulty(c.Query("difficulty")); difficulty != "" {
+		if err := validateDifficulty(difficulty); err != nil {
+			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+		}
+		filter["difficulty"] = difficulty
+	}
+	total, err := db.QuestionsCollection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count questions"})
+	}
+
+	questions := []models.Question{}
+	opts := page.findOptions().SetSort(bson.D{{Key: "_id", Value: 1}})
+	cursor, err := db.QuestionsCollection.Find(context.Background(), filter, opts)
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch questions"})
 	}
@@ -48,9 +195,66 @@ func GetQuestions(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse questions"})
 	}
 
-	fmt.Printf("%+v", questions)
+	return c.JSON(page.response(questions, total))
+}
+
+// GetQuestionTags lists every tag used in the question bank, alphabetically
+func GetQuestionTags(c *fiber.Ctx) error {
+	values, err := db.QuestionsCollection.Distinct(context.Background(), "tags", bson.M{})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch question tags"})
+	}
+
+	tags := make([]string, 0, len(values))
+	for _, value := range values {
+		if tag, ok := value.(string); ok {
+			tags = append(tags, tag)
+		}
+	}
+	sort.Strings(tags)
+	return c.JSON(fiber.Map{"tags": tags})
+}
+
+// QuestionBankCount is the number of questions of one difficulty and type
+type QuestionBankCount struct {
+	Difficulty string `json:"difficulty" bson:"difficulty"`
+	Type       string `json:"type" bson:"type"`
+	Count      int    `json:"count" bson:"count"`
+}
+
+// GetQuestionStats reports the composition of the question bank: counts by
+// difficulty and type, with unrated questions under an empty difficulty
+func GetQuestionStats(c *fiber.Ctx) error {
+	cursor, err := db.QuestionsCollection.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$group", Value: bson.M{
+			"_id":   bson.M{"difficulty": bson.M{"$ifNull": bson.A{"$difficulty", ""}}, "type": "$type"},
+			"count": bson.M{"$sum": 1},
+		}}},
+		{{Key: "$project", Value: bson.M{"_id": 0, "difficulty": "$_id.difficulty", "type": "$_id.type", "count": 1}}},
+		{{Key: "$sort", Value: bson.D{{Key: "difficulty", Value: 1}, {Key: "type", Value: 1}}}},
+	})
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to compute question stats"})
+	}
+	counts := []QuestionBankCount{}
+	if err := cursor.All(context.Background(), &counts); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse question stats"})
+	}
 
-	return c.JSON(questions)
+	total := 0
+	byDifficulty := map[string]int{}
+	byType := map[string]int{}
+	for _, count := range counts {
+		total += count.Count
+		byDifficulty[count.Difficulty] += count.Count
+		byType[count.Type] += count.Count
+	}
+	return c.JSON(fiber.Map{
+		"total":        total,
+		"byDifficulty": byDifficulty,
+		"byType":       byType,
+		"breakdown":    counts,
+	})
 }
 
 func GetQuestion(c *fiber.Ctx) error {
@@ -109,6 +313,20 @@ func UpdateQuestion(c *fiber.Ctx) error {
 
 	// Ensure question type is lowercase
 	question.Type = strings.ToLower(question.Type)
+	normalizeQuestionTopics(question)
+
+	if err := validateAcceptedAnswers(question.AcceptedAnswers); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateReferences(question.References); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateCorrectOptions(question); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if err := validateDifficulty(question.Difficulty); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
 
 	update := bson.M{
 		"$set": question,
@@ -122,6 +340,7 @@ func UpdateQuestion(c *fiber.Ctx) error {
 	if result.MatchedCount == 0 {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Question not found"})
 	}
+	testQuestions.invalidate(id)
 
 	return c.JSON(question)
 }
@@ -140,6 +359,83 @@ func DeleteQuestion(c *fiber.Ctx) error {
 	if result.DeletedCount == 0 {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Question not found"})
 	}
+	testQuestions.invalidate(id)
 
 	return c.SendStatus(http.StatusNoContent)
 }
+
+// normalizeQuestionTopics trims the category, capitalizes the difficulty and
+// lowercases the tags, dropping empty and repeated tags so filters match however
+// they were typed
+func normalizeQuestionTopics(question *models.Question) {
+	question.Category = strings.TrimSpace(question.Category)
+	question.Difficulty = models.NormalizeQuestionDifficulty(question.Difficulty)
+	if len(question.Tags) == 0 {
+		return
+	}
+	tags := make([]string, 0, len(question.Tags))
+	seen := make(map[string]bool, len(question.Tags))
+	for _, tag := range question.Tags {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		tags = append(tags, tag)
+	}
+	question.Tags = tags
+}
+
+// validateDifficulty checks a question's difficulty is one of the supported levels
+func validateDifficulty(difficulty string) error {
+	if !models.IsValidQuestionDifficulty(difficulty) {
+		return fmt.Errorf("Invalid difficulty %q (use Easy, Medium or Hard)", difficulty)
+	}
+	return nil
+}
+
+// validateCorrectOptions checks a multi-select question names at least one
+// correct option, each an index into Options and listed once
+func validateCorrectOptions(question *models.Question) error {
+	if question.Type != "multi_mcq" {
+		return nil
+	}
+	if len(question.CorrectOptions) == 0 {
+		return fmt.Errorf("Multi-select questions need at least one correct option")
+	}
+	seen := make(map[int]bool, len(question.CorrectOptions))
+	for _, index := range question.CorrectOptions {
+		if index < 0 || index >= len(question.Options) {
+			return fmt.Errorf("Correct option %d is not one of the %d options", index, len(question.Options))
+		}
+		if seen[index] {
+			return fmt.Errorf("Correct option %d is listed twice", index)
+		}
+		seen[index] = true
+	}
+	return nil
+}
+
+// validateAcceptedAnswers checks accepted short-answer variants are usable
+func validateAcceptedAnswers(variants []models.AcceptedAnswer) error {
+	for i, variant := range variants {
+		if strings.TrimSpace(variant.Answer) == "" {
+			return fmt.Errorf("Accepted answer %d is empty", i+1)
+		}
+		if !models.IsValidAnswerMatchMode(variant.MatchMode) {
+			return fmt.Errorf("Accepted answer %d has invalid matchMode %q (use exact, case-insensitive or contains)", i+1, variant.MatchMode)
+		}
+	}
+	return nil
+}
+
+// validateReferences checks every reference is an absolute http(s) link
+func validateReferences(references []models.QuestionReference) error {
+	for i, ref := range references {
+		u, err := url.Parse(strings.TrimSpace(ref.URL))
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("Reference %d must be an http or https URL", i+1)
+		}
+	}
+	return nil
+}
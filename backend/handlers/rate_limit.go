@@ -0,0 +1,124 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// rateLimiter is a token bucket per caller: each caller may make up to limit
+// requests at once, and regains one every minute/limit
+type rateLimiter struct {
+	once      sync.Once
+	envKey    string
+	mu        sync.Mutex
+	limit     float64
+	buckets   map[string]*rateBucket
+	lastSweep time.Time
+}
+
+type rateBucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// init reads the limit lazily, after main has loaded the .env file. The env
+// variable holds requests per minute and defaults to 10; 0 disables the limit.
+func (l *rateLimiter) init() {
+	l.once.Do(func() {
+		l.limit = 10
+		if limit, err := strconv.Atoi(getEnvWithDefault(l.envKey, "10")); err == nil && limit >= 0 {
+			l.limit = float64(limit)
+		}
+		l.buckets = make(map[string]*rateBucket)
+		l.lastSweep = time.Now()
+	})
+}
+
+// allow takes a token from key's bucket. When the bucket is empty it returns
+// false and how long until the next token.
+func (l *rateLimiter) allow(key string) (bool, time.Duration) {
+	l.init()
+	if l.limit == 0 {
+		return true, 0
+	}
+	perSecond := l.limit / 60
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	l.sweep(now, perSecond)
+
+	bucket, ok := l.buckets[key]
+	if !ok {
+		bucket = &rateBucket{tokens: l.limit, updated: now}
+		l.buckets[key] = bucket
+	}
+	bucket.tokens = math.Min(l.limit, bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond)
+	bucket.updated = now
+
+	if bucket.tokens < 1 {
+		wait := time.Duration((1 - bucket.tokens) / perSecond * float64(time.Second))
+		return false, wait
+	}
+	bucket.tokens--
+	return true, 0
+}
+
+// sweep drops buckets that have refilled completely, since a fresh bucket is the
+// same thing; the caller holds l.mu
+func (l *rateLimiter) sweep(now time.Time, perSecond float64) {
+	if now.Sub(l.lastSweep) < time.Minute {
+		return
+	}
+	l.lastSweep = now
+	for key, bucket := range l.buckets {
+		if bucket.tokens+now.Sub(bucket.updated).Seconds()*perSecond >= l.limit {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+// Submission limits, each with its own budget per caller
+var (
+	testSubmitLimiter      = &rateLimiter{envKey: "TEST_SUBMIT_RATE_LIMIT_PER_MINUTE"}
+	challengeSubmitLimiter = &rateLimiter{envKey: "CHALLENGE_SUBMIT_RATE_LIMIT_PER_MINUTE"}
+)
+
+// TestSubmitRateLimit limits test submissions per caller
+func TestSubmitRateLimit() fiber.Handler {
+	return rateLimitMiddleware(testSubmitLimiter)
+}
+
+// ChallengeSubmitRateLimit limits challenge submissions and sample runs per caller.
+// Both execute code, so they share one budget.
+func ChallengeSubmitRateLimit() fiber.Handler {
+	return rateLimitMiddleware(challengeSubmitLimiter)
+}
+
+// rateLimitMiddleware rejects callers over limiter's rate with 429 and a
+// Retry-After header. Callers are identified by the user ID that AuthMiddleware
+// or OptionalAuthMiddleware stored, and anonymous callers by IP.
+func rateLimitMiddleware(limiter *rateLimiter) fiber.Handler {
+	return func(c *fiber.Ctx) error {
+		key := "ip:" + c.IP()
+		if userID, _ := c.Locals("userId").(string); userID != "" {
+			key = "user:" + userID
+		}
+
+		allowed, wait := limiter.allow(key)
+		if !allowed {
+			retryAfter := int(math.Ceil(wait.Seconds()))
+			c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfter))
+			return c.Status(fiber.StatusTooManyRequests).JSON(fiber.Map{
+				"error":             "Too many submissions, please slow down",
+				"retryAfterSeconds": retryAfter,
+			})
+		}
+		return c.Next()
+	}
+}
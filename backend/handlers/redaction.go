@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+)
+
+// Hidden test cases exist so students can't code against them. Every handler
+// that serializes a validation result or attempt goes through resultForCaller or
+// attemptForCaller, which decide from the caller's role alone: staff get full
+// detail, everyone else gets studentResultView. Stored attempts always keep the
+// full detail. Public routes returning results need OptionalAuthMiddleware,
+// otherwise the role is never known and staff are served the redacted view.
+
+// studentResultView redacts hidden test cases from a validation result: their
+// inputs, outputs and point weights are removed, only pass/fail remains
+func studentResultView(result models.ValidationResult) models.ValidationResult {
+	testCases := make([]models.TestResult, len(result.TestCases))
+	for i, tc := range result.TestCases {
+		if tc.Hidden {
+			testCases[i] = models.TestResult{
+				Passed:      tc.Passed,
+				Description: tc.Description,
+				Hidden:      true,
+				Group:       tc.Group,
+			}
+			continue
+		}
+		testCases[i] = tc
+	}
+	result.TestCases = testCases
+	return result
+}
+
+// resultForCaller returns result as the caller is allowed to see it, with scores
+// rounded to the configured precision
+func resultForCaller(c *fiber.Ctx, result models.ValidationResult) models.ValidationResult {
+	result = roundValidationResult(result)
+	if isStaffRequest(c) {
+		return result
+	}
+	return studentResultView(result)
+}
+
+// attemptForCaller returns attempt with its result and review flags redacted for the caller
+func attemptForCaller(c *fiber.Ctx, attempt models.ChallengeAttempt) models.ChallengeAttempt {
+	attempt.Result = resultForCaller(c, attempt.Result)
+	if !isStaffRequest(c) {
+		attempt.SolutionSimilarity = 0
+		attempt.MatchesSolution = false
+	}
+	return attempt
+}
+
+// attemptsForCaller applies attemptForCaller to every attempt
+func attemptsForCaller(c *fiber.Ctx, attempts []models.ChallengeAttempt) []models.ChallengeAttempt {
+	redacted := make([]models.ChallengeAttempt, len(attempts))
+	for i, attempt := range attempts {
+		redacted[i] = attemptForCaller(c, attempt)
+	}
+	return redacted
+}
@@ -0,0 +1,48 @@
+package handlers
+
+import (
+	"crypto/rand"
+	"math/big"
+	"strings"
+)
+
+const (
+	// referenceCodePrefix marks attempt reference codes, e.g. ATT-7F3K9QW2MX
+	referenceCodePrefix = "ATT-"
+
+	// referenceCodeLength is the number of random characters after the prefix.
+	// Ten characters give about 8*10^14 codes, too many to guess.
+	referenceCodeLength = 10
+
+	// legacyReferenceCodeLength is the length of codes issued before they were
+	// lengthened; they are still looked up
+	legacyReferenceCodeLength = 5
+
+	// referenceCodeAlphabet omits characters that are easy to misread (0/O, 1/I/L)
+	referenceCodeAlphabet = "23456789ABCDEFGHJKMNPQRSTUVWXYZ"
+
+	// referenceCodeRetries bounds how often a colliding code is regenerated
+	referenceCodeRetries = 5
+)
+
+// generateReferenceCode returns a random human-readable attempt reference code
+func generateReferenceCode() (string, error) {
+	var sb strings.Builder
+	sb.WriteString(referenceCodePrefix)
+	max := big.NewInt(int64(len(referenceCodeAlphabet)))
+	for i := 0; i < referenceCodeLength; i++ {
+		n, err := rand.Int(rand.Reader, max)
+		if err != nil {
+			return "", err
+		}
+		sb.WriteByte(referenceCodeAlphabet[n.Int64()])
+	}
+	return sb.String(), nil
+}
+
+// isReferenceCode reports whether id looks like an attempt reference code
+func isReferenceCode(id string) bool {
+	length := len(id) - len(referenceCodePrefix)
+	return (length == referenceCodeLength || length == legacyReferenceCodeLength) &&
+		strings.HasPrefix(strings.ToUpper(id), referenceCodePrefix)
+}
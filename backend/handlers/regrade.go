@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+	"qms-backend/services"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// RegradeItemResult reports the outcome of regrading a single attempt
+type RegradeItemResult struct {
+	AttemptID       string                   `json:"attemptId"`
+	UserID          string                   `json:"userId"`
+	Success         bool                     `json:"success"`
+	Status          string                   `json:"status,omitempty"`
+	PercentageScore float64                  `json:"percentageScore"`
+	Result          *models.ValidationResult `json:"result,omitempty"`
+	Error           string                   `json:"error,omitempty"`
+}
+
+// RegradeChallengeAttempts re-runs every stored attempt for a challenge against its
+// current test cases. Executions run on a bounded worker pool under an overall
+// deadline; attempts that fail or aren't reached in time are reported per item
+// instead of aborting the batch.
+func RegradeChallengeAttempts(c *fiber.Ctx) error {
+	challenge, err := findChallenge(c)
+	if challenge == nil {
+		return err
+	}
+
+	var attempts []models.ChallengeAttempt
+	cursor, err := db.ChallengeAttemptsCollection.Find(
+		context.Background(),
+		bson.M{"challengeId": challenge.ID, "status": bson.M{"$ne": models.AttemptStatusPending}},
+		options.Find().SetSort(bson.D{{Key: "createdAt", Value: 1}}),
+	)
+	if err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch challenge attempts"})
+	}
+	defer cursor.Close(context.Background())
+
+	if err := cursor.All(context.Background(), &attempts); err != nil {
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to parse challenge attempts"})
+	}
+
+	batchConfig := services.LoadBatchConfig()
+	ctx, cancel := context.WithTimeout(context.Background(), batchConfig.Timeout)
+	defer cancel()
+
+	executionService := services.NewCodeExecutionService()
+	results := make([]RegradeItemResult, len(attempts))
+	errs := services.RunBatch(ctx, len(attempts), batchConfig.Concurrency, func(ctx context.Context, i int) error {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		attempt := attempts[i]
+		language := attempt.Language
+		if language == "" {
+			language = challenge.Language
+		}
+
+		validationResult, err := executionService.ExecuteCode(ctx, challenge, language, attempt.Code)
+		if err != nil {
+			return err
+		}
+
+		status := "Failed"
+		if validationResult.Passed {
+			status = "Passed"
+		}
+
+		// Always persist a finished regrade, even if the batch deadline passed meanwhile
+		_, err = db.ChallengeAttemptsCollection.UpdateOne(
+			context.Background(),
+			bson.M{"_id": attempt.ID},
+			bson.M{"$set": bson.M{"result": validationResult, "status": status}},
+		)
+		if err != nil {
+			return err
+		}
+
+		// A regrade that turns an attempt into a pass earns its points, if not already earned
+		attempt.Status = status
+		awardChallengePoints(&attempt, challenge)
+
+		results[i].Status = status
+		results[i].PercentageScore = roundPercent(validationResult.PercentageScore)
+		redacted := resultForCaller(c, *validationResult)
+		results[i].Result = &redacted
+		return nil
+	})
+
+	succeeded, failed, skipped := 0, 0, 0
+	for i, attempt := range attempts {
+		results[i].AttemptID = attempt.ID.Hex()
+		results[i].UserID = attempt.UserID.Hex()
+		switch {
+		case errs[i] == nil:
+			results[i].Success = true
+			succeeded++
+		case errors.Is(errs[i], context.DeadlineExceeded):
+			results[i].Error = "Not regraded: operation deadline exceeded"
+			skipped++
+		default:
+			log.Printf("Failed to regrade attempt %s: %v", attempt.ID.Hex(), errs[i])
+			results[i].Error = errs[i].Error()
+			failed++
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"challengeId":      challenge.ID.Hex(),
+		"total":            len(attempts),
+		"succeeded":        succeeded,
+		"failed":           failed,
+		"skipped":          skipped,
+		"deadlineExceeded": errors.Is(ctx.Err(), context.DeadlineExceeded),
+		"regradedAt":       time.Now().UTC().Format(time.RFC3339),
+		"results":          results,
+	})
+}
@@ -0,0 +1,82 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"sync"
+
+	"qms-backend/models"
+)
+
+// Score precision, read lazily after main has loaded the .env file.
+// SCORE_PERCENT_DECIMALS (default 1) applies to percentages and rates,
+// SCORE_POINTS_DECIMALS (default 2) to points. Both match the executor's own
+// rounding so stored and recomputed numbers agree.
+var (
+	scorePrecisionOnce sync.Once
+	percentDecimals    = 1
+	pointsDecimals     = 2
+)
+
+func loadScorePrecision() {
+	scorePrecisionOnce.Do(func() {
+		if d, err := strconv.Atoi(getEnvWithDefault("SCORE_PERCENT_DECIMALS", "1")); err == nil && d >= 0 && d <= 6 {
+			percentDecimals = d
+		}
+		if d, err := strconv.Atoi(getEnvWithDefault("SCORE_POINTS_DECIMALS", "2")); err == nil && d >= 0 && d <= 6 {
+			pointsDecimals = d
+		}
+	})
+}
+
+func roundTo(value float64, decimals int) float64 {
+	scale := math.Pow(10, float64(decimals))
+	return math.Round(value*scale) / scale
+}
+
+// roundPercent rounds a 0-100 percentage or rate to the configured precision
+func roundPercent(value float64) float64 {
+	loadScorePrecision()
+	return roundTo(value, percentDecimals)
+}
+
+// roundPoints rounds a points value to the configured precision
+func roundPoints(value float64) float64 {
+	loadScorePrecision()
+	return roundTo(value, pointsDecimals)
+}
+
+// percentOf returns part as a rounded percentage of total, or 0 when total is 0
+func percentOf(part, total float64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return roundPercent(part / total * 100)
+}
+
+// roundValidationResult applies the configured precision to every score in a
+// validation result
+func roundValidationResult(result models.ValidationResult) models.ValidationResult {
+	result.TotalPoints = roundPoints(result.TotalPoints)
+	result.ScoredPoints = roundPoints(result.ScoredPoints)
+	result.PercentageScore = roundPercent(result.PercentageScore)
+
+	testCases := make([]models.TestResult, len(result.TestCases))
+	for i, tc := range result.TestCases {
+		tc.PointsAvailable = roundPoints(tc.PointsAvailable)
+		tc.PointsScored = roundPoints(tc.PointsScored)
+		testCases[i] = tc
+	}
+	result.TestCases = testCases
+
+	if len(result.Groups) > 0 {
+		groups := make([]models.GroupResult, len(result.Groups))
+		for i, g := range result.Groups {
+			g.TotalPoints = roundPoints(g.TotalPoints)
+			g.ScoredPoints = roundPoints(g.ScoredPoints)
+			groups[i] = g
+		}
+		result.Groups = groups
+	}
+	return result
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// loadSequentialTest fetches a test for the sequential endpoints, writing the
+// error response itself when the test is missing or not in sequential mode
+func loadSequentialTest(c *fiber.Ctx) (models.TestBSON, bool) {
+	testBSON, ok := loadProgressTest(c)
+	if !ok {
+		return testBSON, false
+	}
+	if testBSON.Mode != models.TestModeSequential {
+		c.Status(http.StatusConflict).JSON(fiber.Map{"error": "This test is not answered one question at a time; use the submit endpoint"})
+		return testBSON, false
+	}
+	return testBSON, true
+}
+
+// loadProgressTest fetches the test named by the :id parameter, writing the
+// error response itself when it is missing
+func loadProgressTest(c *fiber.Ctx) (models.TestBSON, bool) {
+	var testBSON models.TestBSON
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid test ID"})
+		return testBSON, false
+	}
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&testBSON)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		} else {
+			log.Printf("Failed to fetch test %s: %v", id.Hex(), err)
+			c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test"})
+		}
+		return testBSON, false
+	}
+	return testBSON, true
+}
+
+// sequentialQuestions returns the test's questions in the order the instructor
+// listed them, skipping any that have since been deleted
+func sequentialQuestions(testBSON models.TestBSON) ([]models.Question, error) {
+	return testQuestions.getMany(testBSON.Questions)
+}
+
+// findTestProgress returns the student's progress on a test, or nil if they
+// haven't started or answered anything yet
+func findTestProgress(testID, studentID string) (*models.TestProgress, error) {
+	var progress models.TestProgress
+	err := db.TestProgressCollection.FindOne(context.Background(), bson.M{
+		"testId":    testID,
+		"studentId": studentID,
+	}).Decode(&progress)
+	if err == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &progress, nil
+}
+
+// nextQuestionView strips the answer key and hidden test cases from the
+// question a student is about to see
+func nextQuestionView(q models.Question) models.Question {
+	return withoutAnswerKey(q)
+}
+
+// progressResponse describes where a student stands on a sequential test
+func progressResponse(testID string, questions []models.Question, progress *models.TestProgress) fiber.Map {
+	answered := 0
+	resp := fiber.Map{
+		"testId":         testID,
+		"totalQuestions": len(questions),
+	}
+	if progress != nil {
+		answered = len(progress.Answers)
+		resp["startedAt"] = progress.StartedAt.UTC()
+		if progress.FinalizedAt != nil {
+			resp["finalizedAt"] = progress.FinalizedAt.UTC()
+			resp["submissionId"] = progress.SubmissionID
+		}
+	}
+	resp["answered"] = answered
+	if answered < len(questions) && (progress == nil || progress.FinalizedAt == nil) {
+		resp["nextQuestion"] = nextQuestionView(questions[answered])
+	}
+	return resp
+}
+
+// GetTestProgress returns how many questions of a sequential test a student has
+// answered and the next question to show them. For free-mode tests it returns
+// the answers the student has saved instead. Students get their own progress;
+// staff pick the student with ?studentId=.
+func GetTestProgress(c *fiber.Ctx) error {
+	testBSON, ok := loadProgressTest(c)
+	if !ok {
+		return nil
+	}
+	studentID, _ := c.Locals("userId").(string)
+	if isStaffRequest(c) {
+		studentID = c.Query("studentId")
+	}
+	if studentID == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Student ID is required"})
+	}
+	if testBSON.Mode != models.TestModeSequential {
+		return getSavedProgress(c, testBSON, studentID)
+	}
+
+	questions, err := sequentialQuestions(testBSON)
+	if err != nil {
+		log.Printf("Failed to fetch questions for test %s: %v", testBSON.ID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch progress"})
+	}
+	progress, err := findTestProgress(testBSON.ID.Hex(), studentID)
+	if err != nil {
+		log.Printf("Failed to fetch progress for student %s on test %s: %v", studentID, testBSON.ID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch progress"})
+	}
+
+	return c.JSON(progressResponse(testBSON.ID.Hex(), questions, progress))
+}
+
+// SubmitTestAnswer records a single answer to a sequential test. Answers must
+// arrive in question order and an answered question can't be answered again.
+// Answers are always recorded for the caller.
+func SubmitTestAnswer(c *fiber.Ctx) error {
+	var req struct {
+		QuestionID string `json:"questionId"`
+		Answer     string `json:"answer"`
+		Language   string `json:"language"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	if req.QuestionID == "" {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Question ID is required"})
+	}
+	studentID, _ := c.Locals("userId").(string)
+
+	testBSON, ok := loadSequentialTest(c)
+	if !ok {
+		return nil
+	}
+	testID := testBSON.ID.Hex()
+
+	if !enforceSubmissionWindow(c, testBSON, studentID) {
+		return nil
+	}
+	if !enforcePersonalDeadline(c, testBSON, studentID) {
+		return nil
+	}
+	now := time.Now()
+
+	questions, err := sequentialQuestions(testBSON)
+	if err != nil {
+		log.Printf("Failed to fetch questions for test %s: %v", testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record answer"})
+	}
+	progress, err := findTestProgress(testID, studentID)
+	if err != nil {
+		log.Printf("Failed to fetch progress for student %s on test %s: %v", studentID, testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record answer"})
+	}
+
+	answered := 0
+	if progress != nil {
+		if progress.FinalizedAt != nil {
+			return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Test has already been submitted"})
+		}
+		answered = len(progress.Answers)
+	}
+	if answered >= len(questions) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "All questions have been answered; finalize the test"})
+	}
+	if expected := questions[answered].ID.Hex(); req.QuestionID != expected {
+		for _, a := range progressAnswers(progress) {
+			if a.QuestionID == req.QuestionID {
+				return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Question has already been answered"})
+			}
+		}
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":              "Questions must be answered in order",
+			"expectedQuestionId": expected,
+		})
+	}
+
+	// Append only if the answer count is still what we read, so two concurrent
+	// requests for the same question can't both be recorded
+	answer := models.Answer{QuestionID: req.QuestionID, Answer: req.Answer, Language: req.Language}
+	filter := bson.M{
+		"testId":      testID,
+		"studentId":   studentID,
+		"finalizedAt": bson.M{"$exists": false},
+		"answers":     bson.M{"$size": answered},
+	}
+	update := bson.M{
+		"$push":        bson.M{"answers": answer},
+		"$setOnInsert": bson.M{"startedAt": now},
+	}
+	// Upserting is only safe for the first answer; later ones must match an existing record
+	opts := options.FindOneAndUpdate().SetUpsert(answered == 0).SetReturnDocument(options.After)
+	var updated models.TestProgress
+	err = db.TestProgressCollection.FindOneAndUpdate(context.Background(), filter, update, opts).Decode(&updated)
+	if err == mongo.ErrNoDocuments || mongo.IsDuplicateKeyError(err) {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Question has already been answered"})
+	}
+	if err != nil {
+		log.Printf("Failed to record answer for student %s on test %s: %v", studentID, testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to record answer"})
+	}
+
+	return c.JSON(progressResponse(testID, questions, &updated))
+}
+
+// progressAnswers returns the recorded answers, tolerating a nil progress
+func progressAnswers(progress *models.TestProgress) []models.Answer {
+	if progress == nil {
+		return nil
+	}
+	return progress.Answers
+}
+
+// FinalizeTest turns a student's sequential answers into a graded submission.
+// Unanswered questions are simply left out, as with a partial free-mode submission.
+// The submission is always made for the caller.
+func FinalizeTest(c *fiber.Ctx) error {
+	var req struct {
+		StudentName  string `json:"studentName"`
+		StudentEmail string `json:"studentEmail"`
+		TimeSpent    int    `json:"timeSpent"`
+	}
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
+	}
+	studentID, _ := c.Locals("userId").(string)
+
+	testBSON, ok := loadSequentialTest(c)
+	if !ok {
+		return nil
+	}
+	testID := testBSON.ID.Hex()
+
+	if !enforceSubmissionWindow(c, testBSON, studentID) {
+		return nil
+	}
+	if !enforcePersonalDeadline(c, testBSON, studentID) {
+		return nil
+	}
+
+	progress, err := findTestProgress(testID, studentID)
+	if err != nil {
+		log.Printf("Failed to fetch progress for student %s on test %s: %v", studentID, testID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
+	if progress == nil || len(progress.Answers) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "No answers provided"})
+	}
+	if progress.FinalizedAt != nil {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error":        "Test has already been submitted",
+			"submissionId": progress.SubmissionID,
+		})
+	}
+
+	// Claim the progress record before grading so a double submit can't create two attempts
+	now := time.Now()
+	res, err := db.TestProgressCollection.UpdateOne(context.Background(),
+		bson.M{"_id": progress.ID, "finalizedAt": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"finalizedAt": now}})
+	if err != nil {
+		log.Printf("Failed to finalize progress %s: %v", progress.ID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
+	if res.ModifiedCount == 0 {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{"error": "Test has already been submitted"})
+	}
+	release := func() {
+		_, err := db.TestProgressCollection.UpdateOne(context.Background(),
+			bson.M{"_id": progress.ID}, bson.M{"$unset": bson.M{"finalizedAt": ""}})
+		if err != nil {
+			log.Printf("Failed to reopen progress %s after a failed submit: %v", progress.ID.Hex(), err)
+		}
+	}
+
+	submission := &models.TestSubmission{
+		TestID:       testID,
+		StudentID:    studentID,
+		StudentName:  req.StudentName,
+		StudentEmail: req.StudentEmail,
+		Answers:      progress.Answers,
+		SubmittedAt:  now,
+		TimeSpent:    req.TimeSpent,
+	}
+	submission.ServerTimeSpent = serverTimeSpent(progress, now)
+	questions, err := fetchQuestionsForAnswers(submission.Answers)
+	if err != nil {
+		release()
+		log.Printf("Failed to fetch questions for grading: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to grade submission"})
+	}
+	submission.QuestionResults = gradeAnswers(submission.Answers, questions)
+	gradePendingAnswers(submission, questions)
+	scoreSubmission(submission, questions, testBSON)
+
+	if err := insertSubmission(submission); err != nil {
+		release()
+		log.Printf("Failed to submit test: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
+	_, err = db.TestProgressCollection.UpdateOne(context.Background(),
+		bson.M{"_id": progress.ID}, bson.M{"$set": bson.M{"submissionId": submission.ID}})
+	if err != nil {
+		log.Printf("Failed to link progress %s to submission %s: %v", progress.ID.Hex(), submission.ID, err)
+	}
+	log.Printf("Successfully finalized sequential test attempt with ID: %s", submission.ID)
+
+	return c.Status(http.StatusCreated).JSON(submission)
+}
@@ -0,0 +1,84 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// notDeleted restricts filter to tests or challenges that haven't been
+// soft-deleted. Results lookups deliberately skip it so attempts of deleted
+// content still show its title.
+func notDeleted(filter bson.M) bson.M {
+	filter["deletedAt"] = bson.M{"$exists": false}
+	return filter
+}
+
+// deletedOnly matches the soft-deleted document with the given ID
+func deletedOnly(id primitive.ObjectID) bson.M {
+	return bson.M{"_id": id, "deletedAt": bson.M{"$exists": true}}
+}
+
+// restoreUpdate clears a soft delete
+func restoreUpdate() bson.M {
+	return bson.M{
+		"$set":   bson.M{"updatedAt": time.Now().UTC()},
+		"$unset": bson.M{"deletedAt": ""},
+	}
+}
+
+// RestoreTest undoes the soft delete of a test
+func RestoreTest(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var testBSON models.TestBSON
+	err = db.TestsCollection.FindOneAndUpdate(context.Background(), deletedOnly(id), restoreUpdate(),
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&testBSON)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deleted test not found"})
+		}
+		log.Printf("Failed to restore test %s: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore test"})
+	}
+
+	test, err := hydrateTest(testBSON)
+	if err != nil {
+		log.Printf("Failed to hydrate restored test %s: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare test response"})
+	}
+	return c.JSON(test)
+}
+
+// RestoreChallenge undoes the soft delete of a coding challenge
+func RestoreChallenge(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var challenge models.CodingChallenge
+	err = db.ChallengesCollection.FindOneAndUpdate(context.Background(), deletedOnly(id), restoreUpdate(),
+		options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&challenge)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Deleted challenge not found"})
+		}
+		log.Printf("Failed to restore challenge %s: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to restore challenge"})
+	}
+	return c.JSON(challenge)
+}
@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+
+	"qms-backend/models"
+)
+
+// maxSimilarityCodeLength bounds the edit-distance comparison, which is
+// quadratic in the code length; longer submissions are only checked for an
+// exact match
+const maxSimilarityCodeLength = 8000
+
+var (
+	solutionSimilarityOnce      sync.Once
+	solutionSimilarityThreshold float64
+)
+
+// similarityThreshold reads SOLUTION_SIMILARITY_THRESHOLD lazily, after main has
+// loaded the .env file. It defaults to 0.95; values outside (0, 1] are ignored.
+func similarityThreshold() float64 {
+	solutionSimilarityOnce.Do(func() {
+		solutionSimilarityThreshold = 0.95
+		if t, err := strconv.ParseFloat(getEnvWithDefault("SOLUTION_SIMILARITY_THRESHOLD", "0.95"), 64); err == nil && t > 0 && t <= 1 {
+			solutionSimilarityThreshold = t
+		}
+	})
+	return solutionSimilarityThreshold
+}
+
+// normalizeCode trims every line, collapses runs of whitespace and drops blank
+// lines, so reformatting alone doesn't hide a copied solution
+func normalizeCode(code string) string {
+	lines := strings.Split(strings.ReplaceAll(code, "\r\n", "\n"), "\n")
+	normalized := make([]string, 0, len(lines))
+	for _, line := range lines {
+		if fields := strings.Fields(line); len(fields) > 0 {
+			normalized = append(normalized, strings.Join(fields, " "))
+		}
+	}
+	return strings.Join(normalized, "\n")
+}
+
+// codeSimilarity scores two normalized sources between 0 and 1 the same way the
+// executor scores outputs: 70% edit-distance similarity, 30% length ratio.
+// ok is false when the sources are too long to compare.
+func codeSimilarity(a, b string) (score float64, ok bool) {
+	if a == b {
+		return 1, true
+	}
+	if len(a) == 0 || len(b) == 0 {
+		return 0, true
+	}
+	if len(a) > maxSimilarityCodeLength || len(b) > maxSimilarityCodeLength {
+		return 0, false
+	}
+
+	maxLen := float64(max(len(a), len(b)))
+	similarity := 1 - float64(editDistance(a, b))/maxLen
+	lenRatio := float64(min(len(a), len(b))) / maxLen
+	return math.Max(0, math.Min(1, similarity*0.7+lenRatio*0.3)), true
+}
+
+// editDistance is the Levenshtein distance between a and b, kept to two rows
+func editDistance(a, b string) int {
+	prev := make([]int, len(b)+1)
+	curr := make([]int, len(b)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(a); i++ {
+		curr[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			curr[j] = min(min(prev[j]+1, curr[j-1]+1), prev[j-1]+cost)
+		}
+		prev, curr = curr, prev
+	}
+	return prev[len(b)]
+}
+
+// flagSolutionMatch records how closely an attempt's code matches the
+// challenge's reference solution and flags it for review when the similarity
+// reaches the threshold. The submission itself is never rejected.
+func flagSolutionMatch(attempt *models.ChallengeAttempt, challenge *models.CodingChallenge) {
+	if strings.TrimSpace(challenge.SolutionCode) == "" {
+		return
+	}
+	score, ok := codeSimilarity(normalizeCode(attempt.Code), normalizeCode(challenge.SolutionCode))
+	if !ok {
+		return
+	}
+	attempt.SolutionSimilarity = score
+	attempt.MatchesSolution = score >= similarityThreshold()
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Per-student test statuses
+const (
+	StudentTestScheduled = "scheduled"
+	StudentTestActive    = "active"
+	StudentTestCompleted = "completed"
+	StudentTestMissed    = "missed" // Window closed without a submission
+)
+
+// StudentTestStatus is one assigned test and where the student stands on it
+type StudentTestStatus struct {
+	TestID        string     `json:"testId"`
+	Title         string     `json:"title"`
+	StartTime     time.Time  `json:"startTime"`
+	EndTime       time.Time  `json:"endTime"`
+	Duration      int        `json:"duration"`
+	Status        string     `json:"status"`
+	AttemptID     string     `json:"attemptId,omitempty"`
+	ReferenceCode string     `json:"referenceCode,omitempty"`
+	SubmittedAt   *time.Time `json:"submittedAt,omitempty"`
+	Score         *float64   `json:"score,omitempty"`
+	MaxScore      float64    `json:"maxScore"`
+}
+
+// GetStudentTests lists every test assigned to a student with its status
+// (scheduled, active, completed or missed) and the score of completed ones.
+// Students may only request their own list; admins may request anyone's.
+func GetStudentTests(c *fiber.Ctx) error {
+	studentID := c.Params("id")
+	callerID, _ := c.Locals("userId").(string)
+	role, _ := c.Locals("userRole").(string)
+	if studentID != callerID && role != "admin" {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Access denied: you can only view your own tests"})
+	}
+
+	cursor, err := db.TestsCollection.Find(context.Background(), notDeleted(studentTestScope(studentID)),
+		options.Find().SetSort(bson.D{{Key: "startTime", Value: 1}}))
+	if err != nil {
+		log.Printf("Failed to fetch tests for student %s: %v", studentID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch tests"})
+	}
+	defer cursor.Close(context.Background())
+
+	var testsBSON []models.TestBSON
+	if err := cursor.All(context.Background(), &testsBSON); err != nil {
+		log.Printf("Failed to decode tests for student %s: %v", studentID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch tests"})
+	}
+
+	// Latest submission per test
+	submissionCursor, err := db.AttemptCollection.Find(context.Background(), bson.M{"studentId": studentID},
+		options.Find().SetSort(bson.D{{Key: "submittedAt", Value: 1}}))
+	if err != nil {
+		log.Printf("Failed to fetch submissions for student %s: %v", studentID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch submissions"})
+	}
+	defer submissionCursor.Close(context.Background())
+
+	var submissions []models.TestSubmission
+	if err := submissionCursor.All(context.Background(), &submissions); err != nil {
+		log.Printf("Failed to decode submissions for student %s: %v", studentID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch submissions"})
+	}
+	latest := make(map[string]models.TestSubmission, len(submissions))
+	for _, submission := range submissions {
+		latest[submission.TestID] = submission
+	}
+
+	points, err := fetchQuestionPoints(testsBSON)
+	if err != nil {
+		log.Printf("Failed to fetch question points for student %s: %v", studentID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch questions"})
+	}
+
+	now := time.Now().UTC()
+	statuses := make([]StudentTestStatus, 0, len(testsBSON))
+	for _, test := range testsBSON {
+		status := StudentTestStatus{
+			TestID:    test.ID.Hex(),
+			Title:     test.Title,
+			StartTime: test.StartTime.UTC(),
+			EndTime:   test.EndTime.UTC(),
+			Duration:  test.Duration,
+		}
+		for _, questionID := range test.Questions {
+			status.MaxScore += points[questionID]
+		}
+
+		if submission, ok := latest[status.TestID]; ok {
+			status.Status = StudentTestCompleted
+			status.AttemptID = submission.ID
+			status.ReferenceCode = submission.Reference
+			submittedAt := submission.SubmittedAt.UTC()
+			status.SubmittedAt = &submittedAt
+			score := 0.0
+			for _, result := range submission.QuestionResults {
+				score += result.AwardedPoints
+			}
+			score = roundPoints(score)
+			status.Score = &score
+		} else if now.Before(status.StartTime) {
+			status.Status = StudentTestScheduled
+		} else if now.Before(status.EndTime) {
+			status.Status = StudentTestActive
+		} else {
+			status.Status = StudentTestMissed
+		}
+		statuses = append(statuses, status)
+	}
+
+	return c.JSON(statuses)
+}
+
+// fetchQuestionPoints loads the point value of every question used by tests
+func fetchQuestionPoints(tests []models.TestBSON) (map[primitive.ObjectID]float64, error) {
+	var questionIDs []primitive.ObjectID
+	for _, test := range tests {
+		questionIDs = append(questionIDs, test.Questions...)
+	}
+
+	points := make(map[primitive.ObjectID]float64)
+	if len(questionIDs) == 0 {
+		return points, nil
+	}
+
+	cursor, err := db.QuestionsCollection.Find(context.Background(),
+		bson.M{"_id": bson.M{"$in": questionIDs}},
+		options.Find().SetProjection(bson.M{"points": 1}))
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var questions []models.Question
+	if err := cursor.All(context.Background(), &questions); err != nil {
+		return nil, err
+	}
+	for _, question := range questions {
+		points[question.ID] = float64(question.Points)
+	}
+	return points, nil
+}
@@ -3,6 +3,7 @@ package handlers
 import (
 	"context"
 	"net/http"
+	"strings"
 	"time"
 
 	"qms-backend/db"
@@ -81,11 +82,16 @@ func CreateStudent(c *fiber.Ctx) error {
 		})
 	}
 
+	student.BasicInfo.Email = normalizeStudentEmail(student.BasicInfo.Email)
+
 	// Set timestamps
 	student.CreatedAt = time.Now()
 	student.UpdatedAt = time.Now()
 
 	result, err := db.StudentsCollection.InsertOne(context.Background(), student)
+	if mongo.IsDuplicateKeyError(err) {
+		return studentEmailConflict(c)
+	}
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -100,6 +106,22 @@ func CreateStudent(c *fiber.Ctx) error {
 	return c.Status(http.StatusCreated).JSON(student)
 }
 
+// normalizeStudentEmail trims and lowercases an email, so the unique index sees
+// addresses differing only in case or surrounding spaces as the same
+func normalizeStudentEmail(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// studentEmailConflict writes the response for a student email that is already
+// taken, which the unique index on basicInfo.email reports as a duplicate key
+func studentEmailConflict(c *fiber.Ctx) error {
+	return c.Status(http.StatusConflict).JSON(fiber.Map{
+		"success": false,
+		"message": "Email already in use",
+		"error":   "Another student already has this email",
+	})
+}
+
 // UpdateStudent updates a student
 func UpdateStudent(c *fiber.Ctx) error {
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
@@ -139,6 +161,16 @@ func UpdateStudent(c *fiber.Ctx) error {
 		})
 	}
 
+	// The email may be sent within basicInfo or as a dotted path
+	if basicInfo, ok := (*updates)["basicInfo"].(map[string]interface{}); ok {
+		if email, ok := basicInfo["email"].(string); ok {
+			basicInfo["email"] = normalizeStudentEmail(email)
+		}
+	}
+	if email, ok := (*updates)["basicInfo.email"].(string); ok {
+		(*updates)["basicInfo.email"] = normalizeStudentEmail(email)
+	}
+
 	// Add updated time to the updates
 	(*updates)["updatedAt"] = time.Now()
 
@@ -148,6 +180,9 @@ func UpdateStudent(c *fiber.Ctx) error {
 	}
 
 	result, err := db.StudentsCollection.UpdateOne(context.Background(), bson.M{"_id": id}, update)
+	if mongo.IsDuplicateKeyError(err) {
+		return studentEmailConflict(c)
+	}
 	if err != nil {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{
 			"success": false,
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"strconv"
+	"sync"
+)
+
+// submissionGuard limits how many code executions a single user can have in
+// flight at once, so parallel submissions can't probe hidden test cases or flood
+// the executor
+type submissionGuard struct {
+	once     sync.Once
+	mu       sync.Mutex
+	limit    int
+	inFlight map[string]int
+}
+
+// challengeSubmissions guards SubmitChallengeAttempt
+var challengeSubmissions = &submissionGuard{}
+
+// init reads the limit lazily, after main has loaded the .env file.
+// MAX_CONCURRENT_SUBMISSIONS_PER_USER defaults to 1; 0 disables the guard.
+func (g *submissionGuard) init() {
+	g.once.Do(func() {
+		g.limit = 1
+		if limit, err := strconv.Atoi(getEnvWithDefault("MAX_CONCURRENT_SUBMISSIONS_PER_USER", "1")); err == nil && limit >= 0 {
+			g.limit = limit
+		}
+		g.inFlight = make(map[string]int)
+	})
+}
+
+// acquire reserves an execution slot for key, reporting false when the user is
+// already at the limit. Every successful acquire must be paired with release.
+func (g *submissionGuard) acquire(key string) bool {
+	g.init()
+	if g.limit == 0 {
+		return true
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inFlight[key] >= g.limit {
+		return false
+	}
+	g.inFlight[key]++
+	return true
+}
+
+// release frees a slot taken by acquire
+func (g *submissionGuard) release(key string) {
+	if g.limit == 0 {
+		return
+	}
+
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.inFlight[key] <= 1 {
+		delete(g.inFlight, key)
+		return
+	}
+	g.inFlight[key]--
+}
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// maxGeneratedQuestions caps how many questions one generated test may sample
+const maxGeneratedQuestions = 200
+
+// questionCriteriaFilter converts criteria into a question bank filter,
+// normalizing values the way questions store them
+func questionCriteriaFilter(criteria models.QuestionCriteria) (bson.M, error) {
+	filter := bson.M{}
+	if questionType := strings.ToLower(strings.TrimSpace(criteria.Type)); questionType != "" {
+		if !validQuestionTypes[questionType] {
+			return nil, fmt.Errorf("Invalid type %q (use mcq, multi_mcq, subjective, short_answer or coding)", criteria.Type)
+		}
+		filter["type"] = questionType
+	}
+	if difficulty := models.NormalizeQuestionDifficulty(criteria.Difficulty); difficulty != "" {
+		if err := validateDifficulty(difficulty); err != nil {
+			return nil, err
+		}
+		filter["difficulty"] = difficulty
+	}
+	if category := strings.TrimSpace(criteria.Category); category != "" {
+		filter["category"] = category
+	}
+	tagged := models.Question{Tags: criteria.Tags}
+	normalizeQuestionTopics(&tagged)
+	if len(tagged.Tags) > 0 {
+		filter["tags"] = bson.M{"$all": tagged.Tags}
+	}
+	return filter, nil
+}
+
+// GenerateTest creates a test from Criteria.Count questions sampled at random
+// from those matching the criteria, and returns it hydrated. It fails with 422,
+// creating nothing, when fewer questions match than were requested.
+func GenerateTest(c *fiber.Ctx) error {
+	var req models.GenerateTestRequest
+	if err := c.BodyParser(&req); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": fmt.Sprintf("Invalid test data: %v", err)})
+	}
+
+	startTime, endTime, err := validateTestSettings(req.CreateTestRequest)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	count := req.Criteria.Count
+	if count <= 0 || count > maxGeneratedQuestions {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{
+			"error": fmt.Sprintf("Question count must be between 1 and %d", maxGeneratedQuestions),
+		})
+	}
+	filter, err := questionCriteriaFilter(req.Criteria)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+
+	available, err := db.QuestionsCollection.CountDocuments(context.Background(), filter)
+	if err != nil {
+		log.Printf("Failed to count questions for generated test: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to count matching questions"})
+	}
+	if available < int64(count) {
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error":     fmt.Sprintf("Only %d questions match the criteria, %d requested", available, count),
+			"available": available,
+			"requested": count,
+		})
+	}
+
+	// $sample never picks the same document twice within one stage
+	cursor, err := db.QuestionsCollection.Aggregate(context.Background(), mongo.Pipeline{
+		{{Key: "$match", Value: filter}},
+		{{Key: "$sample", Value: bson.M{"size": count}}},
+		{{Key: "$project", Value: bson.M{"_id": 1}}},
+	})
+	if err != nil {
+		log.Printf("Failed to sample questions for generated test: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to select questions"})
+	}
+	var sampled []struct {
+		ID primitive.ObjectID `bson:"_id"`
+	}
+	if err := cursor.All(context.Background(), &sampled); err != nil {
+		log.Printf("Failed to decode sampled questions: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to select questions"})
+	}
+	// Questions deleted between the count and the sample leave the test short
+	if len(sampled) < count {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "The question bank changed while selecting questions, please try again",
+		})
+	}
+
+	questionIDs := make([]primitive.ObjectID, len(sampled))
+	for i, q := range sampled {
+		questionIDs[i] = q.ID
+	}
+	return insertTest(c, newTestBSON(c, req.CreateTestRequest, startTime, endTime, questionIDs))
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// studentViewRules describes the transformations testWithoutAnswerKeys applies, in order
+var studentViewRules = []string{
+	"correctOption, correctAnswer and acceptedAnswers are removed from every question",
+	"explanations and references are removed until the test closes",
+	"hidden test cases are removed from coding questions",
+	"question order is the test's own; every student gets the same order",
+	"MCQ option order is preserved because answers are graded by option index",
+}
+
+// withoutAnswerKey returns q without anything that gives its answer away: the
+// correct options and answers, explanations, references and hidden test cases
+func withoutAnswerKey(q models.Question) models.Question {
+	q.CorrectOption = 0
+	q.CorrectOptions = nil
+	q.CorrectAnswer = ""
+	q.AcceptedAnswers = nil
+	q.Explanation = ""
+	q.References = nil
+	if len(q.TestCases) > 0 {
+		visible := make([]models.TestCase, 0, len(q.TestCases))
+		for _, tc := range q.TestCases {
+			if !tc.Hidden {
+				visible = append(visible, tc)
+			}
+		}
+		q.TestCases = visible
+	}
+	return q
+}
+
+// testWithoutAnswerKeys returns a copy of a hydrated test with withoutAnswerKey
+// applied to every question, for listings shown to students
+func testWithoutAnswerKeys(test models.Test) models.Test {
+	questions := make([]models.Question, len(test.Questions))
+	for i, q := range test.Questions {
+		questions[i] = withoutAnswerKey(q)
+	}
+	test.Questions = questions
+	return test
+}
+
+// PreviewTest returns a test exactly as a student would receive it, without
+// recording an attempt
+func PreviewTest(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var testBSON models.TestBSON
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&testBSON)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test %s for preview: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test"})
+	}
+
+	test, err := hydrateTest(testBSON)
+	if err != nil {
+		log.Printf("Failed to hydrate test %s for preview: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare test preview"})
+	}
+
+	return c.JSON(fiber.Map{
+		"test":           testWithoutAnswerKeys(test),
+		"appliedRules":   studentViewRules,
+		"totalQuestions": len(test.Questions),
+	})
+}
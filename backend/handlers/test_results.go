@@ -1,26 +1,56 @@
 package handlers
 
 import (
+	"bufio"
 	"context"
+	"encoding/csv"
+	"fmt"
 	"log"
 	"net/http"
 	"qms-backend/db"
 	"qms-backend/models"
-	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
-// GetTestResults handles fetching all test results
+// submissionAward returns the points a submission earned for one answer. Coding
+// answers can only be graded by executing them, so their stored result is used,
+// and pending reports that it hasn't been executed yet.
+func submissionAward(attempt models.TestSubmission, question models.Question, answer models.Answer) (awarded float64, pending bool) {
+	if question.Type != "coding" {
+		awarded, _ = gradeAnswer(question, answer.Answer)
+		return awarded, false
+	}
+	for _, result := range attempt.QuestionResults {
+		if result.QuestionID == answer.QuestionID {
+			return result.AwardedPoints, result.Status == models.GradingPending
+		}
+	}
+	return 0, false
+}
+
+// GetTestResults handles fetching all test results, optionally only those
+// submitted between ?from= and ?to=. With ?format=csv the results are streamed
+// as a CSV download instead.
 func GetTestResults(c *fiber.Ctx) error {
+	window, err := parseTimeWindow(c)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	if c.Query("format") == "csv" {
+		return exportTestResultsCSV(c, window)
+	}
+
 	var attempts []models.TestSubmission
 	cursor, err := db.AttemptCollection.Find(
 		context.Background(),
-		bson.M{},
+		window.apply(bson.M{}, "submittedAt"),
 		options.Find().SetSort(bson.D{{Key: "submittedAt", Value: -1}}),
 	)
 	if err != nil {
@@ -34,78 +64,226 @@ func GetTestResults(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode test results"})
 	}
 
-	// Convert attempts to response format
-	var results []fiber.Map
+	results, err := newResultLookup().results(attempts)
+	if err != nil {
+		log.Printf("Failed to fetch tests and questions for results: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test results"})
+	}
+	return c.JSON(results)
+}
+
+// resultLookup holds the tests and questions that results are scored against,
+// so each is fetched once per request however many attempts reference it
+type resultLookup struct {
+	tests     map[string]models.TestBSON
+	questions map[string]models.Question
+	// fetched records every ID already looked up, including ones that no longer exist
+	fetched map[string]bool
+}
+
+func newResultLookup() *resultLookup {
+	return &resultLookup{
+		tests:     make(map[string]models.TestBSON),
+		questions: make(map[string]models.Question),
+		fetched:   make(map[string]bool),
+	}
+}
+
+// load fetches, with one query for tests and one for questions, everything the
+// attempts reference that hasn't been looked up yet. Questions are only needed
+// to score attempts stored without their score.
+func (l *resultLookup) load(attempts []models.TestSubmission) error {
+	var testIDs, questionIDs []primitive.ObjectID
 	for _, attempt := range attempts {
-		// Get test details
-		var test models.TestBSON
-		testID, err := primitive.ObjectIDFromHex(attempt.TestID)
-		if err != nil {
-			log.Printf("Invalid test ID format: %v", err)
-			continue
+		if id, err := primitive.ObjectIDFromHex(attempt.TestID); err == nil && !l.fetched[attempt.TestID] {
+			l.fetched[attempt.TestID] = true
+			testIDs = append(testIDs, id)
 		}
-		err = db.TestsCollection.FindOne(context.Background(), bson.M{"_id": testID}).Decode(&test)
-		if err != nil {
-			log.Printf("Failed to fetch test details: %v", err)
+		if attempt.Status != "" {
 			continue
 		}
-
-		// Calculate total points and scored points
-		totalPoints := 0
-		scoredPoints := 0
 		for _, answer := range attempt.Answers {
-			// Get question details
-			var question models.Question
-			questionID, err := primitive.ObjectIDFromHex(answer.QuestionID)
-			if err != nil {
-				log.Printf("Invalid question ID format: %v", err)
-				continue
+			if id, err := primitive.ObjectIDFromHex(answer.QuestionID); err == nil && !l.fetched[answer.QuestionID] {
+				l.fetched[answer.QuestionID] = true
+				questionIDs = append(questionIDs, id)
 			}
-			err = db.QuestionsCollection.FindOne(context.Background(), bson.M{"_id": questionID}).Decode(&question)
+		}
+	}
+
+	if len(testIDs) > 0 {
+		cursor, err := db.TestsCollection.Find(context.Background(), bson.M{"_id": bson.M{"$in": testIDs}})
+		if err != nil {
+			return err
+		}
+		var tests []models.TestBSON
+		if err := cursor.All(context.Background(), &tests); err != nil {
+			return err
+		}
+		for _, test := range tests {
+			l.tests[test.ID.Hex()] = test
+		}
+	}
+
+	// Questions go through the shared cache that test hydration uses
+	questions, err := testQuestions.getMany(questionIDs)
+	if err != nil {
+		return err
+	}
+	for _, question := range questions {
+		l.questions[question.ID.Hex()] = question
+	}
+	return nil
+}
+
+// results loads what the attempts reference and scores each of them
+func (l *resultLookup) results(attempts []models.TestSubmission) ([]fiber.Map, error) {
+	if err := l.load(attempts); err != nil {
+		return nil, err
+	}
+	var results []fiber.Map
+	for _, attempt := range attempts {
+		if result, ok := l.entry(attempt); ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// entry scores one attempt for the results listing. It reports false when the
+// attempt's test can't be found.
+func (l *resultLookup) entry(attempt models.TestSubmission) (fiber.Map, bool) {
+	test, ok := l.tests[attempt.TestID]
+	if !ok {
+		log.Printf("Test %s of attempt %s not found", attempt.TestID, attempt.ID)
+		return nil, false
+	}
+
+	// Attempts stored before scores were are scored from their questions
+	scoredPoints := attempt.PointsScored
+	totalPoints := attempt.TotalPoints
+	percentageScore := attempt.PercentageScore
+	pendingQuestions := pendingResults(attempt.QuestionResults)
+	if attempt.Status == "" {
+		scoredPoints, totalPoints, pendingQuestions = submissionScore(attempt, l.questions)
+		percentageScore = percentOf(scoredPoints, float64(totalPoints))
+	}
+
+	// The pass mark may have changed since grading, so the status is derived again.
+	// Scores are partial until every coding answer has been executed.
+	status := submissionStatus(scoredPoints, totalPoints, test.PassMark(), pendingQuestions)
+	gradingStatus := models.GradingComplete
+	if pendingQuestions > 0 {
+		gradingStatus = models.GradingPending
+	}
+
+	return fiber.Map{
+		"studentId":        attempt.StudentID,
+		"studentName":      attempt.StudentName,
+		"studentEmail":     attempt.StudentEmail,
+		"testId":           attempt.TestID,
+		"testTitle":        test.Title,
+		"status":           status,
+		"percentageScore":  percentageScore,
+		"passThreshold":    test.PassMark(),
+		"pointsScored":     roundPoints(scoredPoints),
+		"totalPoints":      totalPoints,
+		"timeSpent":        attempt.TimeSpent,
+		"untimed":          attempt.Untimed,
+		"submittedAt":      attempt.SubmittedAt.Format(time.RFC3339),
+		"answers":          attempt.Answers,
+		"gradingStatus":    gradingStatus,
+		"pendingQuestions": pendingQuestions,
+	}, true
+}
+
+// testResultsCSVColumns are the columns of the CSV export, in order; each is a
+// key of resultLookup.entry's map
+var testResultsCSVColumns = []string{
+	"studentId", "studentName", "studentEmail", "testId", "testTitle", "status",
+	"percentageScore", "pointsScored", "totalPoints", "timeSpent", "submittedAt",
+}
+
+// csvExportBatchSize is how many attempts the CSV export scores at a time
+const csvExportBatchSize = 100
+
+// exportTestResultsCSV streams every test result as CSV, writing each row as
+// its attempt is read rather than loading them all first
+func exportTestResultsCSV(c *fiber.Ctx, window timeWindow) error {
+	cursor, err := db.AttemptCollection.Find(
+		context.Background(),
+		window.apply(bson.M{}, "submittedAt"),
+		options.Find().SetSort(bson.D{{Key: "submittedAt", Value: -1}}),
+	)
+	if err != nil {
+		log.Printf("Failed to fetch test attempts: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test results"})
+	}
+
+	filename := fmt.Sprintf("test-results-%s.csv", time.Now().UTC().Format("20060102"))
+	c.Set(fiber.HeaderContentType, "text/csv; charset=utf-8")
+	c.Set(fiber.HeaderContentDisposition, fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Context().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cursor.Close(context.Background())
+
+		// encoding/csv quotes fields containing commas, quotes or newlines
+		out := csv.NewWriter(w)
+		out.Write(testResultsCSVColumns)
+
+		// Attempts are scored a batch at a time so lookups stay batched too
+		lookup := newResultLookup()
+		batch := make([]models.TestSubmission, 0, csvExportBatchSize)
+		writeBatch := func() bool {
+			results, err := lookup.results(batch)
+			batch = batch[:0]
 			if err != nil {
-				log.Printf("Failed to fetch question details: %v", err)
-				continue
+				log.Printf("Failed to fetch tests and questions for CSV export: %v", err)
+				return false
 			}
-
-			totalPoints += question.Points
-			if question.Type == "mcq" {
-				selectedIndex, err := strconv.ParseInt(answer.Answer, 10, 64)
-				if err == nil && int(selectedIndex) == question.CorrectOption {
-					scoredPoints += question.Points
+			for _, result := range results {
+				row := make([]string, len(testResultsCSVColumns))
+				for i, column := range testResultsCSVColumns {
+					row[i] = csvCell(result[column])
 				}
+				out.Write(row)
 			}
+			out.Flush()
+			if err := out.Error(); err != nil {
+				// The client went away
+				log.Printf("Stopped CSV export: %v", err)
+				return false
+			}
+			return true
 		}
 
-		percentageScore := 0.0
-		if totalPoints > 0 {
-			percentageScore = float64(scoredPoints) / float64(totalPoints) * 100
+		for cursor.Next(context.Background()) {
+			var attempt models.TestSubmission
+			if err := cursor.Decode(&attempt); err != nil {
+				log.Printf("Failed to decode test attempt for CSV export: %v", err)
+				continue
+			}
+			batch = append(batch, attempt)
+			if len(batch) == csvExportBatchSize && !writeBatch() {
+				return
+			}
 		}
-
-		status := "Submitted"
-		if percentageScore >= 70 {
-			status = "Passed"
-		} else if percentageScore > 0 {
-			status = "Failed"
+		if err := cursor.Err(); err != nil {
+			log.Printf("Test results CSV export ended early: %v", err)
 		}
-
-		result := fiber.Map{
-			"studentId":       attempt.StudentID,
-			"studentName":     attempt.StudentName,
-			"studentEmail":    attempt.StudentEmail,
-			"testId":          attempt.TestID,
-			"testTitle":       test.Title,
-			"status":          status,
-			"percentageScore": percentageScore,
-			"pointsScored":    scoredPoints,
-			"totalPoints":     totalPoints,
-			"timeSpent":       attempt.TimeSpent,
-			"submittedAt":     attempt.SubmittedAt.Format(time.RFC3339),
-			"answers":         attempt.Answers,
+		if len(batch) > 0 {
+			writeBatch()
 		}
-		results = append(results, result)
-	}
+	})
+	return nil
+}
 
-	return c.JSON(results)
+// csvCell formats a value for the CSV export. Text that a spreadsheet would
+// run as a formula (e.g. a name starting with "=") is prefixed with a quote.
+func csvCell(value interface{}) string {
+	cell := fmt.Sprint(value)
+	if s, ok := value.(string); ok && s != "" && strings.ContainsRune("=+-@\t\r", rune(s[0])) {
+		cell = "'" + s
+	}
+	return cell
 }
 
 // GetTestResultsByStudent handles fetching test results for a specific student
@@ -132,75 +310,44 @@ func GetTestResultsByStudent(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode student results"})
 	}
 
-	// Convert attempts to response format (same logic as GetTestResults)
-	var results []fiber.Map
-	for _, attempt := range attempts {
-		var test models.TestBSON
-		testID, err := primitive.ObjectIDFromHex(attempt.TestID)
+	// No attempts is only a valid answer for a student that exists; otherwise the
+	// ID is most likely a typo
+	if len(attempts) == 0 {
+		exists, err := studentExists(studentId)
 		if err != nil {
-			log.Printf("Invalid test ID format: %v", err)
-			continue
+			log.Printf("Failed to look up student %s: %v", studentId, err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch student results"})
 		}
-		err = db.TestsCollection.FindOne(context.Background(), bson.M{"_id": testID}).Decode(&test)
-		if err != nil {
-			log.Printf("Failed to fetch test details: %v", err)
-			continue
-		}
-
-		totalPoints := 0
-		scoredPoints := 0
-		for _, answer := range attempt.Answers {
-			var question models.Question
-			questionID, err := primitive.ObjectIDFromHex(answer.QuestionID)
-			if err != nil {
-				log.Printf("Invalid question ID format: %v", err)
-				continue
-			}
-			err = db.QuestionsCollection.FindOne(context.Background(), bson.M{"_id": questionID}).Decode(&question)
-			if err != nil {
-				log.Printf("Failed to fetch question details: %v", err)
-				continue
-			}
-
-			totalPoints += question.Points
-			if question.Type == "mcq" {
-				selectedIndex, err := strconv.ParseInt(answer.Answer, 10, 64)
-				if err == nil && int(selectedIndex) == question.CorrectOption {
-					scoredPoints += question.Points
-				}
-			}
+		if !exists {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Student not found"})
 		}
+	}
 
-		percentageScore := 0.0
-		if totalPoints > 0 {
-			percentageScore = float64(scoredPoints) / float64(totalPoints) * 100
-		}
+	results, err := newResultLookup().results(attempts)
+	if err != nil {
+		log.Printf("Failed to fetch tests and questions for student results: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch student results"})
+	}
+	return c.JSON(results)
+}
 
-		status := "Submitted"
-		if percentageScore >= 70 {
-			status = "Passed"
-		} else if percentageScore > 0 {
-			status = "Failed"
+// studentExists reports whether id belongs to a student profile or a user
+// account; tests are submitted under either
+func studentExists(id string) (bool, error) {
+	objID, err := primitive.ObjectIDFromHex(id)
+	if err != nil {
+		return false, nil
+	}
+	for _, collection := range []*mongo.Collection{db.StudentsCollection, db.UsersCollection} {
+		count, err := collection.CountDocuments(context.Background(), bson.M{"_id": objID}, options.Count().SetLimit(1))
+		if err != nil {
+			return false, err
 		}
-
-		result := fiber.Map{
-			"studentId":       attempt.StudentID,
-			"studentName":     attempt.StudentName,
-			"studentEmail":    attempt.StudentEmail,
-			"testId":          attempt.TestID,
-			"testTitle":       test.Title,
-			"status":          status,
-			"percentageScore": percentageScore,
-			"pointsScored":    scoredPoints,
-			"totalPoints":     totalPoints,
-			"timeSpent":       attempt.TimeSpent,
-			"submittedAt":     attempt.SubmittedAt.Format(time.RFC3339),
-			"answers":         attempt.Answers,
+		if count > 0 {
+			return true, nil
 		}
-		results = append(results, result)
 	}
-
-	return c.JSON(results)
+	return false, nil
 }
 
 // GetTestResultsByTest handles fetching test results for a specific test
@@ -240,61 +387,13 @@ func GetTestResultsByTest(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test details"})
 	}
 
-	// Convert attempts to response format
-	var results []fiber.Map
-	for _, attempt := range attempts {
-		totalPoints := 0
-		scoredPoints := 0
-		for _, answer := range attempt.Answers {
-			var question models.Question
-			questionID, err := primitive.ObjectIDFromHex(answer.QuestionID)
-			if err != nil {
-				log.Printf("Invalid question ID format: %v", err)
-				continue
-			}
-			err = db.QuestionsCollection.FindOne(context.Background(), bson.M{"_id": questionID}).Decode(&question)
-			if err != nil {
-				log.Printf("Failed to fetch question details: %v", err)
-				continue
-			}
-
-			totalPoints += question.Points
-			if question.Type == "mcq" {
-				selectedIndex, err := strconv.ParseInt(answer.Answer, 10, 64)
-				if err == nil && int(selectedIndex) == question.CorrectOption {
-					scoredPoints += question.Points
-				}
-			}
-		}
-
-		percentageScore := 0.0
-		if totalPoints > 0 {
-			percentageScore = float64(scoredPoints) / float64(totalPoints) * 100
-		}
-
-		status := "Submitted"
-		if percentageScore >= 70 {
-			status = "Passed"
-		} else if percentageScore > 0 {
-			status = "Failed"
-		}
-
-		result := fiber.Map{
-			"studentId":       attempt.StudentID,
-			"studentName":     attempt.StudentName,
-			"studentEmail":    attempt.StudentEmail,
-			"testId":          attempt.TestID,
-			"testTitle":       test.Title,
-			"status":          status,
-			"percentageScore": percentageScore,
-			"pointsScored":    scoredPoints,
-			"totalPoints":     totalPoints,
-			"timeSpent":       attempt.TimeSpent,
-			"submittedAt":     attempt.SubmittedAt.Format(time.RFC3339),
-			"answers":         attempt.Answers,
-		}
-		results = append(results, result)
+	lookup := newResultLookup()
+	lookup.tests[testId] = test
+	lookup.fetched[testId] = true
+	results, err := lookup.results(attempts)
+	if err != nil {
+		log.Printf("Failed to fetch questions for test results: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test results"})
 	}
-
 	return c.JSON(results)
 }
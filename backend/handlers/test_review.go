@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// ReviewQuestion is one entry of a closed test's answer key
+type ReviewQuestion struct {
+	models.Question
+	// Set when the review is requested for a student who submitted the test
+	StudentAnswer *string                `json:"studentAnswer,omitempty"`
+	Result        *models.QuestionResult `json:"result,omitempty"`
+}
+
+// GetTestReview returns a test's answer key with each question's explanation and
+// references. It's only available once the test has closed, so none of it can
+// leak to students still taking it. Students get their own answers and grading
+// alongside each question; staff pick the student with ?studentId=.
+func GetTestReview(c *fiber.Ctx) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var testBSON models.TestBSON
+	if err := db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&testBSON); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Error fetching test %s for review: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test"})
+	}
+	staff := isStaffRequest(c)
+	studentID, _ := c.Locals("userId").(string)
+	if staff {
+		studentID = c.Query("studentId")
+	} else if !isAllowedStudent(testBSON, studentID) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You are not assigned to this test"})
+	}
+	if time.Now().UTC().Before(testBSON.EndTime) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error":       "The review is available once the test has closed",
+			"availableAt": testBSON.EndTime.UTC(),
+		})
+	}
+
+	test, err := hydrateTest(testBSON)
+	if err != nil {
+		log.Printf("Failed to hydrate test %s for review: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare test review"})
+	}
+
+	answers := map[string]string{}
+	results := map[string]models.QuestionResult{}
+	if studentID != "" {
+		var submission models.TestSubmission
+		err := db.AttemptCollection.FindOne(context.Background(), bson.M{"testId": id.Hex(), "studentId": studentID}).Decode(&submission)
+		if err != nil && err != mongo.ErrNoDocuments {
+			log.Printf("Error fetching submission of student %s for test %s: %v", studentID, id.Hex(), err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch submission"})
+		}
+		for _, answer := range submission.Answers {
+			answers[answer.QuestionID] = answer.Answer
+		}
+		for _, result := range submission.QuestionResults {
+			results[result.QuestionID] = result
+		}
+	}
+
+	questions := make([]ReviewQuestion, len(test.Questions))
+	for i, q := range test.Questions {
+		questions[i] = ReviewQuestion{Question: q}
+		if answer, ok := answers[q.ID.Hex()]; ok {
+			questions[i].StudentAnswer = &answer
+		}
+		if result, ok := results[q.ID.Hex()]; ok {
+			result.AwardedPoints = roundPoints(result.AwardedPoints)
+			questions[i].Result = &result
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"testId":    test.ID,
+		"title":     test.Title,
+		"endTime":   test.EndTime,
+		"questions": questions,
+	})
+}
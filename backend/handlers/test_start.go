@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// submissionGrace absorbs network latency for answers sent right at the deadline
+const submissionGrace = 30 * time.Second
+
+// lateStartCutoff is the last moment a student may start the test
+func lateStartCutoff(test models.TestBSON) time.Time {
+	cutoff := test.StartTime.Add(time.Duration(test.LateStartMins) * time.Minute)
+	if cutoff.After(test.EndTime) {
+		return test.EndTime
+	}
+	return cutoff
+}
+
+// personalDeadline is when a student who started at startedAt runs out of time:
+// Duration after their own start, capped at EndTime. Late-start tests differ only
+// in how long after StartTime a student may still start.
+func personalDeadline(test models.TestBSON, startedAt time.Time) time.Time {
+	if test.Duration <= 0 {
+		return test.EndTime
+	}
+	deadline := startedAt.Add(time.Duration(test.Duration) * time.Minute)
+	if deadline.After(test.EndTime) {
+		return test.EndTime
+	}
+	return deadline
+}
+
+// isAllowedStudent reports whether studentID may take the test. Tests without
+// an AllowedStudents list are open to everyone.
+func isAllowedStudent(test models.TestBSON, studentID string) bool {
+	if len(test.AllowedStudents) == 0 {
+		return true
+	}
+	for _, allowed := range test.AllowedStudents {
+		if allowed == studentID {
+			return true
+		}
+	}
+	return false
+}
+
+// enforceSubmissionWindow checks that studentID is assigned to the test and that
+// it is open, writing the error response itself when not
+func enforceSubmissionWindow(c *fiber.Ctx, test models.TestBSON, studentID string) bool {
+	if !isAllowedStudent(test, studentID) {
+		c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You are not assigned to this test"})
+		return false
+	}
+	now := time.Now()
+	if now.Before(test.StartTime) {
+		c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Test has not started yet"})
+		return false
+	}
+	if now.After(test.EndTime.Add(submissionGrace)) {
+		c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Test has ended"})
+		return false
+	}
+	return true
+}
+
+// enforcePersonalDeadline checks that a student is still within their own time,
+// measured from the start the server recorded, writing the error response itself
+// when not. Late-start tests must have been started; on fixed-window tests a
+// student without a recorded start can't be timed and passes.
+func enforcePersonalDeadline(c *fiber.Ctx, test models.TestBSON, studentID string) bool {
+	progress, err := findTestProgress(test.ID.Hex(), studentID)
+	if err != nil {
+		log.Printf("Failed to fetch start time for student %s on test %s: %v", studentID, test.ID.Hex(), err)
+		c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to check test deadline"})
+		return false
+	}
+	if progress == nil {
+		if test.LateStartMins <= 0 {
+			return true
+		}
+		c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Test has not been started"})
+		return false
+	}
+	deadline := personalDeadline(test, progress.StartedAt)
+	if time.Now().After(deadline.Add(submissionGrace)) {
+		c.Status(http.StatusForbidden).JSON(fiber.Map{
+			"error":            "Time is up for this test",
+			"personalDeadline": deadline.UTC(),
+		})
+		return false
+	}
+	return true
+}
+
+// StartTest records the moment a student begins a test and returns their
+// personal deadline. Starting again returns the original start time. The test
+// is always started for the caller.
+func StartTest(c *fiber.Ctx) error {
+	studentID, _ := c.Locals("userId").(string)
+
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid test ID"})
+	}
+	var test models.TestBSON
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&test)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test %s: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start test"})
+	}
+	if !isAllowedStudent(test, studentID) {
+		return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You are not assigned to this test"})
+	}
+
+	progress, err := findTestProgress(id.Hex(), studentID)
+	if err != nil {
+		log.Printf("Failed to fetch progress for student %s on test %s: %v", studentID, id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start test"})
+	}
+
+	if progress == nil {
+		now := time.Now()
+		if now.Before(test.StartTime) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Test has not started yet"})
+		}
+		if !now.Before(test.EndTime) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "Test has ended"})
+		}
+		if test.LateStartMins > 0 && now.After(lateStartCutoff(test)) {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "The window for starting this test has closed"})
+		}
+
+		progress, err = recordTestStart(id.Hex(), studentID, now)
+		if err != nil {
+			log.Printf("Failed to record start for student %s on test %s: %v", studentID, id.Hex(), err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to start test"})
+		}
+	}
+
+	return c.JSON(fiber.Map{
+		"testId":           id.Hex(),
+		"studentId":        studentID,
+		"startedAt":        progress.StartedAt.UTC(),
+		"personalDeadline": personalDeadline(test, progress.StartedAt).UTC(),
+	})
+}
+
+// recordTestStart records that studentID started the test at now, unless they
+// already had. It returns the student's progress with the start that counts.
+func recordTestStart(testID, studentID string, now time.Time) (*models.TestProgress, error) {
+	// $setOnInsert keeps the first start if two requests race
+	var started models.TestProgress
+	err := db.TestProgressCollection.FindOneAndUpdate(context.Background(),
+		bson.M{"testId": testID, "studentId": studentID},
+		bson.M{"$setOnInsert": bson.M{"startedAt": now, "answers": []models.Answer{}}},
+		options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After),
+	).Decode(&started)
+	if err != nil {
+		return nil, err
+	}
+	return &started, nil
+}
+
+// canStartNow reports whether a student who hasn't started the test yet may
+// start it at now
+func canStartNow(test models.TestBSON, now time.Time) bool {
+	if now.Before(test.StartTime) || !now.Before(test.EndTime) {
+		return false
+	}
+	return test.LateStartMins <= 0 || !now.After(lateStartCutoff(test))
+}
+
+// serverTimeSpent returns the seconds between the student's recorded start and
+// submittedAt, or nil when the server never saw them start
+func serverTimeSpent(progress *models.TestProgress, submittedAt time.Time) *int {
+	if progress == nil {
+		return nil
+	}
+	spent := int(submittedAt.Sub(progress.StartedAt).Seconds())
+	return &spent
+}
+
+// studentStartTimes returns when studentID started each of the given tests
+func studentStartTimes(tests []models.TestBSON, studentID string) (map[string]time.Time, error) {
+	testIDs := make([]string, len(tests))
+	for i, t := range tests {
+		testIDs[i] = t.ID.Hex()
+	}
+	cursor, err := db.TestProgressCollection.Find(context.Background(), bson.M{
+		"studentId": studentID,
+		"testId":    bson.M{"$in": testIDs},
+	})
+	if err != nil {
+		return nil, err
+	}
+	defer cursor.Close(context.Background())
+
+	var progress []models.TestProgress
+	if err := cursor.All(context.Background(), &progress); err != nil {
+		return nil, err
+	}
+	started := make(map[string]time.Time, len(progress))
+	for _, p := range progress {
+		started[p.TestID] = p.StartedAt
+	}
+	return started, nil
+}
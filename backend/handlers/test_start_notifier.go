@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"time"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// StartTestStartNotifier announces each scheduled test over the hub once its
+// start time passes, so clients waiting in a lobby can enter it. It checks every
+// interval until ctx is cancelled.
+func StartTestStartNotifier(ctx context.Context, hub *Hub, interval time.Duration) {
+	workers.Add(1)
+	go func() {
+		defer workers.Done()
+		fmt.Printf("Starting test start notifier (check every %s)...\n", interval)
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		// Start time each test was announced with. A rescheduled test is announced
+		// again at its new start time.
+		announced := make(map[string]time.Time)
+		for {
+			select {
+			case <-ctx.Done():
+				fmt.Println("Stopping test start notifier")
+				return
+			case <-ticker.C:
+				announceStartedTests(ctx, hub, interval, announced)
+			}
+		}
+	}()
+}
+
+// announceStartedTests broadcasts the tests that started within the last two
+// intervals and haven't been announced yet. Looking back two intervals covers a
+// check that ran late.
+func announceStartedTests(ctx context.Context, hub *Hub, interval time.Duration, announced map[string]time.Time) {
+	now := time.Now().UTC()
+	since := now.Add(-2 * interval)
+
+	cursor, err := db.TestsCollection.Find(ctx, bson.M{
+		"startTime": bson.M{"$gt": since, "$lte": now},
+		"archived":  bson.M{"$ne": true},
+		"deletedAt": bson.M{"$exists": false},
+	})
+	if err != nil {
+		log.Printf("Failed to fetch started tests: %v", err)
+		return
+	}
+	var tests []models.TestBSON
+	if err := cursor.All(ctx, &tests); err != nil {
+		log.Printf("Failed to decode started tests: %v", err)
+		return
+	}
+
+	for _, test := range tests {
+		id := test.ID.Hex()
+		if startTime, ok := announced[id]; ok && startTime.Equal(test.StartTime) {
+			continue
+		}
+		announced[id] = test.StartTime
+		hub.BroadcastTestStarted(id)
+	}
+
+	// Tests outside the window can't match again, so they needn't be remembered
+	for id, startTime := range announced {
+		if !startTime.After(since) {
+			delete(announced, id)
+		}
+	}
+}
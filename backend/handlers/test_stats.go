@@ -0,0 +1,221 @@
+package handlers
+
+import (
+	"context"
+	"log"
+	"net/http"
+	"sort"
+
+	"qms-backend/db"
+	"qms-backend/models"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// TestOverallStats summarizes every submission of a test. Scores and the pass
+// rate only count fully graded submissions; those still waiting for coding
+// answers to run are reported as pending.
+type TestOverallStats struct {
+	Submissions    int     `json:"submissions"`
+	Graded         int     `json:"graded"`
+	Pending        int     `json:"pending"`
+	Passed         int     `json:"passed"`
+	PassRate       float64 `json:"passRate"`
+	AverageScore   float64 `json:"averageScore"`
+	MedianScore    float64 `json:"medianScore"`
+	HighestScore   float64 `json:"highestScore"`
+	LowestScore    float64 `json:"lowestScore"`
+	PassThreshold  float64 `json:"passThreshold"`
+	AverageSeconds float64 `json:"averageTimeSpent"`
+}
+
+// QuestionStats summarizes the answers given to one question of a test
+type QuestionStats struct {
+	QuestionID    string  `json:"questionId"`
+	Content       string  `json:"content"`
+	Type          string  `json:"type"`
+	Points        int     `json:"points"`
+	Answered      int     `json:"answered"`
+	Correct       int     `json:"correct"`
+	Pending       int     `json:"pending"`
+	CorrectRate   float64 `json:"correctRate"`
+	AveragePoints float64 `json:"averagePoints"`
+}
+
+// GetTestStats returns analytics for one test: submission count, score
+// distribution and pass rate overall, and how often each question was answered
+// correctly. Questions are listed in the test's order.
+func GetTestStats(c *fiber.Ctx) error {
+	testID, err := primitive.ObjectIDFromHex(c.Params("testId"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid test ID format"})
+	}
+
+	var test models.TestBSON
+	if err := db.TestsCollection.FindOne(context.Background(), bson.M{"_id": testID}).Decode(&test); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test %s for stats: %v", testID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test details"})
+	}
+
+	var attempts []models.TestSubmission
+	cursor, err := db.AttemptCollection.Find(context.Background(), bson.M{"testId": testID.Hex()})
+	if err != nil {
+		log.Printf("Failed to fetch attempts of test %s for stats: %v", testID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test results"})
+	}
+	if err := cursor.All(context.Background(), &attempts); err != nil {
+		log.Printf("Failed to decode attempts of test %s for stats: %v", testID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode test results"})
+	}
+
+	// Per-question correctness needs the questions' answer keys
+	questionList, err := testQuestions.getMany(test.Questions)
+	if err != nil {
+		log.Printf("Failed to fetch questions of test %s for stats: %v", testID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch questions"})
+	}
+	questions := make(map[string]models.Question, len(questionList))
+	for _, question := range questionList {
+		questions[question.ID.Hex()] = question
+	}
+
+	lookup := newResultLookup()
+	lookup.tests[testID.Hex()] = test
+	lookup.fetched[testID.Hex()] = true
+	for id, question := range questions {
+		lookup.questions[id] = question
+		lookup.fetched[id] = true
+	}
+	results, err := lookup.results(attempts)
+	if err != nil {
+		log.Printf("Failed to score attempts of test %s for stats: %v", testID.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test results"})
+	}
+
+	return c.JSON(fiber.Map{
+		"testId":    testID.Hex(),
+		"testTitle": test.Title,
+		"overall":   overallTestStats(test, results),
+		"questions": questionStats(test, questions, attempts),
+	})
+}
+
+// overallTestStats aggregates the scored entries of resultLookup.results
+func overallTestStats(test models.TestBSON, results []fiber.Map) TestOverallStats {
+	stats := TestOverallStats{Submissions: len(results), PassThreshold: test.PassMark()}
+	var scores []float64
+	totalSeconds := 0
+	for _, result := range results {
+		seconds, _ := result["timeSpent"].(int)
+		totalSeconds += seconds
+		if result["gradingStatus"] == models.GradingPending {
+			stats.Pending++
+			continue
+		}
+		score, _ := result["percentageScore"].(float64)
+		scores = append(scores, score)
+		if result["status"] == "Passed" {
+			stats.Passed++
+		}
+	}
+	if len(results) > 0 {
+		stats.AverageSeconds = roundTo(float64(totalSeconds)/float64(len(results)), 1)
+	}
+
+	stats.Graded = len(scores)
+	if stats.Graded == 0 {
+		return stats
+	}
+	sort.Float64s(scores)
+	sum := 0.0
+	for _, score := range scores {
+		sum += score
+	}
+	stats.PassRate = percentOf(float64(stats.Passed), float64(stats.Graded))
+	stats.AverageScore = roundPercent(sum / float64(stats.Graded))
+	stats.MedianScore = roundPercent(median(scores))
+	stats.LowestScore = scores[0]
+	stats.HighestScore = scores[len(scores)-1]
+	return stats
+}
+
+// median returns the middle of sorted values, averaging the two middle ones
+// when there is an even number
+func median(sorted []float64) float64 {
+	mid := len(sorted) / 2
+	if len(sorted)%2 == 1 {
+		return sorted[mid]
+	}
+	return (sorted[mid-1] + sorted[mid]) / 2
+}
+
+// questionStats grades every answer to the test's questions. Coding answers use
+// the result stored when they were executed and count as pending until then.
+func questionStats(test models.TestBSON, questions map[string]models.Question, attempts []models.TestSubmission) []QuestionStats {
+	stats := make([]QuestionStats, 0, len(test.Questions))
+	index := make(map[string]int, len(test.Questions))
+	for _, id := range test.Questions {
+		question, ok := questions[id.Hex()]
+		if !ok {
+			continue
+		}
+		index[id.Hex()] = len(stats)
+		stats = append(stats, QuestionStats{
+			QuestionID: id.Hex(),
+			Content:    question.Content,
+			Type:       question.Type,
+			Points:     question.Points,
+		})
+	}
+
+	awarded := make([]float64, len(stats))
+	for _, attempt := range attempts {
+		for _, answer := range attempt.Answers {
+			i, ok := index[answer.QuestionID]
+			if !ok {
+				continue
+			}
+			question := questions[answer.QuestionID]
+			points, pending := submissionAward(attempt, question, answer)
+			if pending {
+				stats[i].Pending++
+				continue
+			}
+			stats[i].Answered++
+			awarded[i] += points
+			if answerCorrect(attempt, question, answer) {
+				stats[i].Correct++
+			}
+		}
+	}
+
+	for i := range stats {
+		if stats[i].Answered == 0 {
+			continue
+		}
+		stats[i].CorrectRate = percentOf(float64(stats[i].Correct), float64(stats[i].Answered))
+		stats[i].AveragePoints = roundPoints(awarded[i] / float64(stats[i].Answered))
+	}
+	return stats
+}
+
+// answerCorrect reports whether an answer earned full marks, reading a coding
+// answer's outcome from the submission's stored results
+func answerCorrect(attempt models.TestSubmission, question models.Question, answer models.Answer) bool {
+	if question.Type != "coding" {
+		_, correct := gradeAnswer(question, answer.Answer)
+		return correct
+	}
+	for _, result := range attempt.QuestionResults {
+		if result.QuestionID == answer.QuestionID {
+			return result.Correct
+		}
+	}
+	return false
+}
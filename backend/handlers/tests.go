@@ -2,58 +2,41 @@ package handlers
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
 	"qms-backend/db"
 	"qms-backend/models"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gofiber/fiber/v2"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 // CreateTest handles the creation of a new test
 func CreateTest(c *fiber.Ctx) error {
 	fmt.Println("Creating new test...")
-	fmt.Printf("Request body: %s\n", string(c.Body()))
+	fmt.Printf("Request body: %+v\n", sanitizeBodyForLog(c.Body()))
 
 	var req models.CreateTestRequest
 	if err := c.BodyParser(&req); err != nil {
 		fmt.Printf("Error parsing test data: %v\n", err)
-		fmt.Printf("Raw request body: %s\n", string(c.Body()))
+		fmt.Printf("Raw request body: %+v\n", sanitizeBodyForLog(c.Body()))
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
 			"error": fmt.Sprintf("Invalid test data: %v", err),
 		})
 	}
 
-	// Validate required fields
-	if req.Title == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Title is required",
-		})
-	}
-	if req.Description == "" {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Description is required",
-		})
-	}
-	if req.StartTime.IsZero() {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Start time is required",
-		})
-	}
-	if req.EndTime.IsZero() {
-		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "End time is required",
-		})
-	}
-	if req.Duration <= 0 {
+	startTime, endTime, err := validateTestSettings(req)
+	if err != nil {
 		return c.Status(fiber.StatusBadRequest).JSON(fiber.Map{
-			"error": "Duration must be greater than 0",
+			"error": err.Error(),
 		})
 	}
 
@@ -69,17 +52,69 @@ func CreateTest(c *fiber.Ctx) error {
 		questionIDs = append(questionIDs, objID)
 	}
 
-	// Create TestBSON for database insertion
-	testBSON := models.TestBSON{
+	return insertTest(c, newTestBSON(c, req, startTime, endTime, questionIDs))
+}
+
+// validateTestSettings checks everything about a new test but its questions,
+// returning its window in UTC
+func validateTestSettings(req models.CreateTestRequest) (time.Time, time.Time, error) {
+	if req.Title == "" {
+		return time.Time{}, time.Time{}, errors.New("Title is required")
+	}
+	if req.Description == "" {
+		return time.Time{}, time.Time{}, errors.New("Description is required")
+	}
+	startTime, endTime, err := normalizeTestWindow(req.StartTime, req.EndTime)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+	if req.Duration <= 0 {
+		return time.Time{}, time.Time{}, errors.New("Duration must be greater than 0")
+	}
+	if !models.IsValidTestMode(req.Mode) {
+		return time.Time{}, time.Time{}, errors.New("Mode must be one of: free, sequential")
+	}
+	if req.LateStartMins < 0 {
+		return time.Time{}, time.Time{}, errors.New("Late start window cannot be negative")
+	}
+	if req.PassThreshold != nil && !validPassThreshold(*req.PassThreshold) {
+		return time.Time{}, time.Time{}, errPassThreshold
+	}
+	return startTime, endTime, nil
+}
+
+// errPassThreshold rejects a pass threshold outside (0, 100]. A test can't pass
+// everyone outright: zero is how a stored test says it uses the default.
+var errPassThreshold = errors.New("Pass threshold must be greater than 0 and at most 100")
+
+func validPassThreshold(threshold float64) bool {
+	return threshold > 0 && threshold <= 100
+}
+
+// newTestBSON builds the stored test for a validated request, owned by the caller
+func newTestBSON(c *fiber.Ctx, req models.CreateTestRequest, startTime, endTime time.Time, questionIDs []primitive.ObjectID) models.TestBSON {
+	test := models.TestBSON{
 		Title:           req.Title,
 		Description:     req.Description,
-		StartTime:       req.StartTime,
-		EndTime:         req.EndTime,
+		StartTime:       startTime,
+		EndTime:         endTime,
 		Duration:        req.Duration,
 		Questions:       questionIDs,
 		AllowedStudents: req.AllowedStudents,
+		OwnerID:         callerID(c),
+		Mode:            req.Mode,
+		LateStartMins:   req.LateStartMins,
+		UpdatedAt:       time.Now().UTC(),
 	}
+	if req.PassThreshold != nil {
+		test.PassThreshold = *req.PassThreshold
+	}
+	return test
+}
 
+// insertTest stores a new test and answers 201 with it hydrated, notifying the
+// clients watching the test list
+func insertTest(c *fiber.Ctx, testBSON models.TestBSON) error {
 	// Create test in database
 	result, err := db.TestsCollection.InsertOne(context.Background(), testBSON)
 	if err != nil {
@@ -110,7 +145,7 @@ func CreateTest(c *fiber.Ctx) error {
 		})
 	}
 
-	// Broadcast the test update to all connected clients
+	// Notify the clients watching the test list
 	if hub := c.Locals("hub"); hub != nil {
 		if h, ok := hub.(*Hub); ok {
 			fmt.Printf("Broadcasting test update for test ID: %s\n", createdTestID.Hex())
@@ -125,17 +160,66 @@ func CreateTest(c *fiber.Ctx) error {
 	return c.Status(fiber.StatusCreated).JSON(createdTest)
 }
 
-// GetTests retrieves all the tests from the database with full question details
+// normalizeTestWindow validates a test's scheduling window and converts it to UTC.
+// Times must be sent as RFC3339 with an explicit offset (e.g. "2024-05-01T09:00:00+05:30"
+// or "...Z"); all stored times and time comparisons are in UTC.
+func normalizeTestWindow(startTime, endTime time.Time) (time.Time, time.Time, error) {
+	if startTime.IsZero() {
+		return time.Time{}, time.Time{}, errors.New("Start time is required")
+	}
+	if endTime.IsZero() {
+		return time.Time{}, time.Time{}, errors.New("End time is required")
+	}
+
+	startTime = startTime.UTC()
+	endTime = endTime.UTC()
+	if !endTime.After(startTime) {
+		return time.Time{}, time.Time{}, errors.New("End time must be after start time")
+	}
+
+	return startTime, endTime, nil
+}
+
+// GetTests retrieves tests from the database with full question details. By
+// default only current, unarchived tests are listed. Staff can pass
+// ?includePast=true to also list tests that have ended, and ?archived=true to
+// list archived tests instead.
 func GetTests(c *fiber.Ctx) error {
-	now := time.Now()
+	now := time.Now().UTC()
 
 	filter := bson.M{
 		"endTime": bson.M{
 			"$gt": now,
 		},
+		"archived": bson.M{"$ne": true},
+	}
+	if isStaffRequest(c) {
+		if c.QueryBool("includePast") {
+			delete(filter, "endTime")
+		}
+		if c.QueryBool("archived") {
+			delete(filter, "endTime")
+			filter["archived"] = true
+		}
 	}
 
-	cursor, err := db.TestsCollection.Find(context.Background(), filter)
+	return listTests(c, ownerScope(c, filter), options.Find())
+}
+
+// GetTestArchive lists every test that has ended or been archived, most recent first
+func GetTestArchive(c *fiber.Ctx) error {
+	filter := ownerScope(c, bson.M{
+		"$or": []bson.M{
+			{"endTime": bson.M{"$lte": time.Now().UTC()}},
+			{"archived": true},
+		},
+	})
+	return listTests(c, filter, options.Find().SetSort(bson.D{{Key: "endTime", Value: -1}}))
+}
+
+// listTests writes the hydrated tests matching filter
+func listTests(c *fiber.Ctx, filter bson.M, opts *options.FindOptions) error {
+	cursor, err := db.TestsCollection.Find(context.Background(), notDeleted(filter), opts)
 	if err != nil {
 		log.Printf("Failed to fetch tests from DB: %v", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch tests"})
@@ -148,6 +232,7 @@ func GetTests(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to decode tests"})
 	}
 
+	staff := isStaffRequest(c)
 	var tests []models.Test // Slice to hold tests with full Question objects
 	for _, testBSON := range testsBSON {
 		test, err := hydrateTest(testBSON)
@@ -156,13 +241,17 @@ func GetTests(c *fiber.Ctx) error {
 			// Decide how to handle hydration errors for multiple tests
 			continue // Skip this test on hydration error
 		}
+		if !staff {
+			test = testWithoutAnswerKeys(test)
+		}
 		tests = append(tests, test)
 	}
 
 	return c.JSON(tests)
 }
 
-// GetTest retrieves a single test by its ID with full question details
+// GetTest retrieves a single test by its ID. Staff get full question details;
+// students get the questions without their answer keys.
 func GetTest(c *fiber.Ctx) error {
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
@@ -170,12 +259,14 @@ func GetTest(c *fiber.Ctx) error {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	now := time.Now()
+	now := time.Now().UTC()
 	filter := bson.M{
 		"_id": id,
 		"endTime": bson.M{
 			"$gt": now,
 		},
+		"archived":  bson.M{"$ne": true},
+		"deletedAt": bson.M{"$exists": false},
 	}
 
 	var testBSON models.TestBSON
@@ -196,55 +287,66 @@ func GetTest(c *fiber.Ctx) error {
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare test response"})
 	}
 
+	// The test is still open; explanations are only served by the review endpoint
+	for i := range test.Questions {
+		test.Questions[i].Explanation = ""
+		test.Questions[i].References = nil
+	}
+
+	// Students taking the test mustn't be able to read the answers from the response
+	if !isStaffRequest(c) {
+		test = testWithoutAnswerKeys(test)
+
+		// Fetching an open test starts the student's clock, so the time they spend
+		// is measured by the server rather than reported by the client
+		studentID, _ := c.Locals("userId").(string)
+		if studentID != "" && isAllowedStudent(testBSON, studentID) && canStartNow(testBSON, time.Now()) {
+			if _, err := recordTestStart(testBSON.ID.Hex(), studentID, time.Now()); err != nil {
+				log.Printf("Failed to record start for student %s on test %s: %v", studentID, testBSON.ID.Hex(), err)
+			}
+		}
+	}
+
 	return c.JSON(test)
 }
 
-// UpdateTest updates an existing test by its ID
+// UpdateTest updates an existing test by its ID. Only the fields present in the
+// request body are changed; an omitted "questions" or "allowedStudents" key keeps
+// the stored list, while an explicit empty array clears it.
 func UpdateTest(c *fiber.Ctx) error {
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	// We expect question IDs and allowed student IDs as strings in the incoming request
-	type UpdateTestRequest struct {
-		Title           string    `json:"title"`
-		Description     string    `json:"description"`
-		StartTime       time.Time `json:"startTime"`
-		EndTime         time.Time `json:"endTime"`
-		Duration        int       `json:"duration"`
-		Questions       []string  `json:"questions"`
-		AllowedStudents []string  `json:"allowedStudents"`
-	}
-
-	req := new(UpdateTestRequest)
+	req := new(updateTestRequest)
 	if err := c.BodyParser(req); err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
 
-	// Prepare the update data for DB (using TestBSON structure for DB update)
-	updateBSON := bson.M{
-		"$set": bson.M{
-			"title":           req.Title,
-			"description":     req.Description,
-			"startTime":       req.StartTime,
-			"endTime":         req.EndTime,
-			"duration":        req.Duration,
-			"allowedStudents": req.AllowedStudents, // Assign strings directly
-		},
+	var existingTest models.TestBSON
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&existingTest)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test for update: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update test"})
+	}
+	if !canManage(c, existingTest.OwnerID) {
+		return forbidden(c)
 	}
 
-	// Convert question string IDs to ObjectIDs for DB update
-	var questionIDsForDB []primitive.ObjectID
-	for _, qIDStr := range req.Questions {
-		objID, err := primitive.ObjectIDFromHex(qIDStr)
-		if err != nil {
-			log.Printf("Invalid question ID format in update request: %v", qIDStr)
-			return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid question ID format"})
-		}
-		questionIDsForDB = append(questionIDsForDB, objID)
+	setFields, err := testUpdateFields(*req, existingTest)
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
 	}
-	updateBSON["$set"].(bson.M)["questions"] = questionIDsForDB
+
+	if len(setFields) == 0 {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "No fields to update"})
+	}
+	setFields["updatedAt"] = time.Now().UTC()
+	updateBSON := bson.M{"$set": setFields}
 
 	result, err := db.TestsCollection.UpdateOne(context.Background(), bson.M{"_id": id}, updateBSON)
 	if err != nil {
@@ -273,6 +375,98 @@ func UpdateTest(c *fiber.Ctx) error {
 	return c.JSON(updatedTest)
 }
 
+// updateTestRequest is the body of a test update. We expect question IDs and
+// allowed student IDs as strings in the incoming request. Pointers distinguish
+// "not provided" (nil) from zero values.
+type updateTestRequest struct {
+	Title           *string    `json:"title"`
+	Description     *string    `json:"description"`
+	StartTime       *time.Time `json:"startTime"`
+	EndTime         *time.Time `json:"endTime"`
+	Duration        *int       `json:"duration"`
+	Questions       *[]string  `json:"questions"`
+	AllowedStudents *[]string  `json:"allowedStudents"`
+	Mode            *string    `json:"mode"`
+	LateStartMins   *int       `json:"lateStartMinutes"`
+	PassThreshold   *float64   `json:"passThreshold"`
+}
+
+// testUpdateFields validates an update against the stored test and returns the
+// fields to $set, containing only the ones the request provided
+func testUpdateFields(req updateTestRequest, existingTest models.TestBSON) (bson.M, error) {
+	setFields := bson.M{}
+	if req.Title != nil {
+		if *req.Title == "" {
+			return nil, errors.New("Title cannot be empty")
+		}
+		setFields["title"] = *req.Title
+	}
+	if req.Description != nil {
+		setFields["description"] = *req.Description
+	}
+	if req.Duration != nil {
+		if *req.Duration <= 0 {
+			return nil, errors.New("Duration must be greater than 0")
+		}
+		setFields["duration"] = *req.Duration
+	}
+
+	// Validate the window as it will be after the update
+	if req.StartTime != nil || req.EndTime != nil {
+		startTime, endTime := existingTest.StartTime, existingTest.EndTime
+		if req.StartTime != nil {
+			startTime = *req.StartTime
+		}
+		if req.EndTime != nil {
+			endTime = *req.EndTime
+		}
+		startTime, endTime, err := normalizeTestWindow(startTime, endTime)
+		if err != nil {
+			return nil, err
+		}
+		setFields["startTime"] = startTime
+		setFields["endTime"] = endTime
+	}
+
+	if req.AllowedStudents != nil {
+		setFields["allowedStudents"] = *req.AllowedStudents // Assign strings directly
+	}
+	if req.Mode != nil {
+		if !models.IsValidTestMode(*req.Mode) {
+			return nil, errors.New("Mode must be one of: free, sequential")
+		}
+		setFields["mode"] = *req.Mode
+	}
+	if req.LateStartMins != nil {
+		if *req.LateStartMins < 0 {
+			return nil, errors.New("Late start window cannot be negative")
+		}
+		setFields["lateStartMinutes"] = *req.LateStartMins
+	}
+	if req.PassThreshold != nil {
+		if !validPassThreshold(*req.PassThreshold) {
+			return nil, errPassThreshold
+		}
+		setFields["passThreshold"] = *req.PassThreshold
+	}
+
+	// Convert question string IDs to ObjectIDs for DB update
+	if req.Questions != nil {
+		questionIDsForDB := []primitive.ObjectID{}
+		for _, qIDStr := range *req.Questions {
+			objID, err := primitive.ObjectIDFromHex(qIDStr)
+			if err != nil {
+				log.Printf("Invalid question ID format in update request: %v", qIDStr)
+				return nil, errors.New("Invalid question ID format")
+			}
+			questionIDsForDB = append(questionIDsForDB, objID)
+		}
+		setFields["questions"] = questionIDsForDB
+	}
+
+	return setFields, nil
+}
+
 // hydrateTest fetches full Question objects for a TestBSON and converts it to models.Test
 func hydrateTest(testBSON models.TestBSON) (models.Test, error) {
 	var test models.Test
@@ -281,30 +475,33 @@ func hydrateTest(testBSON models.TestBSON) (models.Test, error) {
 	test.ID = testBSON.ID.Hex()
 	test.Title = testBSON.Title
 	test.Description = testBSON.Description
-	test.StartTime = testBSON.StartTime
-	test.EndTime = testBSON.EndTime
+	// The driver decodes dates in the server's local zone; always respond in UTC
+	test.StartTime = testBSON.StartTime.UTC()
+	test.EndTime = testBSON.EndTime.UTC()
 	test.Duration = testBSON.Duration
 
 	// Convert allowed student ObjectIDs to strings for the response
 	// Since TestBSON.AllowedStudents is now []string, simply assign or copy
 	test.AllowedStudents = testBSON.AllowedStudents
-
-	var questions []models.Question
-	// Fetch full question details using the ObjectIDs from TestBSON
-	if len(testBSON.Questions) > 0 {
-		cursor, err := db.QuestionsCollection.Find(context.Background(), bson.M{
-			"_id": bson.M{"$in": testBSON.Questions},
-		})
-		if err != nil {
-			log.Printf("Failed to fetch questions for test %s during hydration: %v", testBSON.ID.Hex(), err)
-			return models.Test{}, err // Return error to calling handler
-		}
-		defer cursor.Close(context.Background())
-
-		if err := cursor.All(context.Background(), &questions); err != nil {
-			log.Printf("Failed to decode questions for test %s during hydration: %v", testBSON.ID.Hex(), err)
-			return models.Test{}, err // Return error to calling handler
-		}
+	if !testBSON.OwnerID.IsZero() {
+		test.OwnerID = testBSON.OwnerID.Hex()
+	}
+	test.Mode = testBSON.Mode
+	test.LateStartMins = testBSON.LateStartMins
+	test.PassThreshold = testBSON.PassMark()
+	test.UpdatedAt = testBSON.UpdatedAt.UTC()
+	test.Archived = testBSON.Archived
+	if testBSON.ArchivedAt != nil {
+		archivedAt := testBSON.ArchivedAt.UTC()
+		test.ArchivedAt = &archivedAt
+	}
+
+	// Fetch full question details using the ObjectIDs from TestBSON, in the test's order.
+	// The cache lets concurrent hydrations of the same test share one query.
+	questions, err := testQuestions.getMany(testBSON.Questions)
+	if err != nil {
+		log.Printf("Failed to fetch questions for test %s during hydration: %v", testBSON.ID.Hex(), err)
+		return models.Test{}, err // Return error to calling handler
 	}
 
 	// Assign the fetched full question objects to the Test struct
@@ -312,39 +509,112 @@ func hydrateTest(testBSON models.TestBSON) (models.Test, error) {
 
 	// Compatibility: For MCQ questions, always set CorrectOption if CorrectAnswer is present
 	for i, q := range test.Questions {
-		if q.Type == "mcq" && q.CorrectAnswer != "" && len(q.Options) > 0 {
-			for idx, opt := range q.Options {
-				if opt == q.CorrectAnswer {
-					test.Questions[i].CorrectOption = idx
-					break
-				}
+		if q.Type == "mcq" {
+			index, ambiguous := correctOptionIndex(q)
+			if ambiguous {
+				log.Printf("Warning: correct answer %q of question %s matches several options; using option %d", q.CorrectAnswer, q.ID.Hex(), index)
 			}
+			test.Questions[i].CorrectOption = index
 		}
 	}
 
 	return test, nil
 }
 
-// DeleteTest deletes a test by its ID
+// DeleteTest soft-deletes a test by its ID. The document is kept so existing
+// attempts and results can still resolve it; see RestoreTest.
 func DeleteTest(c *fiber.Ctx) error {
 	id, err := primitive.ObjectIDFromHex(c.Params("id"))
 	if err != nil {
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
 	}
 
-	result, err := db.TestsCollection.DeleteOne(context.Background(), bson.M{"_id": id})
+	var testBSON models.TestBSON
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&testBSON)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test for deletion: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete test"})
+	}
+	if !canManage(c, testBSON.OwnerID) {
+		return forbidden(c)
+	}
+
+	now := time.Now().UTC()
+	result, err := db.TestsCollection.UpdateOne(context.Background(), notDeleted(bson.M{"_id": id}),
+		bson.M{"$set": bson.M{"deletedAt": now, "updatedAt": now}})
 	if err != nil {
 		log.Printf("Failed to delete test: %v", err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to delete test"})
 	}
 
-	if result.DeletedCount == 0 {
+	if result.MatchedCount == 0 {
 		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
 	}
 
 	return c.SendStatus(204)
 }
 
+// ArchiveTest hides a test from students while keeping it for staff to browse and reuse
+func ArchiveTest(c *fiber.Ctx) error {
+	return setTestArchived(c, true)
+}
+
+// UnarchiveTest makes an archived test visible to students again
+func UnarchiveTest(c *fiber.Ctx) error {
+	return setTestArchived(c, false)
+}
+
+func setTestArchived(c *fiber.Ctx, archived bool) error {
+	id, err := primitive.ObjectIDFromHex(c.Params("id"))
+	if err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid ID"})
+	}
+
+	var testBSON models.TestBSON
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": id})).Decode(&testBSON)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test for archiving: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update test"})
+	}
+	if !canManage(c, testBSON.OwnerID) {
+		return forbidden(c)
+	}
+
+	now := time.Now().UTC()
+	update := bson.M{
+		"$set": bson.M{"archived": true, "archivedAt": now, "updatedAt": now},
+	}
+	if !archived {
+		update = bson.M{
+			"$set":   bson.M{"updatedAt": now},
+			"$unset": bson.M{"archived": "", "archivedAt": ""},
+		}
+	}
+	if _, err := db.TestsCollection.UpdateOne(context.Background(), bson.M{"_id": id}, update); err != nil {
+		log.Printf("Failed to update archived state of test %s: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to update test"})
+	}
+
+	testBSON.Archived = archived
+	testBSON.ArchivedAt = nil
+	if archived {
+		testBSON.ArchivedAt = &now
+	}
+	testBSON.UpdatedAt = now
+	test, err := hydrateTest(testBSON)
+	if err != nil {
+		log.Printf("Failed to hydrate test %s: %v", id.Hex(), err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to prepare test response"})
+	}
+	return c.JSON(test)
+}
+
 // SubmitTest handles a test submission
 func SubmitTest(c *fiber.Ctx) error {
 	// Parse the submission body into a map first to handle both formats
@@ -353,7 +623,6 @@ func SubmitTest(c *fiber.Ctx) error {
 		log.Printf("Error parsing submission body: %v", err)
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid request body"})
 	}
-	fmt.Printf("[DEBUG] Received submission payload: %+v\n", submissionMap)
 
 	// Create a new TestSubmission
 	submission := &models.TestSubmission{
@@ -375,11 +644,17 @@ func SubmitTest(c *fiber.Ctx) error {
 		submission.TimeSpent = int(timeSpent)
 	}
 
-	fmt.Printf("[DEBUG] Parsed studentId: %s, testId: %s\n", submission.StudentID, submission.TestID)
+	// A signed-in student always submits as themselves
+	signedInID, _ := c.Locals("userId").(string)
+	if signedInID != "" {
+		if submission.StudentID != "" && submission.StudentID != signedInID {
+			return c.Status(http.StatusForbidden).JSON(fiber.Map{"error": "You can only submit as yourself"})
+		}
+		submission.StudentID = signedInID
+	}
 
 	// Handle answers in either format
 	if answers, ok := submissionMap["answers"]; ok {
-		fmt.Printf("[DEBUG] Raw answers: %+v\n", answers)
 		switch v := answers.(type) {
 		case []interface{}:
 			// Array format
@@ -392,6 +667,9 @@ func SubmitTest(c *fiber.Ctx) error {
 					if ans, ok := answerMap["answer"].(string); ok {
 						answer.Answer = ans
 					}
+					if language, ok := answerMap["language"].(string); ok {
+						answer.Language = language
+					}
 					submission.Answers = append(submission.Answers, answer)
 				}
 			}
@@ -408,78 +686,195 @@ func SubmitTest(c *fiber.Ctx) error {
 		}
 	}
 
-	fmt.Printf("[DEBUG] Parsed answers: %+v\n", submission.Answers)
-
 	// Validate required fields
 	if submission.StudentID == "" {
-		fmt.Printf("[DEBUG] 400 error: Student ID is required\n")
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Student ID is required"})
 	}
 	if submission.TestID == "" {
-		fmt.Printf("[DEBUG] 400 error: Test ID is required\n")
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Test ID is required"})
 	}
+
+	// Answers saved along the way count too; the submitted answer wins where both exist
+	saved, err := findAttemptProgress(submission.TestID, submission.StudentID)
+	if err != nil {
+		log.Printf("Failed to fetch saved answers for student %s on test %s: %v", submission.StudentID, submission.TestID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
+	if saved != nil {
+		submission.Answers = mergeAnswers(saved.Answers, submission.Answers)
+	}
 	if len(submission.Answers) == 0 {
-		fmt.Printf("[DEBUG] 400 error: No answers provided\n")
 		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "No answers provided"})
 	}
 
-	// Insert the submission into the database
-	result, err := db.AttemptCollection.InsertOne(context.Background(), submission)
+	// A test whose questions have all been deleted can't be graded meaningfully;
+	// reject the submission instead of recording a misleading 0%
+	testID, err := primitive.ObjectIDFromHex(submission.TestID)
 	if err != nil {
-		log.Printf("Failed to submit test: %v", err)
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": "Invalid test ID"})
+	}
+	var testBSON models.TestBSON
+	err = db.TestsCollection.FindOne(context.Background(), notDeleted(bson.M{"_id": testID})).Decode(&testBSON)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test not found"})
+		}
+		log.Printf("Failed to fetch test %s for submission: %v", submission.TestID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
+	if testBSON.Mode == models.TestModeSequential {
+		return c.Status(http.StatusConflict).JSON(fiber.Map{
+			"error": "This test is answered one question at a time; use the answers endpoint",
+		})
+	}
+	// A body-supplied student ID proves nothing, so assigned tests need a signed-in student
+	if len(testBSON.AllowedStudents) > 0 && signedInID == "" {
+		return c.Status(http.StatusUnauthorized).JSON(fiber.Map{"error": "Sign in to submit this test"})
+	}
+	if !enforceSubmissionWindow(c, testBSON, submission.StudentID) {
+		return nil
+	}
+	if !enforcePersonalDeadline(c, testBSON, submission.StudentID) {
+		return nil
+	}
+	// TimeSpent stays as the client reported it; the measured time sits beside it for auditing
+	started, err := findTestProgress(submission.TestID, submission.StudentID)
+	if err != nil {
+		log.Printf("Failed to fetch start time for student %s on test %s: %v", submission.StudentID, submission.TestID, err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
 	}
+	if started == nil {
+		// Nothing vouches for how long the student took, so the submission is
+		// flagged, and the clock starts now so any later attempt is measured
+		submission.Untimed = true
+		if _, err := recordTestStart(submission.TestID, submission.StudentID, submission.SubmittedAt); err != nil {
+			log.Printf("Failed to record start for student %s on test %s: %v", submission.StudentID, submission.TestID, err)
+		}
+	}
+	submission.ServerTimeSpent = serverTimeSpent(started, submission.SubmittedAt)
+	if err := validateAnswersForTest(submission.Answers, testBSON); err != nil {
+		return c.Status(http.StatusBadRequest).JSON(fiber.Map{"error": err.Error()})
+	}
+	questionCount, err := countTestQuestions(testBSON)
+	if err != nil {
+		log.Printf("Failed to count questions for test %s: %v", submission.TestID, err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
+	if questionCount == 0 {
+		log.Printf("Rejected submission for test %s: no questions remain", submission.TestID)
+		return c.Status(http.StatusUnprocessableEntity).JSON(fiber.Map{
+			"error": "This test has no questions and cannot be graded. Please contact your instructor.",
+		})
+	}
 
-	// Set the inserted ID on the submission object
-	submission.ID = result.InsertedID.(primitive.ObjectID).Hex()
+	// Grade each answer now so review and analytics don't have to recompute it
+	questions, err := fetchQuestionsForAnswers(submission.Answers)
+	if err != nil {
+		log.Printf("Failed to fetch questions for grading: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to grade submission"})
+	}
+	submission.QuestionResults = gradeAnswers(submission.Answers, questions)
+	if pending := gradePendingAnswers(submission, questions); pending > 0 {
+		log.Printf("Submission for test %s stored with %d coding answers pending grading", submission.TestID, pending)
+	}
+	scoreSubmission(submission, questions, testBSON)
+
+	if err := insertSubmission(submission); err != nil {
+		log.Printf("Failed to submit test: %v", err)
+		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to submit test"})
+	}
 	log.Printf("Successfully created test attempt with ID: %s", submission.ID)
+	clearAttemptProgress(submission.TestID, submission.StudentID)
 
 	// Respond with the submission details
 	return c.Status(http.StatusCreated).JSON(submission)
 }
 
-// GetTestAttempt retrieves a single test attempt by its ID
+// validateAnswersForTest rejects submissions with more answers than the test has
+// questions, answers to questions outside the test, or repeated answers, so a
+// client can't make grading do unbounded work
+func validateAnswersForTest(answers []models.Answer, testBSON models.TestBSON) error {
+	if len(answers) > len(testBSON.Questions) {
+		return fmt.Errorf("Too many answers: the test has %d questions", len(testBSON.Questions))
+	}
+	inTest := make(map[string]bool, len(testBSON.Questions))
+	for _, id := range testBSON.Questions {
+		inTest[id.Hex()] = true
+	}
+	seen := make(map[string]bool, len(answers))
+	for _, answer := range answers {
+		if !inTest[answer.QuestionID] {
+			return fmt.Errorf("Question %q is not part of this test", answer.QuestionID)
+		}
+		if seen[answer.QuestionID] {
+			return fmt.Errorf("Question %q is answered more than once", answer.QuestionID)
+		}
+		seen[answer.QuestionID] = true
+	}
+	return nil
+}
+
+// insertSubmission stores a graded submission under a fresh reference code,
+// regenerating the code if the unique index reports a collision, and sets the
+// inserted ID on the submission
+func insertSubmission(submission *models.TestSubmission) error {
+	for try := 1; ; try++ {
+		reference, err := generateReferenceCode()
+		if err != nil {
+			return fmt.Errorf("generate reference code: %w", err)
+		}
+		submission.Reference = reference
+		result, err := db.AttemptCollection.InsertOne(context.Background(), submission)
+		if err == nil {
+			submission.ID = result.InsertedID.(primitive.ObjectID).Hex()
+			return nil
+		}
+		if !mongo.IsDuplicateKeyError(err) || try == referenceCodeRetries {
+			return err
+		}
+		log.Printf("Reference code %s already taken, retrying", submission.Reference)
+	}
+}
+
+// GetTestAttempt retrieves a single test attempt by its ID or reference code,
+// for the student who made it or for staff
 func GetTestAttempt(c *fiber.Ctx) error {
 	attemptID := c.Params("attemptId")
 	log.Printf("Received request for test attempt with ID: %s", attemptID)
 	log.Printf("Request path: %s", c.Path())
 	log.Printf("Request method: %s", c.Method())
-	log.Printf("Request headers: %v", c.GetReqHeaders())
-
-	// Try to convert to ObjectID first
-	objID, err := primitive.ObjectIDFromHex(attemptID)
-	if err != nil {
-		log.Printf("Error converting attempt ID %s to ObjectID: %v", attemptID, err)
-		// If conversion fails, try to find by string ID
-		var submission models.TestSubmission
-		err = db.AttemptCollection.FindOne(context.Background(), bson.M{"_id": attemptID}).Decode(&submission)
-		if err != nil {
-			if err == mongo.ErrNoDocuments {
-				log.Printf("Test attempt with ID %s not found in database.", attemptID)
-				return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test attempt not found"})
-			}
-			log.Printf("Error fetching test attempt %s: %v", attemptID, err)
-			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test attempt"})
-		}
-		log.Printf("Successfully found test attempt with string ID: %s", attemptID)
-		return c.Status(http.StatusOK).JSON(submission)
+	log.Printf("Request headers: %v", sanitizeHeaders(c.GetReqHeaders()))
+
+	// Reference codes (e.g. ATT-7F3K9QW2MX) are what students quote to support.
+	// Anything else is an attempt's ObjectID, or a legacy string ID.
+	var filter bson.M
+	if isReferenceCode(attemptID) {
+		filter = bson.M{"referenceCode": strings.ToUpper(attemptID)}
+	} else if objID, err := primitive.ObjectIDFromHex(attemptID); err == nil {
+		filter = bson.M{"_id": objID}
+	} else {
+		filter = bson.M{"_id": attemptID}
 	}
 
-	// If we have a valid ObjectID, search by that
 	var submission models.TestSubmission
-	err = db.AttemptCollection.FindOne(context.Background(), bson.M{"_id": objID}).Decode(&submission)
+	err := db.AttemptCollection.FindOne(context.Background(), filter).Decode(&submission)
 	if err != nil {
 		if err == mongo.ErrNoDocuments {
-			log.Printf("Test attempt with ObjectID %s not found in database.", objID.Hex())
+			log.Printf("Test attempt %s not found in database.", attemptID)
 			return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test attempt not found"})
 		}
-		log.Printf("Error fetching test attempt %s: %v", objID.Hex(), err)
+		log.Printf("Error fetching test attempt %s: %v", attemptID, err)
 		return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch test attempt"})
 	}
 
-	log.Printf("Successfully found test attempt with ObjectID: %s", objID.Hex())
-	// Return the found submission
+	// Students only see their own attempts. Someone else's looks the same as a
+	// missing one, so guessing IDs or codes doesn't reveal which exist.
+	studentID, _ := c.Locals("userId").(string)
+	if !isStaffRequest(c) && (studentID == "" || submission.StudentID != studentID) {
+		return c.Status(http.StatusNotFound).JSON(fiber.Map{"error": "Test attempt not found"})
+	}
+
+	log.Printf("Successfully found test attempt %s", attemptID)
 	return c.Status(http.StatusOK).JSON(submission)
 }
 
@@ -500,7 +895,7 @@ func isValidObjectID(id string) bool {
 // GetActiveTests retrieves all active tests (tests that have started but not ended)
 func GetActiveTests(c *fiber.Ctx) error {
 	fmt.Printf("GetActiveTests handler called\n")
-	now := time.Now()
+	now := time.Now().UTC()
 
 	filter := bson.M{
 		"startTime": bson.M{
@@ -509,6 +904,8 @@ func GetActiveTests(c *fiber.Ctx) error {
 		"endTime": bson.M{
 			"$gt": now,
 		},
+		"archived":  bson.M{"$ne": true},
+		"deletedAt": bson.M{"$exists": false},
 	}
 
 	fmt.Printf("Querying active tests with filter: %+v\n", filter)
@@ -526,6 +923,19 @@ func GetActiveTests(c *fiber.Ctx) error {
 	}
 
 	fmt.Printf("Found %d active tests\n", len(testsBSON))
+
+	// Personal deadlines count from when the student started, or from now for
+	// tests they haven't started yet
+	startedAt := map[string]time.Time{}
+	if studentID := c.Query("studentId"); studentID != "" && len(testsBSON) > 0 {
+		startedAt, err = studentStartTimes(testsBSON, studentID)
+		if err != nil {
+			log.Printf("Failed to fetch start times for student %s: %v", studentID, err)
+			return c.Status(http.StatusInternalServerError).JSON(fiber.Map{"error": "Failed to fetch active tests"})
+		}
+	}
+
+	staff := isStaffRequest(c)
 	var tests []models.Test
 	for _, testBSON := range testsBSON {
 		test, err := hydrateTest(testBSON)
@@ -533,6 +943,15 @@ func GetActiveTests(c *fiber.Ctx) error {
 			log.Printf("Failed to hydrate test %s: %v", testBSON.ID.Hex(), err)
 			continue
 		}
+		if !staff {
+			test = testWithoutAnswerKeys(test)
+		}
+		start, ok := startedAt[test.ID]
+		if !ok {
+			start = now
+		}
+		deadline := personalDeadline(testBSON, start).UTC()
+		test.PersonalDeadline = &deadline
 		tests = append(tests, test)
 	}
 
@@ -542,12 +961,14 @@ func GetActiveTests(c *fiber.Ctx) error {
 // GetScheduledTests retrieves all scheduled tests (tests that haven't started yet)
 func GetScheduledTests(c *fiber.Ctx) error {
 	fmt.Printf("GetScheduledTests handler called\n")
-	now := time.Now()
+	now := time.Now().UTC()
 
 	filter := bson.M{
 		"startTime": bson.M{
 			"$gt": now,
 		},
+		"archived":  bson.M{"$ne": true},
+		"deletedAt": bson.M{"$exists": false},
 	}
 
 	fmt.Printf("Querying scheduled tests with filter: %+v\n", filter)
@@ -565,6 +986,7 @@ func GetScheduledTests(c *fiber.Ctx) error {
 	}
 
 	fmt.Printf("Found %d scheduled tests\n", len(testsBSON))
+	staff := isStaffRequest(c)
 	var tests []models.Test
 	for _, testBSON := range testsBSON {
 		test, err := hydrateTest(testBSON)
@@ -572,6 +994,9 @@ func GetScheduledTests(c *fiber.Ctx) error {
 			log.Printf("Failed to hydrate test %s: %v", testBSON.ID.Hex(), err)
 			continue
 		}
+		if !staff {
+			test = testWithoutAnswerKeys(test)
+		}
 		tests = append(tests, test)
 	}
 
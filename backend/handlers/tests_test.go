@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"qms-backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func storedTest() models.TestBSON {
+	return models.TestBSON{
+		ID:              primitive.NewObjectID(),
+		Title:           "Midterm",
+		StartTime:       time.Date(2024, 3, 1, 9, 0, 0, 0, time.UTC),
+		EndTime:         time.Date(2024, 3, 1, 11, 0, 0, 0, time.UTC),
+		Duration:        60,
+		Questions:       []primitive.ObjectID{primitive.NewObjectID()},
+		AllowedStudents: []string{"student-1"},
+	}
+}
+
+func updateFieldsFor(t *testing.T, body string) (map[string]interface{}, error) {
+	t.Helper()
+	var req updateTestRequest
+	if err := json.Unmarshal([]byte(body), &req); err != nil {
+		t.Fatalf("invalid request body %s: %v", body, err)
+	}
+	return testUpdateFields(req, storedTest())
+}
+
+func TestTestUpdateFieldsOnlySetsProvidedFields(t *testing.T) {
+	fields, err := updateFieldsFor(t, `{"title": "Final"}`)
+	if err != nil {
+		t.Fatalf("update rejected: %v", err)
+	}
+	if len(fields) != 1 || fields["title"] != "Final" {
+		t.Errorf("fields = %v, want only the title", fields)
+	}
+}
+
+func TestTestUpdateFieldsEmptyListsClear(t *testing.T) {
+	fields, err := updateFieldsFor(t, `{"questions": [], "allowedStudents": []}`)
+	if err != nil {
+		t.Fatalf("update rejected: %v", err)
+	}
+	questions, ok := fields["questions"].([]primitive.ObjectID)
+	if !ok || len(questions) != 0 {
+		t.Errorf("questions = %#v, want an empty list", fields["questions"])
+	}
+	students, ok := fields["allowedStudents"].([]string)
+	if !ok || len(students) != 0 {
+		t.Errorf("allowedStudents = %#v, want an empty list", fields["allowedStudents"])
+	}
+}
+
+func TestTestUpdateFieldsValidatesWindowAgainstStoredTimes(t *testing.T) {
+	// Only the end time is sent; it must be checked against the stored start time
+	if _, err := updateFieldsFor(t, `{"endTime": "2024-03-01T08:00:00Z"}`); err == nil {
+		t.Error("expected an end time before the stored start time to be rejected")
+	}
+
+	fields, err := updateFieldsFor(t, `{"endTime": "2024-03-01T12:00:00Z"}`)
+	if err != nil {
+		t.Fatalf("update rejected: %v", err)
+	}
+	if start, _ := fields["startTime"].(time.Time); !start.Equal(storedTest().StartTime) {
+		t.Errorf("startTime = %v, want the stored start time", fields["startTime"])
+	}
+}
+
+func TestTestUpdateFieldsRejectsInvalidValues(t *testing.T) {
+	bodies := []string{
+		`{"title": ""}`,
+		`{"duration": 0}`,
+		`{"mode": "random"}`,
+		`{"lateStartMinutes": -1}`,
+		`{"passThreshold": 101}`,
+		`{"passThreshold": 0}`,
+		`{"questions": ["not-an-id"]}`,
+	}
+	for _, body := range bodies {
+		if _, err := updateFieldsFor(t, body); err == nil {
+			t.Errorf("expected %s to be rejected", body)
+		}
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// timeWindow is a validated ?from= and ?to= pair; a zero bound is open
+type timeWindow struct {
+	from time.Time
+	to   time.Time
+}
+
+// parseTimeWindow reads ?from= and ?to= as RFC3339 times. Both are optional and
+// inclusive; without them nothing is filtered.
+func parseTimeWindow(c *fiber.Ctx) (timeWindow, error) {
+	var w timeWindow
+	for _, bound := range []struct {
+		name   string
+		target *time.Time
+	}{{"from", &w.from}, {"to", &w.to}} {
+		raw := c.Query(bound.name)
+		if raw == "" {
+			continue
+		}
+		t, err := time.Parse(time.RFC3339, raw)
+		if err != nil {
+			return w, fmt.Errorf("%s must be an RFC3339 time, e.g. 2024-05-01T00:00:00Z", bound.name)
+		}
+		*bound.target = t.UTC()
+	}
+	if !w.from.IsZero() && !w.to.IsZero() && w.to.Before(w.from) {
+		return w, fmt.Errorf("to must not be before from")
+	}
+	return w, nil
+}
+
+// apply restricts filter to documents whose field falls within the window
+func (w timeWindow) apply(filter bson.M, field string) bson.M {
+	if w.from.IsZero() && w.to.IsZero() {
+		return filter
+	}
+	bounds := bson.M{}
+	if !w.from.IsZero() {
+		bounds["$gte"] = w.from
+	}
+	if !w.to.IsZero() {
+		bounds["$lte"] = w.to
+	}
+	filter[field] = bounds
+	return filter
+}
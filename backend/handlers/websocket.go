@@ -1,19 +1,54 @@
 package handlers
 
 import (
+	"context"
+	"encoding/json"
 	"fmt"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/gofiber/websocket/v2"
 )
 
-// Hub maintains the set of active clients and broadcasts messages to them
+// hubDrainTimeout bounds how long Run waits at shutdown for clients to be sent
+// their pending messages
+const hubDrainTimeout = 5 * time.Second
+
+// Keepalive timing. A client that hasn't answered a ping (or sent anything)
+// within pongWait is dropped; pings go out often enough to arrive before that.
+const (
+	pongWait   = 60 * time.Second
+	pingPeriod = pongWait * 9 / 10
+	writeWait  = 10 * time.Second
+)
+
+// slowClientDropLimit is how many messages in a row a client may miss because
+// its send buffer is full before it is disconnected
+const slowClientDropLimit = 10
+
+// TestsTopic carries updates for every test, for clients showing the test list
+const TestsTopic = "tests"
+
+// testTopic is the topic a client subscribes to for updates to one test
+func testTopic(testID string) string {
+	return "test:" + testID
+}
+
+// Hub maintains the set of active clients and delivers messages to the clients
+// subscribed to each message's topics
 type Hub struct {
 	// Registered clients
 	clients map[*Client]bool
 
-	// Inbound messages from the clients
-	broadcast chan []byte
+	// Topics each registered client is subscribed to
+	subscriptions map[*Client]map[string]bool
+
+	// Messages to deliver to topic subscribers
+	broadcast chan topicMessage
+
+	// Subscribe and unsubscribe requests from the clients
+	subscription chan subscriptionRequest
 
 	// Register requests from the clients
 	register chan *Client
@@ -21,8 +56,69 @@ type Hub struct {
 	// Unregister requests from clients
 	unregister chan *Client
 
+	// Closed once Run has stopped; sends to the hub are dropped after that
+	done chan struct{}
+
+	// Clients' writer goroutines, waited for when draining at shutdown
+	writers sync.WaitGroup
+
 	// Mutex for thread-safe operations
 	mu sync.Mutex
+
+	// Messages dropped because a client's send buffer was full
+	dropped atomic.Int64
+}
+
+// topicMessage is delivered once to every client subscribed to any of its topics
+type topicMessage struct {
+	topics  []string
+	message []byte
+}
+
+type subscriptionRequest struct {
+	client    *Client
+	topic     string
+	subscribe bool
+}
+
+// Inbound message types
+const (
+	wsSubscribe   = "subscribe"
+	wsUnsubscribe = "unsubscribe"
+	wsPing        = "ping"
+)
+
+// WSMessage is a message sent by a client, e.g.
+// {"type":"subscribe","payload":{"topic":"test:123"}}. The payload's shape
+// depends on Type.
+type WSMessage struct {
+	Type    string          `json:"type"`
+	Payload json.RawMessage `json:"payload,omitempty"`
+}
+
+// topicPayload is the payload of subscribe and unsubscribe messages
+type topicPayload struct {
+	Topic string `json:"topic"`
+}
+
+// wsReply answers a client's own message: pong, subscribed, unsubscribed or error
+type wsReply struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// testEvent announces a change to a test: test_update or test_started
+type testEvent struct {
+	Type   string `json:"type"`
+	TestID string `json:"testId"`
+}
+
+// attemptDoneEvent announces that an asynchronous attempt has finished
+type attemptDoneEvent struct {
+	Type      string `json:"type"`
+	AttemptID string `json:"attemptId"`
+	Status    string `json:"status"`
 }
 
 // Client represents a connected WebSocket client
@@ -30,24 +126,47 @@ type Client struct {
 	hub  *Hub
 	conn *websocket.Conn
 	send chan []byte
+
+	// A connection supports one writer at a time; the reader's echoes and the
+	// writer's messages and pings share it
+	writeMu sync.Mutex
+
+	// Messages dropped in a row for this client; only touched by Hub.Run
+	missed int
+}
+
+// write sends one frame, giving up after writeWait so a stalled peer can't block the writer
+func (client *Client) write(messageType int, data []byte) error {
+	client.writeMu.Lock()
+	defer client.writeMu.Unlock()
+	client.conn.SetWriteDeadline(time.Now().Add(writeWait))
+	return client.conn.WriteMessage(messageType, data)
 }
 
 // NewHub creates a new hub instance
 func NewHub() *Hub {
 	fmt.Println("Creating new WebSocket hub...")
 	return &Hub{
-		clients:    make(map[*Client]bool),
-		broadcast:  make(chan []byte),
-		register:   make(chan *Client),
-		unregister: make(chan *Client),
+		clients:       make(map[*Client]bool),
+		subscriptions: make(map[*Client]map[string]bool),
+		broadcast:     make(chan topicMessage),
+		subscription:  make(chan subscriptionRequest),
+		register:      make(chan *Client),
+		unregister:    make(chan *Client),
+		done:          make(chan struct{}),
 	}
 }
 
-// Run starts the hub's event loop
-func (h *Hub) Run() {
+// Run starts the hub's event loop. When ctx is cancelled it disconnects every
+// client, once their pending messages are written, and returns.
+func (h *Hub) Run(ctx context.Context) {
 	fmt.Println("Starting WebSocket hub event loop...")
 	for {
 		select {
+		case <-ctx.Done():
+			h.drain()
+			return
+
 		case client := <-h.register:
 			h.mu.Lock()
 			h.clients[client] = true
@@ -57,31 +176,121 @@ func (h *Hub) Run() {
 		case client := <-h.unregister:
 			h.mu.Lock()
 			if _, ok := h.clients[client]; ok {
-				delete(h.clients, client)
-				close(client.send)
+				h.remove(client)
 				fmt.Printf("Client unregistered. Remaining clients: %d\n", len(h.clients))
 			}
 			h.mu.Unlock()
 
-		case message := <-h.broadcast:
+		case req := <-h.subscription:
 			h.mu.Lock()
-			clientCount := len(h.clients)
-			fmt.Printf("Broadcasting message to %d clients\n", clientCount)
-			for client := range h.clients {
-				select {
-				case client.send <- message:
-					fmt.Printf("Message sent to client %s\n", client.conn.RemoteAddr().String())
-				default:
-					fmt.Printf("Failed to send message to client %s\n", client.conn.RemoteAddr().String())
-					close(client.send)
-					delete(h.clients, client)
+			if _, ok := h.clients[req.client]; ok {
+				if req.subscribe {
+					if h.subscriptions[req.client] == nil {
+						h.subscriptions[req.client] = make(map[string]bool)
+					}
+					h.subscriptions[req.client][req.topic] = true
+				} else {
+					delete(h.subscriptions[req.client], req.topic)
 				}
 			}
 			h.mu.Unlock()
+
+		case msg := <-h.broadcast:
+			h.deliver(msg)
 		}
 	}
 }
 
+// deliver queues msg for its subscribers without waiting on any of them. The
+// recipients are collected under the lock and sent to after releasing it; that
+// is safe because only Run, which calls deliver, closes send channels. A client
+// whose buffer is full misses the message, and is disconnected once it has
+// missed slowClientDropLimit in a row.
+func (h *Hub) deliver(msg topicMessage) {
+	h.mu.Lock()
+	recipients := make([]*Client, 0, len(h.subscriptions))
+	for client, topics := range h.subscriptions {
+		if subscribedToAny(topics, msg.topics) {
+			recipients = append(recipients, client)
+		}
+	}
+	h.mu.Unlock()
+
+	sent := 0
+	var slow []*Client
+	for _, client := range recipients {
+		select {
+		case client.send <- msg.message:
+			client.missed = 0
+			sent++
+		default:
+			h.dropped.Add(1)
+			client.missed++
+			fmt.Printf("Dropped message for slow client %s (%d in a row)\n", client.conn.RemoteAddr().String(), client.missed)
+			if client.missed >= slowClientDropLimit {
+				slow = append(slow, client)
+			}
+		}
+	}
+
+	if len(slow) > 0 {
+		h.mu.Lock()
+		for _, client := range slow {
+			if _, ok := h.clients[client]; ok {
+				fmt.Printf("Disconnecting slow client %s\n", client.conn.RemoteAddr().String())
+				h.remove(client)
+			}
+		}
+		h.mu.Unlock()
+	}
+	fmt.Printf("Message for %v sent to %d of %d subscribed clients\n", msg.topics, sent, len(recipients))
+}
+
+// DroppedMessages is the number of messages not delivered because a client's
+// send buffer was full, since the hub was created
+func (h *Hub) DroppedMessages() int64 {
+	return h.dropped.Load()
+}
+
+// ClientCount is the number of connected clients
+func (h *Hub) ClientCount() int {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	return len(h.clients)
+}
+
+// drain stops the hub and closes every client's send channel, so each writer
+// flushes what is queued, sends a close frame and disconnects
+func (h *Hub) drain() {
+	h.mu.Lock()
+	close(h.done)
+	fmt.Printf("Stopping WebSocket hub, disconnecting %d clients...\n", len(h.clients))
+	for client := range h.clients {
+		h.remove(client)
+	}
+	h.mu.Unlock()
+
+	if !waitTimeout(&h.writers, hubDrainTimeout) {
+		fmt.Println("Timed out waiting for WebSocket clients to disconnect")
+	}
+}
+
+// remove drops a client and its subscriptions; the caller holds h.mu
+func (h *Hub) remove(client *Client) {
+	delete(h.clients, client)
+	delete(h.subscriptions, client)
+	close(client.send)
+}
+
+func subscribedToAny(subscribed map[string]bool, topics []string) bool {
+	for _, topic := range topics {
+		if subscribed[topic] {
+			return true
+		}
+	}
+	return false
+}
+
 // ServeWs handles websocket requests from clients
 func ServeWs(hub *Hub, c *websocket.Conn) {
 	fmt.Printf("New WebSocket connection from %s\n", c.RemoteAddr().String())
@@ -91,40 +300,62 @@ func ServeWs(hub *Hub, c *websocket.Conn) {
 		conn: c,
 		send: make(chan []byte, 256),
 	}
-	client.hub.register <- client
+	// Requests to the hub are dropped once it has stopped
+	hub.writers.Add(1)
+	select {
+	case hub.register <- client:
+	case <-hub.done:
+		hub.writers.Done()
+		c.Close()
+		return
+	}
 
 	// Start goroutine to read messages from client
 	go func() {
 		defer func() {
 			fmt.Printf("Client %s disconnected\n", c.RemoteAddr().String())
-			client.hub.unregister <- client
+			select {
+			case client.hub.unregister <- client:
+			case <-client.hub.done:
+			}
 			c.Close()
 		}()
 
+		// Every pong or message proves the client is alive; one that goes silent
+		// past the deadline fails the read and is unregistered
+		c.SetReadDeadline(time.Now().Add(pongWait))
+		c.SetPongHandler(func(string) error {
+			return c.SetReadDeadline(time.Now().Add(pongWait))
+		})
+
 		for {
-			messageType, message, err := c.ReadMessage()
+			_, message, err := c.ReadMessage()
 			if err != nil {
 				if websocket.IsUnexpectedCloseError(err, websocket.CloseGoingAway, websocket.CloseAbnormalClosure) {
 					fmt.Printf("WebSocket error from %s: %v\n", c.RemoteAddr().String(), err)
 				}
 				break
 			}
+			c.SetReadDeadline(time.Now().Add(pongWait))
 
 			fmt.Printf("Received message from %s: %s\n", c.RemoteAddr().String(), string(message))
 
-			// Echo the message back to the client
-			if err := c.WriteMessage(messageType, message); err != nil {
+			if err := client.write(websocket.TextMessage, client.handle(message)); err != nil {
 				fmt.Printf("Error writing message to %s: %v\n", c.RemoteAddr().String(), err)
 				break
 			}
 		}
 	}()
 
-	// Start goroutine to write messages to client
+	// Start goroutine to write messages and keepalive pings to client. Closing the
+	// connection on a failed write also ends the reader, which unregisters the client.
 	go func() {
+		ticker := time.NewTicker(pingPeriod)
 		defer func() {
+			ticker.Stop()
 			fmt.Printf("Stopping message writer for %s\n", c.RemoteAddr().String())
 			c.Close()
+			hub.writers.Done()
 		}()
 
 		for {
@@ -132,22 +363,94 @@ func ServeWs(hub *Hub, c *websocket.Conn) {
 			case message, ok := <-client.send:
 				if !ok {
 					fmt.Printf("Client %s send channel closed\n", c.RemoteAddr().String())
+					client.write(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseGoingAway, ""))
 					return
 				}
 
-				if err := c.WriteMessage(websocket.TextMessage, message); err != nil {
+				if err := client.write(websocket.TextMessage, message); err != nil {
 					fmt.Printf("Error writing message to %s: %v\n", c.RemoteAddr().String(), err)
 					return
 				}
 				fmt.Printf("Message sent to %s\n", c.RemoteAddr().String())
+
+			case <-ticker.C:
+				if err := client.write(websocket.PingMessage, nil); err != nil {
+					fmt.Printf("Ping to %s failed, dropping connection: %v\n", c.RemoteAddr().String(), err)
+					return
+				}
 			}
 		}
 	}()
 }
 
-// BroadcastTestUpdate sends a test update to all connected clients
+// handle acts on one inbound message and returns the encoded reply
+func (client *Client) handle(message []byte) []byte {
+	var msg WSMessage
+	if err := json.Unmarshal(message, &msg); err != nil {
+		return encodeWS(wsReply{Type: "error", Error: "Message must be a JSON object with a type"})
+	}
+
+	switch msg.Type {
+	case wsPing:
+		return encodeWS(wsReply{Type: "pong"})
+
+	case wsSubscribe, wsUnsubscribe:
+		var payload topicPayload
+		if len(msg.Payload) == 0 || json.Unmarshal(msg.Payload, &payload) != nil || payload.Topic == "" {
+			return encodeWS(wsReply{Type: "error", Error: "A topic is required"})
+		}
+		req := subscriptionRequest{
+			client:    client,
+			topic:     payload.Topic,
+			subscribe: msg.Type == wsSubscribe,
+		}
+		select {
+		case client.hub.subscription <- req:
+		case <-client.hub.done:
+		}
+		if req.subscribe {
+			return encodeWS(wsReply{Type: "subscribed", Topic: payload.Topic})
+		}
+		return encodeWS(wsReply{Type: "unsubscribed", Topic: payload.Topic})
+	}
+	return encodeWS(wsReply{Type: "error", Error: fmt.Sprintf("Unknown message type %q", msg.Type)})
+}
+
+// encodeWS marshals an outbound message. The message types only hold strings,
+// so encoding can't fail.
+func encodeWS(v interface{}) []byte {
+	data, _ := json.Marshal(v)
+	return data
+}
+
+// BroadcastTestUpdate sends a test update to the clients subscribed to that
+// test's topic or to TestsTopic
 func (h *Hub) BroadcastTestUpdate(testID string) {
 	fmt.Printf("Broadcasting test update for test ID: %s\n", testID)
-	message := fmt.Sprintf(`{"type":"test_update","testId":"%s"}`, testID)
-	h.broadcast <- []byte(message)
+	h.broadcastTestEvent("test_update", testID)
+}
+
+// BroadcastTestStarted tells the same clients that a scheduled test has started
+func (h *Hub) BroadcastTestStarted(testID string) {
+	fmt.Printf("Broadcasting test start for test ID: %s\n", testID)
+	h.broadcastTestEvent("test_started", testID)
+}
+
+// BroadcastAttemptDone tells the clients subscribed to an attempt's topic that
+// it has been graded, or that its execution failed
+func (h *Hub) BroadcastAttemptDone(attemptID, status string) {
+	fmt.Printf("Broadcasting result of attempt ID: %s\n", attemptID)
+	message := encodeWS(attemptDoneEvent{Type: "attempt_done", AttemptID: attemptID, Status: status})
+	select {
+	case h.broadcast <- topicMessage{topics: []string{attemptTopic(attemptID)}, message: message}:
+	case <-h.done:
+	}
+}
+
+func (h *Hub) broadcastTestEvent(eventType, testID string) {
+	message := encodeWS(testEvent{Type: eventType, TestID: testID})
+	select {
+	case h.broadcast <- topicMessage{topics: []string{testTopic(testID), TestsTopic}, message: message}:
+	case <-h.done:
+	}
 }
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"sync"
+	"time"
+)
+
+// workers tracks the background workers started by the Start* functions, so
+// shutdown can let their current pass finish before the database goes away
+var workers sync.WaitGroup
+
+// WaitForWorkers waits for every background worker to return after its context
+// is cancelled, giving up after timeout. It reports whether they all stopped.
+func WaitForWorkers(timeout time.Duration) bool {
+	return waitTimeout(&workers, timeout)
+}
+
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -5,6 +5,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
 	"time"
 
 	"qms-backend/db"
@@ -49,8 +53,22 @@ func main() {
 	allowedOrigins := getEnvWithDefault("ALLOWED_ORIGINS", "http://localhost:5173,http://localhost:3000")
 	logLevel := getEnvWithDefault("LOG_LEVEL", "debug")
 
+	// Behind a reverse proxy the client IP is read from PROXY_HEADER, but only on
+	// requests coming from TRUSTED_PROXIES (comma-separated IPs or CIDRs), so
+	// clients can't choose the IP that rate limits and submission guards key on
+	var trustedProxies []string
+	for _, proxy := range strings.Split(getEnvWithDefault("TRUSTED_PROXIES", ""), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			trustedProxies = append(trustedProxies, proxy)
+		}
+	}
+	proxyHeader := ""
+	if len(trustedProxies) > 0 {
+		proxyHeader = getEnvWithDefault("PROXY_HEADER", fiber.HeaderXForwardedFor)
+	}
+
 	fmt.Printf("Server will run on port: %s\n", port)
-	fmt.Printf("MongoDB URI: %s\n", mongoURI)
+	fmt.Printf("MongoDB URI: %s\n", handlers.SanitizeURL(mongoURI))
 	fmt.Printf("Database name: %s\n", dbName)
 
 	// Connect to MongoDB with retry logic
@@ -96,9 +114,13 @@ func main() {
 
 	// Create Fiber app with custom error handling
 	app := fiber.New(fiber.Config{
-		AppName:               "QMS Backend v1.0",
-		EnablePrintRoutes:     logLevel == "debug",
-		DisableStartupMessage: true,
+		AppName:                 "QMS Backend v1.0",
+		EnablePrintRoutes:       logLevel == "debug",
+		DisableStartupMessage:   true,
+		ProxyHeader:             proxyHeader,
+		EnableTrustedProxyCheck: true,
+		TrustedProxies:          trustedProxies,
+		EnableIPValidation:      true,
 		ErrorHandler: func(c *fiber.Ctx, err error) error {
 			code := fiber.StatusInternalServerError
 			if e, ok := err.(*fiber.Error); ok {
@@ -127,22 +149,67 @@ func main() {
 		MaxAge:           300,
 	}))
 
-	// Health check endpoint
-	app.Get("/health", handlers.HealthCheck)
-	app.Get("/api/health", handlers.HealthCheck)
+	// Cancelled when the server is asked to stop, to shut down background workers
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
 
-	// Initialize WebSocket hub
+	// Initialize WebSocket hub. It gets its own context: it is only stopped once
+	// in-flight requests, which may still broadcast, have finished.
 	fmt.Println("Initializing WebSocket hub...")
 	hub := handlers.NewHub()
-	go hub.Run()
+	hubCtx, stopHub := context.WithCancel(context.Background())
+	hubStopped := make(chan struct{})
+	go func() {
+		hub.Run(hubCtx)
+		close(hubStopped)
+	}()
 	fmt.Println("WebSocket hub initialized and running")
 
+	// Start the background worker that keeps challenge leaderboards precomputed
+	leaderboardInterval, err := strconv.Atoi(getEnvWithDefault("LEADERBOARD_REFRESH_INTERVAL_SECONDS", "300"))
+	if err != nil || leaderboardInterval <= 0 {
+		leaderboardInterval = 300
+	}
+	handlers.StartLeaderboardWorker(ctx, time.Duration(leaderboardInterval)*time.Second)
+
+	// Retry coding answers that couldn't be executed while the executor was down
+	gradingRetryInterval, err := strconv.Atoi(getEnvWithDefault("PENDING_GRADING_RETRY_SECONDS", "60"))
+	if err != nil || gradingRetryInterval <= 0 {
+		gradingRetryInterval = 60
+	}
+	handlers.StartPendingGradingWorker(ctx, time.Duration(gradingRetryInterval)*time.Second)
+
+	// Announce scheduled tests over the WebSocket as they start
+	testStartInterval, err := strconv.Atoi(getEnvWithDefault("TEST_START_CHECK_SECONDS", "30"))
+	if err != nil || testStartInterval <= 0 {
+		testStartInterval = 30
+	}
+	handlers.StartTestStartNotifier(ctx, hub, time.Duration(testStartInterval)*time.Second)
+
+	// Run challenge submissions made with ?async=true in the background
+	jobWorkers, err := strconv.Atoi(getEnvWithDefault("CHALLENGE_JOB_WORKERS", "4"))
+	if err != nil || jobWorkers <= 0 {
+		jobWorkers = 4
+	}
+	jobQueueSize, err := strconv.Atoi(getEnvWithDefault("CHALLENGE_JOB_QUEUE_SIZE", "100"))
+	if err != nil || jobQueueSize <= 0 {
+		jobQueueSize = 100
+	}
+	handlers.StartChallengeJobWorkers(ctx, hub, jobWorkers, jobQueueSize)
+
 	// Middleware to inject hub into context
 	hubMiddleware := func(c *fiber.Ctx) error {
 		c.Locals("hub", hub)
 		return c.Next()
 	}
 
+	// Health check endpoints: /health is an aggregate for humans, /healthz and
+	// /readyz are liveness and readiness probes for orchestrators
+	app.Get("/health", hubMiddleware, handlers.HealthCheck)
+	app.Get("/api/health", hubMiddleware, handlers.HealthCheck)
+	app.Get("/healthz", handlers.Liveness)
+	app.Get("/readyz", handlers.Readiness)
+
 	// WebSocket endpoint
 	app.Use("/ws", func(c *fiber.Ctx) error {
 		fmt.Printf("WebSocket upgrade request from %s\n", c.IP())
@@ -164,18 +231,33 @@ func main() {
 	// API routes
 	api := app.Group("/api")
 
+	// Content management (tests, challenges) is limited to admins and instructors;
+	// handlers further restrict instructors to the content they own
+	authRequired := handlers.AuthMiddleware()
+	authOptional := handlers.OptionalAuthMiddleware()
+	staffOnly := handlers.RoleMiddleware("admin", "instructor")
+	adminOnly := handlers.RoleMiddleware("admin")
+
 	// Auth routes
 	auth := api.Group("/auth")
 	auth.Post("/login", handlers.Login)
 	auth.Post("/register", handlers.Register)
 	auth.Get("/oauth/:provider", handlers.OAuthRedirect)
 	auth.Get("/oauth/:provider/callback", handlers.OAuthCallback)
+	auth.Post("/refresh", handlers.RefreshToken)
+	auth.Post("/logout", handlers.Logout)
+	auth.Get("/verify-token", authRequired, handlers.VerifyToken)
 
 	// Protected routes - requires authentication middleware
 	protectedApi := api.Group("/protected")
 	protectedApi.Use(handlers.AuthMiddleware())
 	protectedApi.Get("/user", handlers.GetCurrentUser)
 
+	// Per-user routes for the authenticated caller
+	me := api.Group("/me")
+	me.Use(handlers.AuthMiddleware())
+	me.Get("/dashboard", handlers.GetDashboard)
+
 	// Admin routes - requires authentication and admin role
 	adminApi := api.Group("/admin-protected")
 	adminApi.Use(handlers.AuthMiddleware(), handlers.RoleMiddleware("admin"))
@@ -189,16 +271,23 @@ func main() {
 	adminApi.Get("/test-results", handlers.GetTestResults)
 	adminApi.Get("/test-results/student/:studentId", handlers.GetTestResultsByStudent)
 	adminApi.Get("/test-results/test/:testId", handlers.GetTestResultsByTest)
+	adminApi.Get("/test-results/test/:testId/stats", handlers.GetTestStats)
 
 	// Admin data routes
 	adminApi.Get("/students", handlers.GetStudents)
 	adminApi.Get("/challenges", handlers.GetChallenges)
+	adminApi.Post("/challenges/:id/regrade", handlers.RegradeChallengeAttempts)
+	adminApi.Post("/leaderboards/refresh", handlers.RefreshLeaderboards)
 	adminApi.Get("/tests", handlers.GetTests)
+	adminApi.Get("/tests/:id/preview", handlers.PreviewTest)
 
 	// Questions routes
 	questions := api.Group("/questions")
 	questions.Post("/", handlers.CreateQuestion)
+	questions.Post("/bulk", authRequired, staffOnly, handlers.BulkCreateQuestions)
 	questions.Get("/", handlers.GetQuestions)
+	questions.Get("/tags", handlers.GetQuestionTags)
+	questions.Get("/stats", handlers.GetQuestionStats)
 	questions.Get("/:id", handlers.GetQuestion)
 	questions.Put("/:id", handlers.UpdateQuestion)
 	questions.Delete("/:id", handlers.DeleteQuestion)
@@ -208,23 +297,34 @@ func main() {
 	tests.Use(hubMiddleware) // Add hub to context for all test routes
 
 	// Specific routes first
-	tests.Get("/active", func(c *fiber.Ctx) error {
+	tests.Get("/active", authRequired, func(c *fiber.Ctx) error {
 		fmt.Printf("Handling /active request\n")
 		return handlers.GetActiveTests(c)
 	})
-	tests.Get("/scheduled", func(c *fiber.Ctx) error {
+	tests.Get("/scheduled", authRequired, func(c *fiber.Ctx) error {
 		fmt.Printf("Handling /scheduled request\n")
 		return handlers.GetScheduledTests(c)
 	})
-	tests.Get("/attempts/:attemptId", handlers.GetTestAttempt)
+	tests.Get("/attempts/:attemptId", authRequired, handlers.GetTestAttempt)
+	tests.Get("/archive", authRequired, staffOnly, handlers.GetTestArchive)
 
 	// Generic routes last
-	tests.Get("/", handlers.GetTests)
-	tests.Get("/:id", handlers.GetTest)
-	tests.Post("/", handlers.CreateTest)
-	tests.Put("/:id", handlers.UpdateTest)
-	tests.Delete("/:id", handlers.DeleteTest)
-	tests.Post("/:id/submit", handlers.SubmitTest)
+	tests.Get("/", authRequired, handlers.GetTests)
+	tests.Get("/:id", authRequired, handlers.GetTest)
+	tests.Post("/", authRequired, staffOnly, handlers.CreateTest)
+	tests.Post("/generate", authRequired, staffOnly, handlers.GenerateTest)
+	tests.Put("/:id", authRequired, staffOnly, handlers.UpdateTest)
+	tests.Delete("/:id", authRequired, staffOnly, handlers.DeleteTest)
+	tests.Post("/:id/archive", authRequired, staffOnly, handlers.ArchiveTest)
+	tests.Post("/:id/unarchive", authRequired, staffOnly, handlers.UnarchiveTest)
+	tests.Post("/:id/restore", authRequired, adminOnly, handlers.RestoreTest)
+	tests.Post("/:id/submit", authOptional, handlers.TestSubmitRateLimit(), handlers.SubmitTest)
+	tests.Post("/:id/start", authRequired, handlers.StartTest)
+	tests.Get("/:id/progress", authRequired, handlers.GetTestProgress)
+	tests.Post("/:id/progress", authRequired, handlers.SaveTestProgress)
+	tests.Post("/:id/answers", authRequired, handlers.SubmitTestAnswer)
+	tests.Post("/:id/finalize", authRequired, handlers.FinalizeTest)
+	tests.Get("/:id/review", authRequired, handlers.GetTestReview)
 
 	// Users routes
 	users := api.Group("/users")
@@ -236,20 +336,35 @@ func main() {
 
 	// Coding Challenges routes
 	challenges := api.Group("/challenges")
-	challenges.Post("/", handlers.CreateChallenge)
-	challenges.Get("/", handlers.GetChallenges)
-	challenges.Get("/:id", handlers.GetChallenge)
-	challenges.Put("/:id", handlers.UpdateChallenge)
-	challenges.Delete("/:id", handlers.DeleteChallenge)
-	challenges.Post("/:id/submit", handlers.SubmitChallengeAttempt)
-	challenges.Get("/:id/attempts", handlers.GetChallengeAttempts)
-	challenges.Get("/user/:userId/attempts", handlers.GetUserChallengeAttempts)
+	challenges.Post("/", authRequired, staffOnly, handlers.CreateChallenge)
+	challenges.Get("/", authOptional, handlers.GetChallenges)
+	challenges.Get("/languages", handlers.GetChallengeLanguages)
+	challenges.Get("/mine", authRequired, staffOnly, handlers.GetMyChallenges)
+	challenges.Get("/attempts/:id/status", authOptional, handlers.GetChallengeAttemptStatus)
+	challenges.Get("/:id", authOptional, handlers.GetChallenge)
+	challenges.Put("/:id", authRequired, staffOnly, handlers.UpdateChallenge)
+	challenges.Delete("/:id", authRequired, staffOnly, handlers.DeleteChallenge)
+	challenges.Post("/:id/restore", authRequired, adminOnly, handlers.RestoreChallenge)
+	// Instructors validate and publish their own drafts; admins any challenge
+	challenges.Post("/:id/validate-solution", authRequired, staffOnly, handlers.ValidateChallengeSolution)
+	challenges.Post("/:id/publish", authRequired, staffOnly, handlers.PublishChallenge)
+	challengeSubmitRateLimit := handlers.ChallengeSubmitRateLimit()
+	challenges.Post("/:id/submit", authOptional, challengeSubmitRateLimit, handlers.SubmitChallengeAttempt)
+	challenges.Post("/:id/run", authOptional, challengeSubmitRateLimit, handlers.RunChallengeCode)
+	challenges.Get("/:id/attempts", authOptional, handlers.GetChallengeAttempts)
+	challenges.Get("/:id/leaderboard", handlers.GetChallengeLeaderboard)
+	challenges.Get("/:id/stats", handlers.GetChallengeStats)
+	challenges.Get("/:id/solution", authRequired, handlers.GetChallengeSolution)
+	challenges.Get("/user/:userId/attempts", authOptional, handlers.GetUserChallengeAttempts)
+	challenges.Get("/:id/user/:userId/summary", authOptional, handlers.GetUserChallengeSummary)
 
 	// Students routes
 	students := api.Group("/students")
 	students.Post("/", handlers.CreateStudent)
 	students.Get("/", handlers.GetStudents)
+	students.Get("/leaderboard", handlers.GetStudentLeaderboard)
 	students.Get("/:id", handlers.GetStudent)
+	students.Get("/:id/tests", authRequired, handlers.GetStudentTests)
 	students.Put("/:id", handlers.UpdateStudent)
 	students.Delete("/:id", handlers.DeleteStudent)
 
@@ -264,9 +379,36 @@ func main() {
 	fmt.Printf("CORS allowed origins: %s\n", allowedOrigins)
 	fmt.Println("==========================================")
 
-	// Start server with graceful shutdown
+	// Start server with graceful shutdown. Listen returns as soon as Shutdown
+	// closes the listener, so main waits for in-flight requests separately.
+	shutdownTimeout := 30 * time.Second
+	serverStopped := make(chan struct{})
+	go func() {
+		defer close(serverStopped)
+		<-ctx.Done()
+		fmt.Println("Shutting down server...")
+		if err := app.ShutdownWithTimeout(shutdownTimeout); err != nil {
+			log.Printf("Error shutting down server: %v", err)
+		}
+	}()
 	if err := app.Listen(":" + port); err != nil {
 		fmt.Printf("Failed to start server: %v\n", err)
 		log.Fatal("Failed to start server:", err)
 	}
+	<-serverStopped
+
+	// Requests are done; let the workers finish their current pass and the hub
+	// flush its clients before the database goes away
+	if !handlers.WaitForWorkers(shutdownTimeout) {
+		log.Printf("Timed out waiting for background workers to stop")
+	}
+	stopHub()
+	<-hubStopped
+
+	disconnectCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := client.Disconnect(disconnectCtx); err != nil {
+		log.Printf("Error disconnecting from MongoDB: %v", err)
+	}
+	fmt.Println("Server stopped")
 }
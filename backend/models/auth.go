@@ -57,6 +57,8 @@ type OAuthUserInfo struct {
 	LastName  string `json:"lastName"`
 	Name      string `json:"name"`
 	Picture   string `json:"picture"`
+	// Whether the provider has verified that the user owns Email
+	Verified bool `json:"verified"`
 }
 
 // TokenClaims represents the claims in a JWT token
@@ -1,34 +1,169 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
+// Challenge lifecycle states. Challenges without a status predate the
+// workflow and are treated as published.
+const (
+	ChallengeStatusDraft     = "draft"
+	ChallengeStatusPublished = "published"
+	ChallengeStatusArchived  = "archived"
+)
+
 type CodingChallenge struct {
-	ID            primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
-	Title         string              `json:"title" bson:"title"`
-	Description   string              `json:"description" bson:"description"`
-	Difficulty    string              `json:"difficulty" bson:"difficulty"` // Easy, Medium, Hard
-	Category      string              `json:"category" bson:"category"`
-	TimeLimit     int                 `json:"timeLimit" bson:"timeLimit"` // Time limit in minutes
-	StarterCode   string              `json:"starterCode" bson:"starterCode"`
-	SolutionCode  string              `json:"solutionCode,omitempty" bson:"solutionCode,omitempty"` // For admin reference
-	Language      string              `json:"language" bson:"language"`
-	TestCases     []ChallengeTestCase `json:"testCases" bson:"testCases"`
-	MemoryLimitMB int                 `json:"memoryLimitMB" bson:"memoryLimitMB"`
-	TimeoutSec    int                 `json:"timeoutSec" bson:"timeoutSec"`
-	CreatedAt     time.Time           `json:"createdAt" bson:"createdAt"`
-	EndTime       *time.Time          `json:"endTime,omitempty" bson:"endTime,omitempty"` // When the challenge ends
+	ID                    primitive.ObjectID  `json:"id" bson:"_id,omitempty"`
+	Title                 string              `json:"title" bson:"title"`
+	Description           string              `json:"description" bson:"description"`
+	Difficulty            string              `json:"difficulty" bson:"difficulty"` // Easy, Medium, Hard
+	Category              string              `json:"category" bson:"category"`
+	TimeLimit             int                 `json:"timeLimit" bson:"timeLimit"` // Time limit in minutes
+	StarterCode           string              `json:"starterCode" bson:"starterCode"`
+	SolutionCode          string              `json:"solutionCode,omitempty" bson:"solutionCode,omitempty"` // For admin reference
+	Language              string              `json:"language" bson:"language"`
+	AllowedLanguages      []string            `json:"allowedLanguages,omitempty" bson:"allowedLanguages,omitempty"` // Languages accepted for submissions; falls back to Language when empty
+	TestCases             []ChallengeTestCase `json:"testCases" bson:"testCases"`
+	TestGroups            []TestGroup         `json:"testGroups,omitempty" bson:"testGroups,omitempty"` // Scoring rules for grouped test cases
+	MemoryLimitMB         int                 `json:"memoryLimitMB" bson:"memoryLimitMB"`
+	TimeoutSec            int                 `json:"timeoutSec" bson:"timeoutSec"`
+	Env                   map[string]string   `json:"env,omitempty" bson:"env,omitempty"`                                     // Environment variables set for every run
+	SetupFiles            []SetupFile         `json:"setupFiles,omitempty" bson:"setupFiles,omitempty"`                       // Files written into the working directory before every run
+	Checker               *ChallengeChecker   `json:"checker,omitempty" bson:"checker,omitempty"`                             // Special judge deciding test cases instead of output comparison
+	SubmissionCooldownSec int                 `json:"submissionCooldownSec,omitempty" bson:"submissionCooldownSec,omitempty"` // Minimum seconds between a student's submissions
+	Status                string              `json:"status,omitempty" bson:"status,omitempty"`                               // draft, published, archived
+	OwnerID               primitive.ObjectID  `json:"ownerId,omitempty" bson:"ownerId,omitempty"`                             // Instructor who created the challenge
+	SolutionReveal        string              `json:"solutionReveal,omitempty" bson:"solutionReveal,omitempty"`               // When students may see SolutionCode: never (default), after-pass, after-deadline
+	SimilarityMode        string              `json:"similarityMode,omitempty" bson:"similarityMode,omitempty"`               // What failed test cases score: fuzzy (default, partial credit) or exact (nothing)
+	GracePercent          float64             `json:"gracePercent,omitempty" bson:"gracePercent,omitempty"`                   // Fuzzy mode: output similarity (0-100) that earns full points; 0 means 90
+	CreatedAt             time.Time           `json:"createdAt" bson:"createdAt"`
+	UpdatedAt             time.Time           `json:"updatedAt" bson:"updatedAt,omitempty"`           // Last create, edit or status change
+	EndTime               *time.Time          `json:"endTime,omitempty" bson:"endTime,omitempty"`     // When the challenge ends
+	DeletedAt             *time.Time          `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"` // Set when soft-deleted; results still resolve the challenge
+}
+
+// SetupFile is a file made available to submitted code, e.g. a data file it reads.
+// Path is relative to the run's working directory; the executor enforces its limits.
+type SetupFile struct {
+	Path    string `json:"path" bson:"path"`
+	Content string `json:"content" bson:"content"`
+}
+
+// ChallengeChecker is a special-judge program for challenges with more than one
+// valid output. The executor runs it sandboxed for every test case with
+// input.txt, expected.txt and actual.txt in its working directory; exiting 0
+// accepts the output. Anything it prints is shown as the test case's feedback.
+type ChallengeChecker struct {
+	Language string `json:"language" bson:"language"`
+	Code     string `json:"code" bson:"code"`
+}
+
+// Solution reveal policies. Challenges without a policy never reveal their solution.
+const (
+	SolutionRevealNever         = "never"
+	SolutionRevealAfterPass     = "after-pass"
+	SolutionRevealAfterDeadline = "after-deadline"
+)
+
+// IsValidSolutionReveal reports whether policy is a supported reveal policy (empty means never)
+func IsValidSolutionReveal(policy string) bool {
+	switch policy {
+	case "", SolutionRevealNever, SolutionRevealAfterPass, SolutionRevealAfterDeadline:
+		return true
+	}
+	return false
+}
+
+// Similarity modes, deciding what a failed test case still scores. Challenges
+// without a mode are fuzzy.
+const (
+	SimilarityModeFuzzy = "fuzzy"
+	SimilarityModeExact = "exact"
+)
+
+// IsValidSimilarityMode reports whether mode is a supported similarity mode (empty means fuzzy)
+func IsValidSimilarityMode(mode string) bool {
+	return mode == "" || mode == SimilarityModeFuzzy || mode == SimilarityModeExact
+}
+
+// IsPublished reports whether students can see the challenge in listings
+func (ch *CodingChallenge) IsPublished() bool {
+	return ch.Status == "" || ch.Status == ChallengeStatusPublished
+}
+
+// SubmissionLanguages returns the languages a submission may be written in.
+// Challenges without AllowedLanguages accept only their single Language.
+func (ch *CodingChallenge) SubmissionLanguages() []string {
+	if len(ch.AllowedLanguages) > 0 {
+		return ch.AllowedLanguages
+	}
+	return []string{ch.Language}
 }
 
+// AllowsLanguage reports whether a submission in the given language is accepted
+func (ch *CodingChallenge) AllowsLanguage(language string) bool {
+	for _, l := range ch.SubmissionLanguages() {
+		if strings.EqualFold(l, language) {
+			return true
+		}
+	}
+	return false
+}
+
+// DefaultTestCasePoints is the weight the executor's validator gives test cases without PointsAvailable
+const DefaultTestCasePoints = 1.0
+
 type ChallengeTestCase struct {
 	Input           string  `json:"input" bson:"input"`
 	ExpectedOutput  string  `json:"expectedOutput" bson:"expectedOutput"`
 	Description     string  `json:"description" bson:"description"`
 	Hidden          bool    `json:"hidden" bson:"hidden"`                                       // Hidden test cases are not shown to users
 	PointsAvailable float64 `json:"pointsAvailable,omitempty" bson:"pointsAvailable,omitempty"` // Max points for this test case
+	CompareMode     string  `json:"compareMode,omitempty" bson:"compareMode,omitempty"`         // exact (default) or number
+	Group           string  `json:"group,omitempty" bson:"group,omitempty"`                     // Test group, e.g. "correctness"
+	TimeoutSec      int     `json:"timeoutSec,omitempty" bson:"timeoutSec,omitempty"`           // Overrides the challenge's TimeoutSec for this test case
+}
+
+// TestGroup gates a group's points on other groups: test cases in Name only
+// score once every test case in each Requires group has passed
+type TestGroup struct {
+	Name     string   `json:"name" bson:"name"`
+	Requires []string `json:"requires,omitempty" bson:"requires,omitempty"`
+}
+
+// GroupResult reports how one test group scored in a validation
+type GroupResult struct {
+	Name          string   `json:"name" bson:"name"`
+	TotalTests    int      `json:"totalTests" bson:"totalTests"`
+	PassedTests   int      `json:"passedTests" bson:"passedTests"`
+	Passed        bool     `json:"passed" bson:"passed"`
+	TotalPoints   float64  `json:"totalPoints" bson:"totalPoints"`
+	ScoredPoints  float64  `json:"scoredPoints" bson:"scoredPoints"`
+	Gated         bool     `json:"gated,omitempty" bson:"gated,omitempty"` // Points withheld because a required group failed
+	UnmetRequires []string `json:"unmetRequires,omitempty" bson:"unmetRequires,omitempty"`
+}
+
+// Output comparison modes for a test case. Number mode canonicalizes numeric
+// tokens before comparing, so "07" matches "7" and "1.0" matches "1".
+const (
+	CompareModeExact  = "exact"
+	CompareModeNumber = "number"
+)
+
+// IsValidCompareMode reports whether mode is a supported comparison mode (empty means exact)
+func IsValidCompareMode(mode string) bool {
+	return mode == "" || mode == CompareModeExact || mode == CompareModeNumber
+}
+
+// EffectivePoints returns the points the test case is worth when graded
+func (tc ChallengeTestCase) EffectivePoints() float64 {
+	if tc.PointsAvailable <= 0 {
+		return DefaultTestCasePoints
+	}
+	return tc.PointsAvailable
 }
 
 type ChallengeAttempt struct {
@@ -37,21 +172,49 @@ type ChallengeAttempt struct {
 	ChallengeID primitive.ObjectID `json:"challengeId" bson:"challengeId"`
 	Code        string             `json:"code" bson:"code"`
 	Language    string             `json:"language" bson:"language"`
-	Status      string             `json:"status" bson:"status"` // "Submitted", "Passed", "Failed"
+	Status      string             `json:"status" bson:"status"` // "Submitted", "Passed", "Failed", or Pending/Error for async submissions
 	Result      ValidationResult   `json:"result" bson:"result"`
 	TimeSpent   int                `json:"timeSpent" bson:"timeSpent"` // Time spent in seconds
 	CreatedAt   time.Time          `json:"createdAt" bson:"createdAt"`
+	// Similarity of the code to the challenge's reference solution (0-1), and
+	// whether it crossed the review threshold. Shown to staff only.
+	SolutionSimilarity float64 `json:"solutionSimilarity,omitempty" bson:"solutionSimilarity,omitempty"`
+	MatchesSolution    bool    `json:"matchesSolution,omitempty" bson:"matchesSolution,omitempty"`
+	// Why an asynchronous submission couldn't be executed, when Status is Error
+	Error string `json:"error,omitempty" bson:"error,omitempty"`
+	// Whether UserID is the signed-in submitter rather than an ID taken from the
+	// request body. Only authenticated attempts earn points.
+	Authenticated bool `json:"-" bson:"authenticated,omitempty"`
 }
 
+// SubmissionCooldown records when a submitter may next submit to a challenge
+// with a submission cooldown. Key names the submitter as the submission guard
+// does: "user:<id>" when signed in, otherwise "ip:<address>". There is at most
+// one per key and challenge, so claiming the next submission is a single
+// atomic upsert.
+type SubmissionCooldown struct {
+	ID            primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	Key           string             `json:"key" bson:"key"`
+	ChallengeID   primitive.ObjectID `json:"challengeId" bson:"challengeId"`
+	NextAllowedAt time.Time          `json:"nextAllowedAt" bson:"nextAllowedAt"`
+}
+
+// Statuses of attempts submitted asynchronously before they are graded
+const (
+	AttemptStatusPending = "Pending" // Queued or running
+	AttemptStatusError   = "Error"   // The code couldn't be executed; see Error
+)
+
 type ValidationResult struct {
-	Passed          bool         `json:"passed" bson:"passed"`
-	TestCases       []TestResult `json:"testCases" bson:"testCases"`
-	TotalTests      int          `json:"totalTests" bson:"totalTests"`
-	PassedTests     int          `json:"passedTests" bson:"passedTests"`
-	FailedTests     int          `json:"failedTests" bson:"failedTests"`
-	TotalPoints     float64      `json:"totalPoints" bson:"totalPoints"`         // Total points available
-	ScoredPoints    float64      `json:"scoredPoints" bson:"scoredPoints"`       // Points earned
-	PercentageScore float64      `json:"percentageScore" bson:"percentageScore"` // Overall score (0-100)
+	Passed          bool          `json:"passed" bson:"passed"`
+	TestCases       []TestResult  `json:"testCases" bson:"testCases"`
+	TotalTests      int           `json:"totalTests" bson:"totalTests"`
+	PassedTests     int           `json:"passedTests" bson:"passedTests"`
+	FailedTests     int           `json:"failedTests" bson:"failedTests"`
+	TotalPoints     float64       `json:"totalPoints" bson:"totalPoints"`           // Total points available
+	ScoredPoints    float64       `json:"scoredPoints" bson:"scoredPoints"`         // Points earned
+	PercentageScore float64       `json:"percentageScore" bson:"percentageScore"`   // Overall score (0-100)
+	Groups          []GroupResult `json:"groups,omitempty" bson:"groups,omitempty"` // Per-group results when test cases are grouped
 }
 
 type TestResult struct {
@@ -59,10 +222,16 @@ type TestResult struct {
 	Input           string  `json:"input" bson:"input"`
 	ExpectedOutput  string  `json:"expectedOutput" bson:"expectedOutput"`
 	ActualOutput    string  `json:"actualOutput" bson:"actualOutput"`
+	OutputTruncated bool    `json:"outputTruncated,omitempty" bson:"outputTruncated,omitempty"` // ActualOutput was cut for display
+	OutputLength    int     `json:"outputLength,omitempty" bson:"outputLength,omitempty"`       // Length of the full output
 	Description     string  `json:"description" bson:"description"`
 	Hidden          bool    `json:"hidden" bson:"hidden"`
 	Stderr          string  `json:"stderr,omitempty" bson:"stderr,omitempty"`
 	SimilarityScore float64 `json:"similarityScore,omitempty" bson:"similarityScore,omitempty"` // How closely output matches (0-1)
 	PointsAvailable float64 `json:"pointsAvailable,omitempty" bson:"pointsAvailable,omitempty"` // Max points for test case
 	PointsScored    float64 `json:"pointsScored,omitempty" bson:"pointsScored,omitempty"`       // Points awarded
+	Group           string  `json:"group,omitempty" bson:"group,omitempty"`
+	CheckerMessage  string  `json:"checkerMessage,omitempty" bson:"checkerMessage,omitempty"` // Feedback printed by the challenge's checker
+	FailureReason   string  `json:"failureReason,omitempty" bson:"failureReason,omitempty"`   // Why a failed test case failed, e.g. time_limit_exceeded
+	ExecutionTime   float64 `json:"executionTime" bson:"executionTime"`                       // Wall-clock seconds the test case's run took
 }
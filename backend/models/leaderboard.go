@@ -0,0 +1,72 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// ChallengeLeaderboard is a precomputed ranking and summary for one challenge.
+// It is rebuilt in the background, so ComputedAt tells clients how fresh it is.
+type ChallengeLeaderboard struct {
+	ChallengeID primitive.ObjectID `json:"challengeId" bson:"_id"`
+	Entries     []LeaderboardEntry `json:"entries" bson:"entries"`
+	Stats       ChallengeStats     `json:"stats" bson:"stats"`
+	ComputedAt  time.Time          `json:"computedAt" bson:"computedAt"`
+}
+
+// LeaderboardEntry is one user's best result on a challenge
+type LeaderboardEntry struct {
+	Rank          int                `json:"rank" bson:"rank"`
+	UserID        primitive.ObjectID `json:"userId" bson:"userId"`
+	UserName      string             `json:"userName" bson:"userName"`
+	BestScore     float64            `json:"bestScore" bson:"bestScore"` // Best percentage score (0-100)
+	Passed        bool               `json:"passed" bson:"passed"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	FirstPassedAt *time.Time         `json:"firstPassedAt,omitempty" bson:"firstPassedAt,omitempty"`
+	// FastestTimeSpent is the shortest TimeSpent of a passing attempt, in seconds;
+	// it breaks ties between equal scores
+	FastestTimeSpent *int `json:"fastestTimeSpent,omitempty" bson:"fastestTimeSpent,omitempty"`
+}
+
+// ChallengeStats summarises all attempts on a challenge
+type ChallengeStats struct {
+	TotalAttempts  int     `json:"totalAttempts" bson:"totalAttempts"`
+	PassedAttempts int     `json:"passedAttempts" bson:"passedAttempts"`
+	AcceptanceRate float64 `json:"acceptanceRate" bson:"acceptanceRate"` // Share of submissions that passed (0-100)
+	UniqueUsers    int     `json:"uniqueUsers" bson:"uniqueUsers"`
+	PassedUsers    int     `json:"passedUsers" bson:"passedUsers"`
+	PassRate       float64 `json:"passRate" bson:"passRate"`         // Share of users who passed (0-100)
+	AverageScore   float64 `json:"averageScore" bson:"averageScore"` // Mean of users' best scores (0-100)
+}
+
+// ChallengeAttemptSummary is one user's history on a challenge, as shown when
+// they open it
+type ChallengeAttemptSummary struct {
+	ChallengeID   primitive.ObjectID `json:"challengeId" bson:"challengeId"`
+	UserID        primitive.ObjectID `json:"userId" bson:"userId"`
+	Attempts      int                `json:"attempts" bson:"attempts"`
+	BestScore     float64            `json:"bestScore" bson:"bestScore"` // Best percentage score (0-100)
+	LatestStatus  string             `json:"latestStatus,omitempty" bson:"latestStatus,omitempty"`
+	LastAttemptAt *time.Time         `json:"lastAttemptAt,omitempty" bson:"lastAttemptAt,omitempty"`
+	Passed        bool               `json:"passed" bson:"passed"` // Whether any attempt passed
+}
+
+// PointAward records the points a student earned for passing a challenge. There
+// is at most one per student and challenge, which keeps awards to once each.
+type PointAward struct {
+	ID          primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	UserID      primitive.ObjectID `json:"userId" bson:"userId"`
+	ChallengeID primitive.ObjectID `json:"challengeId" bson:"challengeId"`
+	AttemptID   primitive.ObjectID `json:"attemptId" bson:"attemptId"` // The first passing attempt
+	Points      int                `json:"points" bson:"points"`
+	AwardedAt   time.Time          `json:"awardedAt" bson:"awardedAt"`
+}
+
+// StudentRanking is one student on the global points leaderboard
+type StudentRanking struct {
+	Rank      int                `json:"rank"`
+	StudentID primitive.ObjectID `json:"studentId"`
+	Name      string             `json:"name"`
+	Points    int                `json:"points"`
+}
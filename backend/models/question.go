@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -15,9 +16,96 @@ type Question struct {
 	CreatedAt     time.Time          `json:"createdAt" bson:"createdAt"`
 	Options       []string           `json:"options,omitempty" bson:"options,omitempty"`
 	CorrectOption int                `json:"correctOption,omitempty" bson:"correctOption,omitempty"`
-	StarterCode   string             `json:"starterCode,omitempty" bson:"starterCode,omitempty"`
-	TestCases     []TestCase         `json:"testCases,omitempty" bson:"testCases,omitempty"`
-	CorrectAnswer string             `json:"correctAnswer,omitempty" bson:"correctAnswer,omitempty"`
+	// CorrectOptions are the option indices a multi_mcq (multi-select) question expects
+	CorrectOptions []int      `json:"correctOptions,omitempty" bson:"correctOptions,omitempty"`
+	StarterCode    string     `json:"starterCode,omitempty" bson:"starterCode,omitempty"`
+	TestCases      []TestCase `json:"testCases,omitempty" bson:"testCases,omitempty"`
+	Language       string     `json:"language,omitempty" bson:"language,omitempty"` // Language coding answers run in unless the answer names one
+	CorrectAnswer  string     `json:"correctAnswer,omitempty" bson:"correctAnswer,omitempty"`
+	// AcceptedAnswers lists the phrasings a short-answer (subjective) question accepts
+	AcceptedAnswers []AcceptedAnswer `json:"acceptedAnswers,omitempty" bson:"acceptedAnswers,omitempty"`
+	// Explanation and References are shown in the review once the test has closed
+	Explanation string              `json:"explanation,omitempty" bson:"explanation,omitempty"`
+	References  []QuestionReference `json:"references,omitempty" bson:"references,omitempty"`
+	// Category and Tags organize the question bank by topic. Tags are stored lowercase.
+	Category string   `json:"category,omitempty" bson:"category,omitempty"`
+	Tags     []string `json:"tags,omitempty" bson:"tags,omitempty"`
+	// Difficulty is Easy, Medium or Hard, like a challenge's; empty means unrated
+	Difficulty string `json:"difficulty,omitempty" bson:"difficulty,omitempty"`
+}
+
+// Question difficulties
+const (
+	QuestionDifficultyEasy   = "Easy"
+	QuestionDifficultyMedium = "Medium"
+	QuestionDifficultyHard   = "Hard"
+)
+
+// NormalizeQuestionDifficulty returns the canonical spelling of a difficulty
+// given in any case, or the trimmed input if it isn't one
+func NormalizeQuestionDifficulty(difficulty string) string {
+	difficulty = strings.TrimSpace(difficulty)
+	for _, known := range []string{QuestionDifficultyEasy, QuestionDifficultyMedium, QuestionDifficultyHard} {
+		if strings.EqualFold(difficulty, known) {
+			return known
+		}
+	}
+	return difficulty
+}
+
+// IsValidQuestionDifficulty reports whether difficulty is supported (empty means unrated)
+func IsValidQuestionDifficulty(difficulty string) bool {
+	switch difficulty {
+	case "", QuestionDifficultyEasy, QuestionDifficultyMedium, QuestionDifficultyHard:
+		return true
+	}
+	return false
+}
+
+// QuestionReference is a link to further reading on a question's topic
+type QuestionReference struct {
+	Title string `json:"title,omitempty" bson:"title,omitempty"`
+	URL   string `json:"url" bson:"url"`
+}
+
+// Match modes for accepted short answers. Answers are trimmed before matching;
+// an empty mode means case-insensitive.
+const (
+	AnswerMatchExact           = "exact"
+	AnswerMatchCaseInsensitive = "case-insensitive"
+	AnswerMatchContains        = "contains"
+)
+
+// AcceptedAnswer is one acceptable phrasing of a short answer
+type AcceptedAnswer struct {
+	Answer    string `json:"answer" bson:"answer"`
+	MatchMode string `json:"matchMode,omitempty" bson:"matchMode,omitempty"`
+}
+
+// Matches reports whether a student's answer satisfies this variant
+func (a AcceptedAnswer) Matches(answer string) bool {
+	answer = strings.TrimSpace(answer)
+	expected := strings.TrimSpace(a.Answer)
+	if expected == "" {
+		return false
+	}
+	switch a.MatchMode {
+	case AnswerMatchExact:
+		return answer == expected
+	case AnswerMatchContains:
+		return strings.Contains(strings.ToLower(answer), strings.ToLower(expected))
+	default:
+		return strings.EqualFold(answer, expected)
+	}
+}
+
+// IsValidAnswerMatchMode reports whether mode is a supported match mode
+func IsValidAnswerMatchMode(mode string) bool {
+	switch mode {
+	case "", AnswerMatchExact, AnswerMatchCaseInsensitive, AnswerMatchContains:
+		return true
+	}
+	return false
 }
 
 type TestCase struct {
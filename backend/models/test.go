@@ -6,7 +6,8 @@ import (
 	"go.mongodb.org/mongo-driver/bson/primitive"
 )
 
-// Test represents the test document structure for API responses
+// Test represents the test document structure for API responses.
+// StartTime and EndTime are always stored and returned in UTC.
 type Test struct {
 	ID              string     `json:"id,omitempty" bson:"_id,omitempty"`
 	Title           string     `json:"title" bson:"title"`
@@ -14,8 +15,29 @@ type Test struct {
 	StartTime       time.Time  `json:"startTime" bson:"startTime"`
 	EndTime         time.Time  `json:"endTime" bson:"endTime"`
 	Duration        int        `json:"duration" bson:"duration"`
-	Questions       []Question `json:"questions" bson:"questions"`             // Slice of full Question objects for API response
-	AllowedStudents []string   `json:"allowedStudents" bson:"allowedStudents"` // Updated to string for parsing
+	Questions       []Question `json:"questions" bson:"questions"`                 // Slice of full Question objects for API response
+	AllowedStudents []string   `json:"allowedStudents" bson:"allowedStudents"`     // Updated to string for parsing
+	OwnerID         string     `json:"ownerId,omitempty" bson:"ownerId,omitempty"` // Instructor who created the test
+	Mode            string     `json:"mode,omitempty" bson:"mode,omitempty"`       // free (default) or sequential
+	LateStartMins   int        `json:"lateStartMinutes,omitempty" bson:"lateStartMinutes,omitempty"`
+	PassThreshold   float64    `json:"passThreshold,omitempty" bson:"passThreshold,omitempty"`
+	UpdatedAt       time.Time  `json:"updatedAt" bson:"updatedAt,omitempty"`
+	Archived        bool       `json:"archived,omitempty" bson:"archived,omitempty"`
+	ArchivedAt      *time.Time `json:"archivedAt,omitempty" bson:"archivedAt,omitempty"`
+	// PersonalDeadline is when the requesting student's time runs out; only set on the active-test listing
+	PersonalDeadline *time.Time `json:"personalDeadline,omitempty" bson:"-"`
+}
+
+// Test modes. In sequential mode answers are accepted one question at a time, in
+// order, and can't be changed once recorded.
+const (
+	TestModeFree       = "free"
+	TestModeSequential = "sequential"
+)
+
+// IsValidTestMode reports whether mode is a supported test mode (empty means free)
+func IsValidTestMode(mode string) bool {
+	return mode == "" || mode == TestModeFree || mode == TestModeSequential
 }
 
 // CreateTestRequest represents the request body for creating a new test
@@ -27,6 +49,26 @@ type CreateTestRequest struct {
 	Duration        int       `json:"duration" bson:"duration"`
 	Questions       []string  `json:"questions" bson:"questions"`             // Array of question IDs
 	AllowedStudents []string  `json:"allowedStudents" bson:"allowedStudents"` // Array of student IDs
+	Mode            string    `json:"mode,omitempty" bson:"mode,omitempty"`   // free (default) or sequential
+	LateStartMins   int       `json:"lateStartMinutes,omitempty" bson:"lateStartMinutes,omitempty"`
+	PassThreshold   *float64  `json:"passThreshold,omitempty" bson:"passThreshold,omitempty"` // Omitted means DefaultPassThreshold
+}
+
+// QuestionCriteria selects questions from the bank for a generated test. Empty
+// fields match any question; a question must carry every listed tag.
+type QuestionCriteria struct {
+	Count      int      `json:"count"`
+	Type       string   `json:"type,omitempty"`
+	Difficulty string   `json:"difficulty,omitempty"`
+	Category   string   `json:"category,omitempty"`
+	Tags       []string `json:"tags,omitempty"`
+}
+
+// GenerateTestRequest creates a test whose questions are sampled at random by
+// Criteria; the Questions field of the embedded request is ignored
+type GenerateTestRequest struct {
+	CreateTestRequest
+	Criteria QuestionCriteria `json:"criteria"`
 }
 
 // TestBSON represents the test document structure as stored in MongoDB
@@ -37,22 +79,111 @@ type TestBSON struct {
 	StartTime       time.Time            `json:"startTime" bson:"startTime"`
 	EndTime         time.Time            `json:"endTime" bson:"endTime"`
 	Duration        int                  `json:"duration" bson:"duration"`
-	Questions       []primitive.ObjectID `json:"questions" bson:"questions"`             // Slice of Question ObjectIDs as stored in DB
-	AllowedStudents []string             `json:"allowedStudents" bson:"allowedStudents"` // Slice of Student IDs as stored in DB (assuming strings)
+	Questions       []primitive.ObjectID `json:"questions" bson:"questions"`                 // Slice of Question ObjectIDs as stored in DB
+	AllowedStudents []string             `json:"allowedStudents" bson:"allowedStudents"`     // Slice of Student IDs as stored in DB (assuming strings)
+	OwnerID         primitive.ObjectID   `json:"ownerId,omitempty" bson:"ownerId,omitempty"` // Instructor who created the test
+	Mode            string               `json:"mode,omitempty" bson:"mode,omitempty"`       // free (default) or sequential
+	// LateStartMins lets a student start up to this many minutes after StartTime and
+	// still get the full Duration, capped at EndTime. Zero keeps the fixed window.
+	LateStartMins int `json:"lateStartMinutes,omitempty" bson:"lateStartMinutes,omitempty"`
+	// PassThreshold is the percentage a submission needs to pass, above 0. Zero means unset,
+	// so DefaultPassThreshold applies.
+	PassThreshold float64   `json:"passThreshold,omitempty" bson:"passThreshold,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt" bson:"updatedAt,omitempty"` // Last create or edit
+	// Archived tests are hidden from students but kept for staff to browse and reuse
+	Archived   bool       `json:"archived,omitempty" bson:"archived,omitempty"`
+	ArchivedAt *time.Time `json:"archivedAt,omitempty" bson:"archivedAt,omitempty"`
+	// Deleted tests are hidden everywhere except results, which still need their
+	// titles; an admin can restore them
+	DeletedAt *time.Time `json:"deletedAt,omitempty" bson:"deletedAt,omitempty"`
+}
+
+// DefaultPassThreshold is the pass mark of tests that don't set their own
+const DefaultPassThreshold = 70.0
+
+// PassMark returns the percentage a submission needs to pass this test
+func (t TestBSON) PassMark() float64 {
+	if t.PassThreshold > 0 {
+		return t.PassThreshold
+	}
+	return DefaultPassThreshold
+}
+
+// TestProgress records when a student started a test and, for sequential-mode
+// tests, their answers as they are given. Answers follow the test's question order
+// and are never rewritten; the progress is finalized into a TestSubmission once
+// the student is done.
+type TestProgress struct {
+	ID           primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TestID       string             `json:"testId" bson:"testId"`
+	StudentID    string             `json:"studentId" bson:"studentId"`
+	Answers      []Answer           `json:"answers" bson:"answers"`
+	StartedAt    time.Time          `json:"startedAt" bson:"startedAt"`
+	FinalizedAt  *time.Time         `json:"finalizedAt,omitempty" bson:"finalizedAt,omitempty"`
+	SubmissionID string             `json:"submissionId,omitempty" bson:"submissionId,omitempty"`
+}
+
+// AttemptProgress holds the answers a student has saved so far on a free-mode
+// test, so they survive a crashed browser. StartedAt is copied from the start the
+// server recorded in TestProgress and never changed. The record is merged into
+// the submission and deleted when the test is submitted.
+type AttemptProgress struct {
+	ID        primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	TestID    string             `json:"testId" bson:"testId"`
+	StudentID string             `json:"studentId" bson:"studentId"`
+	Answers   []Answer           `json:"answers" bson:"answers"`
+	StartedAt time.Time          `json:"startedAt" bson:"startedAt"`
+	UpdatedAt time.Time          `json:"updatedAt" bson:"updatedAt"`
 }
 
 type TestSubmission struct {
 	ID           string    `json:"id,omitempty" bson:"_id,omitempty"`
+	Reference    string    `json:"referenceCode,omitempty" bson:"referenceCode,omitempty"` // Human-readable code students quote to support, e.g. ATT-7F3K9QW2MX
 	TestID       string    `json:"testId" bson:"testId"`
 	StudentID    string    `json:"studentId" bson:"studentId"`
 	StudentName  string    `json:"studentName" bson:"studentName"`
 	StudentEmail string    `json:"studentEmail" bson:"studentEmail"`
-	TimeSpent    int       `json:"timeSpent" bson:"timeSpent"` // Time spent in seconds
+	TimeSpent    int       `json:"timeSpent" bson:"timeSpent"` // Time spent in seconds, as reported by the client
 	SubmittedAt  time.Time `json:"submittedAt" bson:"submittedAt"`
 	Answers      []Answer  `json:"answers" bson:"answers"`
+
+	// ServerTimeSpent is the seconds from the start the server recorded to
+	// submission; nil when the student submitted without a recorded start
+	ServerTimeSpent *int `json:"serverTimeSpent,omitempty" bson:"serverTimeSpent,omitempty"`
+	// Untimed flags a submission made without a recorded start, whose time
+	// spent can't be verified
+	Untimed bool `json:"untimed,omitempty" bson:"untimed,omitempty"`
+
+	// Per-question grading computed at submission time
+	QuestionResults []QuestionResult `json:"questionResults,omitempty" bson:"questionResults,omitempty"`
+	// GradingStatus is pending while coding answers wait for the executor; empty means complete
+	GradingStatus string `json:"gradingStatus,omitempty" bson:"gradingStatus,omitempty"`
+
+	// Score computed when the submission is graded, so results listings needn't
+	// refetch its questions. Status is empty on attempts stored before scores were.
+	PointsScored    float64 `json:"pointsScored" bson:"pointsScored"`
+	TotalPoints     int     `json:"totalPoints" bson:"totalPoints"`
+	PercentageScore float64 `json:"percentageScore" bson:"percentageScore"`
+	Status          string  `json:"status,omitempty" bson:"status,omitempty"`
+}
+
+// Grading states for submissions and individual question results
+const (
+	GradingComplete = "complete"
+	GradingPending  = "pending" // The answer couldn't be executed yet and will be retried
+)
+
+// QuestionResult records how a single answer in a submission was graded
+type QuestionResult struct {
+	QuestionID    string  `json:"questionId" bson:"questionId"`
+	AwardedPoints float64 `json:"awardedPoints" bson:"awardedPoints"`
+	Correct       bool    `json:"correct" bson:"correct"`
+	MatchedAnswer string  `json:"matchedAnswer,omitempty" bson:"matchedAnswer,omitempty"` // Accepted short-answer variant the answer matched
+	Status        string  `json:"status,omitempty" bson:"status,omitempty"`               // pending until a coding answer has been executed; empty means graded
 }
 
 type Answer struct {
 	QuestionID string `json:"questionId" bson:"questionId"`
 	Answer     string `json:"answer" bson:"answer"`
+	Language   string `json:"language,omitempty" bson:"language,omitempty"` // Coding answers only; defaults to the question's language
 }
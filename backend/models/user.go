@@ -19,11 +19,34 @@ type User struct {
 	UpdatedAt   time.Time          `bson:"updatedAt" json:"updatedAt"`
 }
 
-// Session represents a user's active session
+// Session represents a user's active session. Refresh-token sessions store only
+// a hash of the token and belong to a family: every token rotated from the same
+// login shares its FamilyID.
 type Session struct {
-	ID        primitive.ObjectID `bson:"_id,omitempty" json:"id"`
-	UserID    primitive.ObjectID `bson:"userId" json:"userId"`
-	Token     string             `bson:"token" json:"token"`
-	CreatedAt time.Time          `bson:"createdAt" json:"createdAt"`
+	ID          primitive.ObjectID `bson:"_id,omitempty" json:"id"`
+	UserID      primitive.ObjectID `bson:"userId" json:"userId"`
+	Token       string             `bson:"token,omitempty" json:"token,omitempty"`
+	RefreshHash string             `bson:"refreshHash,omitempty" json:"-"`
+	FamilyID    string             `bson:"familyId,omitempty" json:"familyId,omitempty"`
+	RotatedAt   *time.Time         `bson:"rotatedAt,omitempty" json:"rotatedAt,omitempty"` // Set once the refresh token has been exchanged
+	CreatedAt   time.Time          `bson:"createdAt" json:"createdAt"`
+	ExpiresAt   time.Time          `bson:"expiresAt" json:"expiresAt"`
+}
+
+// OAuthState is a CSRF state issued by OAuthRedirect. The callback consumes it,
+// so each state is accepted once; Mongo purges unused ones after they expire.
+type OAuthState struct {
+	State     string    `bson:"_id" json:"state"`
+	Provider  string    `bson:"provider" json:"provider"`
+	CreatedAt time.Time `bson:"createdAt" json:"createdAt"`
+	ExpiresAt time.Time `bson:"expiresAt" json:"expiresAt"`
+}
+
+// RevokedToken blacklists a JWT by its jti until the token would have expired
+// anyway, at which point Mongo purges the record
+type RevokedToken struct {
+	JTI       string             `bson:"_id" json:"jti"`
+	UserID    primitive.ObjectID `bson:"userId,omitempty" json:"userId,omitempty"`
+	RevokedAt time.Time          `bson:"revokedAt" json:"revokedAt"`
 	ExpiresAt time.Time          `bson:"expiresAt" json:"expiresAt"`
 }
@@ -0,0 +1,74 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Defaults for admin batch operations (regrading, solution validation)
+const (
+	defaultBatchConcurrency    = 4
+	defaultBatchTimeoutSeconds = 120
+)
+
+// BatchConfig bounds how hard an admin batch operation may hit the executor
+type BatchConfig struct {
+	Concurrency int
+	Timeout     time.Duration
+}
+
+// LoadBatchConfig reads BATCH_EXECUTION_CONCURRENCY and BATCH_OPERATION_TIMEOUT_SECONDS
+func LoadBatchConfig() BatchConfig {
+	config := BatchConfig{
+		Concurrency: defaultBatchConcurrency,
+		Timeout:     defaultBatchTimeoutSeconds * time.Second,
+	}
+	if value, err := strconv.Atoi(os.Getenv("BATCH_EXECUTION_CONCURRENCY")); err == nil && value > 0 {
+		config.Concurrency = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("BATCH_OPERATION_TIMEOUT_SECONDS")); err == nil && value > 0 {
+		config.Timeout = time.Duration(value) * time.Second
+	}
+	return config
+}
+
+// RunBatch calls fn for every index in [0, n) using at most concurrency workers
+// and returns one error per index. Once ctx is done no new items are started;
+// those items report ctx.Err() so callers can return partial results.
+func RunBatch(ctx context.Context, n int, concurrency int, fn func(ctx context.Context, i int) error) []error {
+	errs := make([]error, n)
+	if concurrency <= 0 {
+		concurrency = 1
+	}
+
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < concurrency; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				errs[i] = fn(ctx, i)
+			}
+		}()
+	}
+
+dispatch:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			for j := i; j < n; j++ {
+				errs[j] = ctx.Err()
+			}
+			break dispatch
+		}
+	}
+	close(jobs)
+	wg.Wait()
+
+	return errs
+}
@@ -2,37 +2,140 @@ package services
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"net"
 	"net/http"
 	"os"
 	"qms-backend/models"
+	"strconv"
+	"sync"
 	"time"
 )
 
+// defaultOutputDisplayLimit is the number of characters of program output
+// kept on a stored test result when OUTPUT_DISPLAY_LIMIT is not set
+const defaultOutputDisplayLimit = 2000
+
+// truncatedMarker is appended to outputs that were cut for display
+const truncatedMarker = "...truncated"
+
+// ErrIncompleteExecutorResponse is returned when the executor answers 200 but its
+// body can't be decoded, or its validation result is missing parts or doesn't
+// cover every test case
+var ErrIncompleteExecutorResponse = errors.New("incomplete response from code execution engine")
+
+// maxErrorBodyBytes is how much of a failed executor response is kept on the error
+const maxErrorBodyBytes = 1024
+
+// requestIDHeader carries the correlation ID shared by backend and executor logs
+const requestIDHeader = "X-Request-ID"
+
+// ExecutorError is returned when the code execution engine answers with a non-200
+// status. Body holds the (truncated) response so failures can be diagnosed.
+type ExecutorError struct {
+	StatusCode int
+	Body       string
+	RequestID  string
+}
+
+func (e *ExecutorError) Error() string {
+	return fmt.Sprintf("code execution engine returned status code %d (request %s): %s", e.StatusCode, e.RequestID, e.Body)
+}
+
+// IsClientError reports whether the executor rejected the request itself (4xx)
+// rather than failing to process it (5xx)
+func (e *ExecutorError) IsClientError() bool {
+	return e.StatusCode >= 400 && e.StatusCode < 500
+}
+
+// IsUnavailable reports whether the executor, or a proxy in front of it, said it
+// couldn't take the request right now (502, 503 or 504)
+func (e *ExecutorError) IsUnavailable() bool {
+	switch e.StatusCode {
+	case http.StatusBadGateway, http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true
+	}
+	return false
+}
+
+// newExecutorError reads the body of a failed executor response into an ExecutorError
+func newExecutorError(resp *http.Response, requestID string) *ExecutorError {
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBodyBytes+1))
+	text := string(bytes.TrimSpace(body))
+	if len(body) > maxErrorBodyBytes {
+		text = string(body[:maxErrorBodyBytes]) + truncatedMarker
+	}
+	if id := resp.Header.Get(requestIDHeader); id != "" {
+		requestID = id
+	}
+	return &ExecutorError{StatusCode: resp.StatusCode, Body: text, RequestID: requestID}
+}
+
+// newRequestID returns a random correlation ID for an executor call
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return strconv.FormatInt(time.Now().UnixNano(), 16)
+	}
+	return hex.EncodeToString(buf)
+}
+
 type CodeExecutionService struct {
-	baseURL string
-	client  *http.Client
+	baseURL            string
+	client             *http.Client
+	outputDisplayLimit int
+	retry              RetryConfig
 }
 
 type ExecutionRequest struct {
-	Language  string              `json:"language"`
-	Code      string              `json:"code"`
-	Input     string              `json:"input"`
-	Config    ExecutionConfig     `json:"config"`
-	TestCases []ExecutionTestCase `json:"test_cases"`
+	Language   string               `json:"language"`
+	Code       string               `json:"code"`
+	Input      string               `json:"input"`
+	Config     ExecutionConfig      `json:"config"`
+	TestCases  []ExecutionTestCase  `json:"test_cases"`
+	TestGroups []ExecutionTestGroup `json:"test_groups,omitempty"`
+	Checker    *ExecutionChecker    `json:"checker,omitempty"`
+}
+
+// ExecutionChecker is a special-judge program that replaces output comparison
+type ExecutionChecker struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
 }
 
 type ExecutionConfig struct {
-	TimeoutSeconds int   `json:"timeout_seconds"`
-	MemoryLimitMB  int64 `json:"memory_limit_mb"`
+	TimeoutSeconds int                  `json:"timeout_seconds"`
+	MemoryLimitMB  int64                `json:"memory_limit_mb"`
+	Env            map[string]string    `json:"env,omitempty"`
+	SetupFiles     []ExecutionSetupFile `json:"setup_files,omitempty"`
+	SimilarityMode string               `json:"similarity_mode,omitempty"`
+	GracePercent   float64              `json:"grace_percent,omitempty"`
+}
+
+type ExecutionSetupFile struct {
+	Path    string `json:"path"`
+	Content string `json:"content"`
 }
 
 type ExecutionTestCase struct {
-	Input          string `json:"input"`
-	ExpectedOutput string `json:"expected_output"`
-	Description    string `json:"description"`
+	Input           string  `json:"input"`
+	ExpectedOutput  string  `json:"expected_output"`
+	Description     string  `json:"description"`
+	CompareMode     string  `json:"compare_mode,omitempty"`
+	PointsAvailable float64 `json:"points_available,omitempty"`
+	Group           string  `json:"group,omitempty"`
+	TimeoutSec      int     `json:"timeout_seconds,omitempty"`
+}
+
+type ExecutionTestGroup struct {
+	Name     string   `json:"name"`
+	Requires []string `json:"requires,omitempty"`
 }
 
 type ExecutionResponse struct {
@@ -63,6 +166,16 @@ type ValidationSummary struct {
 	TotalPoints     float64 `json:"total_points"`
 	ScoredPoints    float64 `json:"scored_points"`
 	PercentageScore float64 `json:"percentage_score"`
+	Groups          []struct {
+		Name          string   `json:"name"`
+		TotalTests    int      `json:"total_tests"`
+		PassedTests   int      `json:"passed_tests"`
+		Passed        bool     `json:"passed"`
+		TotalPoints   float64  `json:"total_points"`
+		ScoredPoints  float64  `json:"scored_points"`
+		Gated         bool     `json:"gated"`
+		UnmetRequires []string `json:"unmet_requires"`
+	} `json:"groups,omitempty"`
 }
 
 type TestResult struct {
@@ -75,6 +188,10 @@ type TestResult struct {
 	SimilarityScore float64 `json:"similarity_score,omitempty"`
 	PointsAvailable float64 `json:"points_available,omitempty"`
 	PointsScored    float64 `json:"points_scored,omitempty"`
+	Group           string  `json:"group,omitempty"`
+	CheckerMessage  string  `json:"checker_message,omitempty"`
+	FailureReason   string  `json:"failure_reason,omitempty"`
+	ExecutionTime   float64 `json:"execution_time"`
 }
 
 func NewCodeExecutionService() *CodeExecutionService {
@@ -83,35 +200,157 @@ func NewCodeExecutionService() *CodeExecutionService {
 		baseURL = "http://localhost:8080" // Default URL for code execution engine
 	}
 
+	// Limit for the ActualOutput stored on each test result (0 disables truncation)
+	outputDisplayLimit := defaultOutputDisplayLimit
+	if value := os.Getenv("OUTPUT_DISPLAY_LIMIT"); value != "" {
+		if limit, err := strconv.Atoi(value); err == nil && limit >= 0 {
+			outputDisplayLimit = limit
+		}
+	}
+
 	return &CodeExecutionService{
 		baseURL: baseURL,
 		client: &http.Client{
 			Timeout: 30 * time.Second,
 		},
+		outputDisplayLimit: outputDisplayLimit,
+		retry:              LoadRetryConfig(),
+	}
+}
+
+// truncateOutput shortens output to the configured display limit.
+// It returns the (possibly) truncated output and whether it was cut.
+func (s *CodeExecutionService) truncateOutput(output string) (string, bool) {
+	if s.outputDisplayLimit <= 0 || len(output) <= s.outputDisplayLimit {
+		return output, false
+	}
+	return output[:s.outputDisplayLimit] + truncatedMarker, true
+}
+
+// GetSupportedLanguages asks the code execution engine which languages it can run
+func (s *CodeExecutionService) GetSupportedLanguages() ([]string, error) {
+	resp, err := s.client.Get(fmt.Sprintf("%s/languages", s.baseURL))
+	if err != nil {
+		return nil, fmt.Errorf("error fetching supported languages: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, newExecutorError(resp, "")
+	}
+
+	var languagesResponse struct {
+		Languages []string `json:"languages"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&languagesResponse); err != nil {
+		return nil, fmt.Errorf("error parsing languages response: %w", err)
+	}
+
+	return languagesResponse.Languages, nil
+}
+
+// languagesCacheTTL is how long the executor's language list is reused
+const languagesCacheTTL = 5 * time.Minute
+
+// languagesFailureTTL is how long a failed fetch is reported before the
+// executor is asked again, so an outage doesn't turn every request into a call
+const languagesFailureTTL = 10 * time.Second
+
+// DefaultLanguages is what the executor ships with, reported when it can't be reached
+var DefaultLanguages = []string{"javascript", "python", "cpp", "java"}
+
+// languagesCache holds the outcome of the last language fetch from the executor.
+// fetching is closed when the fetch in flight finishes, and nil when there is none.
+var languagesCache struct {
+	mu        sync.Mutex
+	languages []string
+	err       error
+	expiresAt time.Time
+	fetching  chan struct{}
+}
+
+// CachedSupportedLanguages is GetSupportedLanguages, asking the executor at most
+// once every few minutes. Concurrent callers share one fetch, and the lock isn't
+// held while it runs. Failures are remembered briefly.
+func (s *CodeExecutionService) CachedSupportedLanguages() ([]string, error) {
+	languagesCache.mu.Lock()
+	for {
+		if time.Now().Before(languagesCache.expiresAt) {
+			languages, err := languagesCache.languages, languagesCache.err
+			languagesCache.mu.Unlock()
+			return languages, err
+		}
+		if languagesCache.fetching == nil {
+			break
+		}
+		fetching := languagesCache.fetching
+		languagesCache.mu.Unlock()
+		<-fetching
+		languagesCache.mu.Lock()
+	}
+	fetching := make(chan struct{})
+	languagesCache.fetching = fetching
+	languagesCache.mu.Unlock()
+
+	languages, err := s.GetSupportedLanguages()
+
+	languagesCache.mu.Lock()
+	defer languagesCache.mu.Unlock()
+	ttl := languagesCacheTTL
+	if err != nil {
+		languages, ttl = nil, languagesFailureTTL
 	}
+	languagesCache.languages = languages
+	languagesCache.err = err
+	languagesCache.expiresAt = time.Now().Add(ttl)
+	languagesCache.fetching = nil
+	close(fetching)
+	return languages, err
 }
 
-func (s *CodeExecutionService) ExecuteCode(challenge *models.CodingChallenge, code string) (*models.ValidationResult, error) {
+// ExecuteCode runs code written in the given language against the challenge's
+// test cases. Cancelling ctx aborts the request to the executor.
+func (s *CodeExecutionService) ExecuteCode(ctx context.Context, challenge *models.CodingChallenge, language string, code string) (*models.ValidationResult, error) {
 	// Prepare the test cases
 	testCases := make([]ExecutionTestCase, 0, len(challenge.TestCases))
 	for _, tc := range challenge.TestCases {
 		testCases = append(testCases, ExecutionTestCase{
-			Input:          tc.Input,
-			ExpectedOutput: tc.ExpectedOutput,
-			Description:    tc.Description,
+			Input:           tc.Input,
+			ExpectedOutput:  tc.ExpectedOutput,
+			Description:     tc.Description,
+			CompareMode:     tc.CompareMode,
+			PointsAvailable: tc.EffectivePoints(),
+			Group:           tc.Group,
+			TimeoutSec:      tc.TimeoutSec,
 		})
 	}
+	testGroups := make([]ExecutionTestGroup, 0, len(challenge.TestGroups))
+	for _, group := range challenge.TestGroups {
+		testGroups = append(testGroups, ExecutionTestGroup{Name: group.Name, Requires: group.Requires})
+	}
+	setupFiles := make([]ExecutionSetupFile, 0, len(challenge.SetupFiles))
+	for _, file := range challenge.SetupFiles {
+		setupFiles = append(setupFiles, ExecutionSetupFile{Path: file.Path, Content: file.Content})
+	}
 
 	// Prepare the execution request
 	executionRequest := ExecutionRequest{
-		Language: challenge.Language,
+		Language: language,
 		Code:     code,
 		Input:    "",
 		Config: ExecutionConfig{
 			TimeoutSeconds: challenge.TimeoutSec,
 			MemoryLimitMB:  int64(challenge.MemoryLimitMB),
+			Env:            challenge.Env,
+			SetupFiles:     setupFiles,
+			SimilarityMode: challenge.SimilarityMode,
+			GracePercent:   challenge.GracePercent,
 		},
-		TestCases: testCases,
+		TestCases:  testCases,
+		TestGroups: testGroups,
+	}
+	if challenge.Checker != nil {
+		executionRequest.Checker = &ExecutionChecker{Language: challenge.Checker.Language, Code: challenge.Checker.Code}
 	}
 
 	// Convert request to JSON
@@ -120,47 +359,64 @@ func (s *CodeExecutionService) ExecuteCode(challenge *models.CodingChallenge, co
 		return nil, fmt.Errorf("error marshaling execution request: %w", err)
 	}
 
-	// Send request to code execution engine
-	resp, err := s.client.Post(
-		fmt.Sprintf("%s/execute", s.baseURL),
-		"application/json",
-		bytes.NewBuffer(jsonData),
-	)
+	// Send request to code execution engine, tagged so both sides' logs can be matched
+	requestID := newRequestID()
+	executionResponse, err := s.postExecution(ctx, jsonData, requestID)
 	if err != nil {
-		return nil, fmt.Errorf("error sending execution request: %w", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	// Check for non-200 status code
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("code execution engine returned status code %d", resp.StatusCode)
+	// Check if validation result is available and complete before indexing into it
+	if executionResponse.Validation == nil {
+		return nil, fmt.Errorf("%w: no validation result received", ErrIncompleteExecutorResponse)
 	}
-
-	// Parse the response
-	var executionResponse ExecutionResponse
-	if err := json.NewDecoder(resp.Body).Decode(&executionResponse); err != nil {
-		return nil, fmt.Errorf("error parsing execution response: %w", err)
+	if executionResponse.Validation.Summary == nil {
+		fmt.Printf("Execution %s (request %s) returned a validation result without a summary\n", executionResponse.ID, requestID)
+		return nil, fmt.Errorf("%w: validation summary missing", ErrIncompleteExecutorResponse)
 	}
-
-	// Check if validation result is available
-	if executionResponse.Validation == nil {
-		return nil, errors.New("no validation result received from code execution engine")
+	if got, want := len(executionResponse.Validation.TestCases), len(challenge.TestCases); got != want {
+		fmt.Printf("Execution %s (request %s) returned %d test results for %d test cases (status %q)\n",
+			executionResponse.ID, requestID, got, want, executionResponse.Status)
+		return nil, fmt.Errorf("%w: expected %d test results, got %d", ErrIncompleteExecutorResponse, want, got)
 	}
 
 	// Map to our validation result format
 	testResults := make([]models.TestResult, 0, len(executionResponse.Validation.TestCases))
 	for i, tr := range executionResponse.Validation.TestCases {
+		// Grading already happened on the full output in the executor, so only
+		// the stored/displayed copy is shortened here
+		actualOutput, truncated := s.truncateOutput(tr.ActualOutput)
 		testResults = append(testResults, models.TestResult{
 			Passed:          tr.Passed,
 			Input:           tr.Input,
 			ExpectedOutput:  tr.ExpectedOutput,
-			ActualOutput:    tr.ActualOutput,
+			ActualOutput:    actualOutput,
+			OutputTruncated: truncated,
+			OutputLength:    len(tr.ActualOutput),
 			Description:     tr.Description,
 			Hidden:          challenge.TestCases[i].Hidden,
 			Stderr:          tr.Stderr,
 			SimilarityScore: tr.SimilarityScore,
 			PointsAvailable: tr.PointsAvailable,
 			PointsScored:    tr.PointsScored,
+			Group:           tr.Group,
+			CheckerMessage:  tr.CheckerMessage,
+			FailureReason:   tr.FailureReason,
+			ExecutionTime:   tr.ExecutionTime,
+		})
+	}
+
+	var groupResults []models.GroupResult
+	for _, g := range executionResponse.Validation.Summary.Groups {
+		groupResults = append(groupResults, models.GroupResult{
+			Name:          g.Name,
+			TotalTests:    g.TotalTests,
+			PassedTests:   g.PassedTests,
+			Passed:        g.Passed,
+			TotalPoints:   g.TotalPoints,
+			ScoredPoints:  g.ScoredPoints,
+			Gated:         g.Gated,
+			UnmetRequires: g.UnmetRequires,
 		})
 	}
 
@@ -174,7 +430,70 @@ func (s *CodeExecutionService) ExecuteCode(challenge *models.CodingChallenge, co
 		TotalPoints:     executionResponse.Validation.Summary.TotalPoints,
 		ScoredPoints:    executionResponse.Validation.Summary.ScoredPoints,
 		PercentageScore: executionResponse.Validation.Summary.PercentageScore,
+		Groups:          groupResults,
 	}
 
 	return validationResult, nil
 }
+
+// postExecution sends an execution request, retrying failed connections and
+// 502/503/504 responses with exponential backoff until the retry deadline. Other
+// failures, including timeouts and 500s, may mean the code itself ran and failed,
+// so running it again would only repeat that. Every attempt carries the same request ID.
+func (s *CodeExecutionService) postExecution(ctx context.Context, jsonData []byte, requestID string) (*ExecutionResponse, error) {
+	ctx, cancel := context.WithTimeout(ctx, s.retry.Deadline)
+	defer cancel()
+
+	var lastErr error
+	for attempt := 1; ; attempt++ {
+		executionResponse, retryable, err := s.postExecutionOnce(ctx, jsonData, requestID)
+		if err == nil {
+			return executionResponse, nil
+		}
+		lastErr = err
+		if !retryable || attempt >= s.retry.MaxAttempts {
+			return nil, lastErr
+		}
+
+		delay := s.retry.backoff(attempt)
+		fmt.Printf("Code execution request %s failed (attempt %d of %d), retrying in %s: %v\n",
+			requestID, attempt, s.retry.MaxAttempts, delay, err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, fmt.Errorf("execution request %s gave up after %d attempts: %w", requestID, attempt, lastErr)
+		}
+	}
+}
+
+// postExecutionOnce makes a single execution request, reporting whether a
+// failure is worth retrying
+func (s *CodeExecutionService) postExecutionOnce(ctx context.Context, jsonData []byte, requestID string) (*ExecutionResponse, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, fmt.Sprintf("%s/execute", s.baseURL), bytes.NewReader(jsonData))
+	if err != nil {
+		return nil, false, fmt.Errorf("error creating execution request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(requestIDHeader, requestID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		// Only a request that never reached the executor is safe to send again, and
+		// once ctx is cancelled or the deadline has passed another attempt can't succeed
+		var opErr *net.OpError
+		retryable := errors.As(err, &opErr) && opErr.Op == "dial" && ctx.Err() == nil
+		return nil, retryable, fmt.Errorf("error sending execution request %s: %w", requestID, err)
+	}
+	defer resp.Body.Close()
+
+	// Check for non-200 status code
+	if resp.StatusCode != http.StatusOK {
+		execErr := newExecutorError(resp, requestID)
+		fmt.Printf("Code execution request %s failed with status %d: %s\n", execErr.RequestID, execErr.StatusCode, execErr.Body)
+		return nil, execErr.IsUnavailable(), execErr
+	}
+
+	var executionResponse ExecutionResponse
+	if err := json.NewDecoder(resp.Body).Decode(&executionResponse); err != nil {
+		return nil, false, fmt.Errorf("%w: error parsing execution response: %w", ErrIncompleteExecutorResponse, err)
+	}
+	return &executionResponse, false, nil
+}
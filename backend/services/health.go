@@ -2,6 +2,8 @@ package services
 
 import (
 	"context"
+	"fmt"
+	"net/http"
 	"time"
 
 	"go.mongodb.org/mongo-driver/mongo"
@@ -35,3 +37,29 @@ func CheckAPIHealth() (string, error) {
 	// dependencies or other services here.
 	return "running", nil
 }
+
+// executorHealthTimeout bounds the executor check so a hung executor can't stall /health
+const executorHealthTimeout = 2 * time.Second
+
+// CheckExecutorHealth checks the code execution engine answers, using its
+// language list as a lightweight ping
+func CheckExecutorHealth() (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), executorHealthTimeout)
+	defer cancel()
+
+	service := NewCodeExecutionService()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, service.baseURL+"/languages", nil)
+	if err != nil {
+		return "disconnected", err
+	}
+	resp, err := service.client.Do(req)
+	if err != nil {
+		return "disconnected", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "disconnected", fmt.Errorf("code execution engine returned status code %d", resp.StatusCode)
+	}
+	return "connected", nil
+}
@@ -0,0 +1,71 @@
+package services
+
+import (
+	"context"
+	"os"
+	"strconv"
+	"time"
+)
+
+// Defaults for retrying executor calls that failed for transient reasons
+const (
+	defaultExecutorRetryAttempts   = 3
+	defaultExecutorRetryBackoffMS  = 500
+	defaultExecutorDeadlineSeconds = 60
+)
+
+// maxExecutorRetryBackoff caps the delay between two attempts
+const maxExecutorRetryBackoff = 10 * time.Second
+
+// RetryConfig controls how an executor call is retried after a failed connection
+// or a 502/503/504 response. Deadline bounds all attempts together, backoff included.
+type RetryConfig struct {
+	MaxAttempts    int
+	InitialBackoff time.Duration
+	Deadline       time.Duration
+}
+
+// LoadRetryConfig reads EXECUTOR_RETRY_ATTEMPTS, EXECUTOR_RETRY_BACKOFF_MS and
+// EXECUTOR_DEADLINE_SECONDS. One attempt disables retrying.
+func LoadRetryConfig() RetryConfig {
+	config := RetryConfig{
+		MaxAttempts:    defaultExecutorRetryAttempts,
+		InitialBackoff: defaultExecutorRetryBackoffMS * time.Millisecond,
+		Deadline:       defaultExecutorDeadlineSeconds * time.Second,
+	}
+	if value, err := strconv.Atoi(os.Getenv("EXECUTOR_RETRY_ATTEMPTS")); err == nil && value > 0 {
+		config.MaxAttempts = value
+	}
+	if value, err := strconv.Atoi(os.Getenv("EXECUTOR_RETRY_BACKOFF_MS")); err == nil && value >= 0 {
+		config.InitialBackoff = time.Duration(value) * time.Millisecond
+	}
+	if value, err := strconv.Atoi(os.Getenv("EXECUTOR_DEADLINE_SECONDS")); err == nil && value > 0 {
+		config.Deadline = time.Duration(value) * time.Second
+	}
+	return config
+}
+
+// backoff is the delay before retry number retry (1 for the first retry),
+// doubling each time up to maxExecutorRetryBackoff
+func (r RetryConfig) backoff(retry int) time.Duration {
+	delay := r.InitialBackoff
+	for i := 1; i < retry && delay < maxExecutorRetryBackoff; i++ {
+		delay *= 2
+	}
+	if delay > maxExecutorRetryBackoff {
+		delay = maxExecutorRetryBackoff
+	}
+	return delay
+}
+
+// sleepContext waits for d, returning ctx.Err() if ctx is done first
+func sleepContext(ctx context.Context, d time.Duration) error {
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
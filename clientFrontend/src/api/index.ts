@@ -142,6 +142,18 @@ if (token) {
 // Health check
 export const checkHealth = () => api.get("/health");
 
+// Paginated listings return at most 100 items a page; read every page
+const getAllPages = async <T>(url: string): Promise<T[]> => {
+	const items: T[] = [];
+	for (let page = 1; ; page++) {
+		const response = await api.get(url, { params: { page, limit: 100 } });
+		items.push(...response.data.data);
+		if (page >= response.data.totalPages) {
+			return items;
+		}
+	}
+};
+
 // Test endpoints
 export const getTests = async (): Promise<Test[]> => {
 	const response = await api.get("/tests");
@@ -172,8 +184,7 @@ export const deleteTest = async (id: string): Promise<void> => {
 
 // Question endpoints
 export const getQuestions = async (): Promise<Question[]> => {
-	const response = await api.get("/questions");
-	return response.data;
+	return getAllPages<Question>("/questions");
 };
 
 export const getQuestion = async (id: string): Promise<Question> => {
@@ -230,8 +241,7 @@ export const deleteUser = async (id: string): Promise<void> => {
 
 // Challenge endpoints
 export const getChallenges = async (): Promise<Challenge[]> => {
-	const response = await api.get("/challenges");
-	return response.data;
+	return getAllPages<Challenge>("/challenges");
 };
 
 export const getChallenge = async (id: string): Promise<Challenge> => {
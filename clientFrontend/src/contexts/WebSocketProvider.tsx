@@ -27,6 +27,8 @@ export function WebSocketProvider({ children }: WebSocketProviderProps) {
 			console.log("WebSocket readyState:", ws.readyState);
 			setIsConnected(true);
 			setConnectionAttempts(0);
+			// Updates are only delivered for subscribed topics; the test list needs all of them
+			ws.send(JSON.stringify({ type: "subscribe", payload: { topic: "tests" } }));
 		};
 
 		ws.onclose = (event) => {
@@ -63,7 +65,7 @@ export function WebSocketProvider({ children }: WebSocketProviderProps) {
 				const data = JSON.parse(event.data);
 				console.log("Parsed WebSocket message:", data);
 
-				if (data.type === "test_update") {
+				if (data.type === "test_update" || data.type === "test_started") {
 					console.log("Test update received, invalidating queries");
 					// Invalidate and refetch tests query when a test is updated
 					queryClient.invalidateQueries("tests");
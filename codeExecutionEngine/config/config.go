@@ -1,22 +1,39 @@
 package config
 
 type Config struct {
-    Port            string
-    MaxConcurrency  int
-    DefaultTimeout  int
-    DefaultMemLimit int64
-    AllowedOrigins  []string
-    EnableCORS      bool
+    Port                    string
+    MaxConcurrency          int
+    MaxQueueWait            int
+    DefaultTimeout          int
+    DefaultMemLimit         int64
+    ResultCacheTTL          int
+    StrictEmptyOutput       bool
+    NormalizeLineEndings    bool
+    TrimTrailingWhitespace  bool
+    // Executions are kept in MongoDB when a URI is set, otherwise in memory
+    ExecutionStoreMongoURI  string
+    ExecutionStoreDatabase  string
+    ExecutionRetentionHours int
+    AllowedOrigins          []string
+    EnableCORS              bool
 }
 
 func GetDefaultConfig() *Config {
     env := LoadEnv()
     return &Config{
-        Port:            env.Port,
-        MaxConcurrency:  env.MaxConcurrency,
-        DefaultTimeout:  env.DefaultTimeout,
-        DefaultMemLimit: env.DefaultMemoryLimit,
-        AllowedOrigins:  env.AllowedOrigins,
-        EnableCORS:      env.EnableCORS,
+        Port:                    env.Port,
+        MaxConcurrency:          env.MaxConcurrency,
+        MaxQueueWait:            env.MaxQueueWait,
+        DefaultTimeout:          env.DefaultTimeout,
+        DefaultMemLimit:         env.DefaultMemoryLimit,
+        ResultCacheTTL:          env.ResultCacheTTL,
+        StrictEmptyOutput:       env.StrictEmptyOutput,
+        NormalizeLineEndings:    env.NormalizeLineEndings,
+        TrimTrailingWhitespace:  env.TrimTrailingWhitespace,
+        ExecutionStoreMongoURI:  env.ExecutionStoreMongoURI,
+        ExecutionStoreDatabase:  env.ExecutionStoreDatabase,
+        ExecutionRetentionHours: env.ExecutionRetentionHours,
+        AllowedOrigins:          env.AllowedOrigins,
+        EnableCORS:              env.EnableCORS,
     }
 }
\ No newline at end of file
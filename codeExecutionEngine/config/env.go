@@ -13,9 +13,19 @@ type EnvConfig struct {
     GinMode string
 
     // Execution
-    MaxConcurrency     int
-    DefaultTimeout     int
-    DefaultMemoryLimit int64
+    MaxConcurrency         int
+    MaxQueueWait           int
+    DefaultTimeout         int
+    DefaultMemoryLimit     int64
+    ResultCacheTTL         int
+    StrictEmptyOutput      bool
+    NormalizeLineEndings   bool
+    TrimTrailingWhitespace bool
+
+    // Execution store
+    ExecutionStoreMongoURI  string
+    ExecutionStoreDatabase  string
+    ExecutionRetentionHours int
 
     // Security
     AllowedOrigins []string
@@ -30,9 +40,19 @@ func LoadEnv() *EnvConfig {
         GinMode: getEnvString("GIN_MODE", "debug"),
 
         // Execution
-        MaxConcurrency:     getEnvInt("MAX_CONCURRENCY", 10),
-        DefaultTimeout:     getEnvInt("DEFAULT_TIMEOUT_SECONDS", 5),
-        DefaultMemoryLimit: getEnvInt64("DEFAULT_MEMORY_LIMIT_MB", 128),
+        MaxConcurrency:         getEnvInt("MAX_CONCURRENCY", 10),
+        MaxQueueWait:           getEnvInt("MAX_QUEUE_WAIT_SECONDS", 10),
+        DefaultTimeout:         getEnvInt("DEFAULT_TIMEOUT_SECONDS", 5),
+        DefaultMemoryLimit:     getEnvInt64("DEFAULT_MEMORY_LIMIT_MB", 128),
+        ResultCacheTTL:         getEnvInt("RESULT_CACHE_TTL_SECONDS", 300),
+        StrictEmptyOutput:      getEnvBool("STRICT_EMPTY_OUTPUT", false),
+        NormalizeLineEndings:   getEnvBool("NORMALIZE_LINE_ENDINGS", true),
+        TrimTrailingWhitespace: getEnvBool("TRIM_TRAILING_WHITESPACE", true),
+
+        // Execution store
+        ExecutionStoreMongoURI:  getEnvString("EXECUTION_STORE_MONGO_URI", ""),
+        ExecutionStoreDatabase:  getEnvString("EXECUTION_STORE_DATABASE", "code_executor"),
+        ExecutionRetentionHours: getEnvInt("EXECUTION_RETENTION_HOURS", 24),
 
         // Security
         AllowedOrigins: getEnvStringSlice("ALLOWED_ORIGINS", []string{"*"}),
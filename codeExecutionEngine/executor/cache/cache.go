@@ -0,0 +1,133 @@
+package cache
+
+import (
+	"code-executor/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// ResultCache stores the outcome of individual runs keyed by everything that
+// can influence them, so identical (code, input) pairs aren't executed twice
+type ResultCache struct {
+	entries   map[string]cacheEntry
+	ttl       time.Duration
+	lastSweep time.Time
+	hits      int64
+	misses    int64
+	mutex     sync.Mutex
+}
+
+type cacheEntry struct {
+	result    models.ExecutionResult
+	expiresAt time.Time
+}
+
+// Stats is a snapshot of the cache metrics
+type Stats struct {
+	Enabled    bool    `json:"enabled"`
+	TTLSeconds float64 `json:"ttl_seconds"`
+	Entries    int     `json:"entries"`
+	Hits       int64   `json:"hits"`
+	Misses     int64   `json:"misses"`
+	HitRate    float64 `json:"hit_rate"`
+}
+
+// NewResultCache creates a cache whose entries live for ttl. A ttl of zero disables caching.
+func NewResultCache(ttl time.Duration) *ResultCache {
+	return &ResultCache{
+		entries: make(map[string]cacheEntry),
+		ttl:     ttl,
+	}
+}
+
+// Key hashes the inputs of a single run
+func Key(language, code, input string, config models.ExecutionConfig) string {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\x00%s\x00%s\x00%d\x00%d", language, code, input, config.TimeoutSeconds, config.MemoryLimitMB)
+	// Environment and setup files change what the code sees, so they're part of the key.
+	// encoding/json sorts map keys, keeping the hash stable.
+	if len(config.Env) > 0 || len(config.SetupFiles) > 0 {
+		workspace, _ := json.Marshal(struct {
+			Env        map[string]string
+			SetupFiles []models.SetupFile
+		}{config.Env, config.SetupFiles})
+		h.Write([]byte{0})
+		h.Write(workspace)
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Get returns a copy of the cached result for key, if present and not expired
+func (c *ResultCache) Get(key string) (*models.ExecutionResult, bool) {
+	if c.ttl <= 0 {
+		return nil, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	entry, ok := c.entries[key]
+	if !ok || time.Now().After(entry.expiresAt) {
+		if ok {
+			delete(c.entries, key)
+		}
+		c.misses++
+		return nil, false
+	}
+
+	c.hits++
+	result := entry.result
+	return &result, true
+}
+
+// Set stores a copy of result under key
+func (c *ResultCache) Set(key string, result *models.ExecutionResult) {
+	if c.ttl <= 0 || result == nil {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.evictExpired()
+	c.entries[key] = cacheEntry{
+		result:    *result,
+		expiresAt: time.Now().Add(c.ttl),
+	}
+}
+
+// Stats returns the current cache metrics
+func (c *ResultCache) Stats() Stats {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	stats := Stats{
+		Enabled:    c.ttl > 0,
+		TTLSeconds: c.ttl.Seconds(),
+		Entries:    len(c.entries),
+		Hits:       c.hits,
+		Misses:     c.misses,
+	}
+	if total := c.hits + c.misses; total > 0 {
+		stats.HitRate = float64(c.hits) / float64(total)
+	}
+	return stats
+}
+
+// evictExpired drops expired entries at most once per TTL. Callers must hold the mutex.
+func (c *ResultCache) evictExpired() {
+	now := time.Now()
+	if now.Sub(c.lastSweep) < c.ttl {
+		return
+	}
+	c.lastSweep = now
+	for key, entry := range c.entries {
+		if now.After(entry.expiresAt) {
+			delete(c.entries, key)
+		}
+	}
+}
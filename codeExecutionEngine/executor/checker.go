@@ -0,0 +1,99 @@
+package executor
+
+import (
+	"code-executor/executor/languages"
+	"code-executor/models"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// checkerTimeoutSeconds applies when the execution itself has no time limit
+const checkerTimeoutSeconds = 10
+
+// ValidateChecker rejects a checker that could never run
+func ValidateChecker(checker *models.Checker) error {
+	if checker == nil {
+		return nil
+	}
+	if strings.TrimSpace(checker.Code) == "" {
+		return fmt.Errorf("checker code is required")
+	}
+	if !languages.IsSupported(checker.Language) {
+		return fmt.Errorf("unsupported checker language %q", checker.Language)
+	}
+	return nil
+}
+
+// runChecker asks the execution's checker to decide each test case. Runs that
+// crashed or timed out fail without consulting it. The checker gets its own
+// directory, so it can't see or touch the submission's files; the directory is
+// shared by every test case so a compiled checker is only built once.
+func (e *Executor) runChecker(execution *models.CodeExecution, results []*models.ExecutionResult) []*models.CheckerVerdict {
+	timeout := execution.Config.TimeoutSeconds
+	if timeout <= 0 {
+		timeout = checkerTimeoutSeconds
+	}
+
+	verdicts := make([]*models.CheckerVerdict, len(results))
+	checkerDir, err := os.MkdirTemp("", "code-checker-*")
+	if err != nil {
+		for i := range verdicts {
+			verdicts[i] = &models.CheckerVerdict{Message: fmt.Sprintf("Checker error: %v", err)}
+		}
+		return verdicts
+	}
+	defer os.RemoveAll(checkerDir)
+
+	// A checker that can't be built fails every remaining test case the same way
+	var unusable *models.CheckerVerdict
+	for i, result := range results {
+		if result == nil || result.TimedOut || result.ExitCode != 0 {
+			verdicts[i] = &models.CheckerVerdict{Passed: false, Message: "Program did not run successfully"}
+			continue
+		}
+		if unusable != nil {
+			verdicts[i] = unusable
+			continue
+		}
+		var usable bool
+		verdicts[i], usable = e.checkTestCase(execution.Checker, execution.TestCases[i], result.Stdout, timeout, checkerDir)
+		if !usable {
+			unusable = verdicts[i]
+		}
+	}
+	return verdicts
+}
+
+// checkTestCase runs the checker in checkerDir against one test case. The
+// returned bool is false when the checker itself can't run at all.
+func (e *Executor) checkTestCase(checker *models.Checker, tc models.TestCase, actual string, timeout int, checkerDir string) (*models.CheckerVerdict, bool) {
+	result := e.runCode(&models.CodeExecution{
+		Language: checker.Language,
+		Code:     checker.Code,
+		Config: models.ExecutionConfig{
+			TimeoutSeconds: timeout,
+			SetupFiles: []models.SetupFile{
+				{Path: "input.txt", Content: tc.Input},
+				{Path: "expected.txt", Content: tc.ExpectedOutput},
+				{Path: "actual.txt", Content: actual},
+			},
+		},
+	}, checkerDir)
+
+	// A checker that can't run fails the test case rather than passing it
+	switch {
+	case result == nil:
+		return &models.CheckerVerdict{Message: "Checker error: unsupported language"}, false
+	case result.CompileError:
+		return &models.CheckerVerdict{Message: "Checker error: " + result.Stderr}, false
+	case result.TimedOut:
+		return &models.CheckerVerdict{Message: "Checker error: timed out"}, true
+	}
+
+	message := strings.TrimSpace(result.Stdout)
+	if message == "" {
+		message = strings.TrimSpace(result.Stderr)
+	}
+	return &models.CheckerVerdict{Passed: result.ExitCode == 0, Message: message}, true
+}
@@ -1,6 +1,8 @@
 package executor
 
 import (
+	"code-executor/config"
+	"code-executor/executor/cache"
 	"code-executor/executor/languages"
 	"code-executor/executor/runners"
 	"code-executor/executor/store"
@@ -12,24 +14,38 @@ import (
 )
 
 type Executor struct {
-	store        *store.ExecutionStore
+	store        store.Store
 	pythonRunner *runners.PythonRunner
 	jsRunner     *runners.JavaScriptRunner
+	cppRunner    *runners.CppRunner
+	javaRunner   *runners.JavaRunner
 	validator    *validator.CodeValidator
+	resultCache  *cache.ResultCache
 }
 
-func NewExecutor() *Executor {
+// NewExecutor returns an executor that keeps executions in executionStore. A nil
+// store keeps them in memory.
+func NewExecutor(cfg *config.Config, executionStore store.Store) *Executor {
+	if executionStore == nil {
+		executionStore = store.NewExecutionStore()
+	}
 	return &Executor{
-		store:        store.NewExecutionStore(),
+		store:        executionStore,
 		pythonRunner: runners.NewPythonRunner(),
 		jsRunner:     runners.NewJavaScriptRunner(),
-		validator:    validator.NewCodeValidator(),
+		cppRunner:    runners.NewCppRunner(),
+		javaRunner:   runners.NewJavaRunner(),
+		validator: validator.NewCodeValidator(cfg.StrictEmptyOutput, validator.OutputNormalization{
+			LineEndings:        cfg.NormalizeLineEndings,
+			TrailingWhitespace: cfg.TrimTrailingWhitespace,
+		}),
+		resultCache: cache.NewResultCache(time.Duration(cfg.ResultCacheTTL) * time.Second),
 	}
 }
 
 func (e *Executor) Execute(execution *models.CodeExecution) {
-	e.store.Save(execution)
 	execution.Status = models.StatusRunning
+	e.store.Save(execution)
 
 	tmpDir, err := os.MkdirTemp("", "code-execution-*")
 	if err != nil {
@@ -38,21 +54,54 @@ func (e *Executor) Execute(execution *models.CodeExecution) {
 	}
 	defer os.RemoveAll(tmpDir)
 
-	result := &models.ExecutionResult{}
-	startTime := time.Now()
+	// Without test cases the run with the main input is the whole execution. With
+	// them it only happens on request: graders rarely set a main input, and running
+	// code that reads stdin against an empty one just wastes a run or fails.
+	var result *models.ExecutionResult
+	if len(execution.TestCases) == 0 || execution.RunMainInput {
+		if result = e.runMainInput(execution, tmpDir); result == nil {
+			return
+		}
+	}
 
-	// Execute with main input first
-	switch execution.Language {
-	case "javascript":
-		result = e.jsRunner.Execute(execution, tmpDir)
-	case "python":
-		result = e.pythonRunner.Execute(execution, tmpDir)
-	default:
-		e.handleExecutionError(execution, fmt.Errorf("unsupported language"))
-		return
+	// If test cases are provided, validate them
+	if len(execution.TestCases) > 0 {
+		// Run code for each test case and collect outputs
+		testResults := make([]*models.ExecutionResult, len(execution.TestCases))
+		for i, tc := range execution.TestCases {
+			// A heavier test case may allow itself more time than the rest
+			config := execution.Config
+			if tc.TimeoutSec > 0 {
+				config.TimeoutSeconds = tc.TimeoutSec
+			}
+			testResults[i] = e.runTestCase(&models.CodeExecution{
+				Code:     execution.Code,
+				Input:    tc.Input,
+				Language: execution.Language,
+				Config:   config,
+			}, tmpDir)
+		}
+		var verdicts []*models.CheckerVerdict
+		if execution.Checker != nil {
+			verdicts = e.runChecker(execution, testResults)
+		}
+		execution.Validation = e.validator.Validate(testResults, execution.TestCases, execution.TestGroups, verdicts, execution.Config)
 	}
 
-	result.ExecutionTime = time.Since(startTime).Seconds()
+	execution.Status = models.StatusCompleted
+	execution.Result = result
+	e.store.Save(execution)
+}
+
+// runMainInput executes the code with the execution's top-level input and
+// applies the time and memory limits to the result. It returns nil after
+// recording an error for an unsupported language.
+func (e *Executor) runMainInput(execution *models.CodeExecution, tmpDir string) *models.ExecutionResult {
+	result := e.runCode(execution, tmpDir)
+	if result == nil {
+		e.handleExecutionError(execution, fmt.Errorf("unsupported language"))
+		return nil
+	}
 
 	// Check if execution exceeded time limit
 	if execution.Config.TimeoutSeconds > 0 && result.ExecutionTime > float64(execution.Config.TimeoutSeconds) {
@@ -67,37 +116,45 @@ func (e *Executor) Execute(execution *models.CodeExecution) {
 			execution.Config.MemoryLimitMB, float64(result.MemoryUsage)/(1024*1024))
 		result.ExitCode = 1
 	}
+	return result
+}
 
-	// If test cases are provided, validate them
-	if len(execution.TestCases) > 0 {
-		// Run code for each test case and collect outputs
-		testResults := make([]*models.ExecutionResult, len(execution.TestCases))
-		for i, tc := range execution.TestCases {
-			var tcResult *models.ExecutionResult
-			switch execution.Language {
-			case "javascript":
-				tcResult = e.jsRunner.Execute(&models.CodeExecution{
-					Code:     execution.Code,
-					Input:    tc.Input,
-					Language: execution.Language,
-					Config:   execution.Config,
-				}, tmpDir)
-			case "python":
-				tcResult = e.pythonRunner.Execute(&models.CodeExecution{
-					Code:     execution.Code,
-					Input:    tc.Input,
-					Language: execution.Language,
-					Config:   execution.Config,
-				}, tmpDir)
-			}
-			testResults[i] = tcResult
-		}
-		execution.Validation = e.validator.Validate(testResults, execution.TestCases)
+// runTestCase executes a single test case run, reusing a cached result when the
+// same code has already been run with the same input and limits
+func (e *Executor) runTestCase(run *models.CodeExecution, tmpDir string) *models.ExecutionResult {
+	key := cache.Key(run.Language, run.Code, run.Input, run.Config)
+	if cached, ok := e.resultCache.Get(key); ok {
+		return cached
 	}
 
-	execution.Status = models.StatusCompleted
-	execution.Result = result
-	e.store.Save(execution)
+	result := e.runCode(run, tmpDir)
+
+	// Timeouts depend on machine load, so they aren't worth remembering
+	if result != nil && !result.TimedOut {
+		e.resultCache.Set(key, result)
+	}
+	return result
+}
+
+// runCode executes run with the runner for its language, returning nil when
+// the language isn't supported
+func (e *Executor) runCode(run *models.CodeExecution, tmpDir string) *models.ExecutionResult {
+	switch run.Language {
+	case "javascript":
+		return e.jsRunner.Execute(run, tmpDir)
+	case "python":
+		return e.pythonRunner.Execute(run, tmpDir)
+	case "cpp", "c++":
+		return e.cppRunner.Execute(run, tmpDir)
+	case "java":
+		return e.javaRunner.Execute(run, tmpDir)
+	}
+	return nil
+}
+
+// CacheStats returns metrics for the per-test-case result cache
+func (e *Executor) CacheStats() cache.Stats {
+	return e.resultCache.Stats()
 }
 
 func (e *Executor) GetExecution(id string) *models.CodeExecution {
@@ -113,6 +170,20 @@ func (e *Executor) handleExecutionError(execution *models.CodeExecution, err err
 	e.store.Save(execution)
 }
 
+// ValidateConfig rejects environment variables and setup files that aren't
+// allowed or could escape the run's working directory, and unknown scoring settings
+func ValidateConfig(config models.ExecutionConfig) error {
+	switch config.SimilarityMode {
+	case "", models.SimilarityModeFuzzy, models.SimilarityModeExact:
+	default:
+		return fmt.Errorf("similarity_mode must be %q or %q", models.SimilarityModeFuzzy, models.SimilarityModeExact)
+	}
+	if config.GracePercent < 0 || config.GracePercent > 100 {
+		return fmt.Errorf("grace_percent must be between 0 and 100")
+	}
+	return runners.ValidateWorkspace(config)
+}
+
 func IsSupportedLanguage(language string) bool {
 	return languages.IsSupported(language)
 }
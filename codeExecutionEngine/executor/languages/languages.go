@@ -1,12 +1,18 @@
 package languages
 
-var supportedLanguages = []string{"javascript", "python"}
+var supportedLanguages = []string{"javascript", "python", "cpp", "java"}
+
+// aliases are accepted in requests but not listed as separate languages
+var aliases = map[string]bool{"c++": true}
 
 func GetSupported() []string {
     return supportedLanguages
 }
 
 func IsSupported(language string) bool {
+    if aliases[language] {
+        return true
+    }
     for _, l := range supportedLanguages {
         if l == language {
             return true
@@ -0,0 +1,127 @@
+package runners
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// cgroupRoot is the cgroup v2 directory each run's memory cgroup is created under
+const cgroupRoot = "/sys/fs/cgroup/code-executor"
+
+var (
+	cgroupSetup     sync.Once
+	cgroupAvailable bool
+)
+
+// cgroupsAvailable prepares cgroupRoot on first use and reports whether memory
+// limits can be enforced. Without cgroup v2, or permission to delegate its
+// memory controller, runs go without a memory limit.
+func cgroupsAvailable() bool {
+	cgroupSetup.Do(func() {
+		if runtime.GOOS != "linux" {
+			return
+		}
+		if _, err := os.Stat("/sys/fs/cgroup/cgroup.controllers"); err != nil {
+			log.Printf("cgroup v2 not mounted, memory limits are not enforced")
+			return
+		}
+		if err := os.MkdirAll(cgroupRoot, 0755); err != nil {
+			log.Printf("Cannot create %s, memory limits are not enforced: %v", cgroupRoot, err)
+			return
+		}
+		// The memory controller has to be enabled on the way down to the runs' cgroups.
+		// The parent may already have it, so only the result is checked.
+		writeCgroupFile(filepath.Dir(cgroupRoot), "cgroup.subtree_control", "+memory")
+		if err := writeCgroupFile(cgroupRoot, "cgroup.subtree_control", "+memory"); err != nil {
+			log.Printf("Cannot enable the memory controller in %s, memory limits are not enforced: %v", cgroupRoot, err)
+			return
+		}
+		cgroupAvailable = true
+	})
+	return cgroupAvailable
+}
+
+// memoryCgroup is the transient cgroup capping one run's memory
+type memoryCgroup struct {
+	path string
+}
+
+func newMemoryCgroup(limitMB int64) (*memoryCgroup, error) {
+	path, err := os.MkdirTemp(cgroupRoot, "run-")
+	if err != nil {
+		return nil, err
+	}
+	group := &memoryCgroup{path: path}
+
+	if err := writeCgroupFile(path, "memory.max", strconv.FormatInt(limitMB*1024*1024, 10)); err != nil {
+		group.remove()
+		return nil, err
+	}
+	// Swapping would let a run exceed its limit, just more slowly. Hosts without
+	// swap accounting don't have the file.
+	writeCgroupFile(path, "memory.swap.max", "0")
+	// On OOM kill every process of the run, not just the largest
+	writeCgroupFile(path, "memory.oom.group", "1")
+	return group, nil
+}
+
+// add moves a started process into the cgroup; processes it spawns follow it
+func (g *memoryCgroup) add(pid int) error {
+	return writeCgroupFile(g.path, "cgroup.procs", strconv.Itoa(pid))
+}
+
+// peak returns the most memory the run used, in bytes
+func (g *memoryCgroup) peak() (int64, error) {
+	data, err := os.ReadFile(filepath.Join(g.path, "memory.peak"))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(data)), 10, 64)
+}
+
+// oomKilled reports whether the kernel killed the run for exceeding memory.max
+func (g *memoryCgroup) oomKilled() bool {
+	file, err := os.Open(filepath.Join(g.path, "memory.events"))
+	if err != nil {
+		return false
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && fields[0] == "oom_kill" {
+			count, _ := strconv.Atoi(fields[1])
+			return count > 0
+		}
+	}
+	return false
+}
+
+// remove kills anything the run left behind and deletes the cgroup
+func (g *memoryCgroup) remove() {
+	writeCgroupFile(g.path, "cgroup.kill", "1")
+	// Killed processes leave the cgroup asynchronously
+	for attempt := 0; attempt < 10; attempt++ {
+		if err := os.Remove(g.path); err == nil || os.IsNotExist(err) {
+			return
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	log.Printf("Failed to remove cgroup %s", g.path)
+}
+
+func writeCgroupFile(dir, name, value string) error {
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(value), 0644); err != nil {
+		return fmt.Errorf("writing %s: %w", name, err)
+	}
+	return nil
+}
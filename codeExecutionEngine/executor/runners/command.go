@@ -6,34 +6,51 @@ import (
 	"io"
 	"os/exec"
 	"runtime"
+	"strings"
 	"syscall"
 	"time"
 )
 
 // Platform-specific resource management
+// A manager is used for a single run: SetupProcess before the command starts,
+// AttachProcess right after, and Release once it has exited.
 type ResourceManager interface {
 	SetupProcess(cmd *exec.Cmd, config models.ExecutionConfig) error
+	AttachProcess(cmd *exec.Cmd) error
 	KillProcess(cmd *exec.Cmd) error
 	GetMemoryUsage(cmd *exec.Cmd) (int64, error)
+	MemoryLimitExceeded() bool
+	Release()
 }
 
 // Unix-like systems (Linux, macOS)
-type UnixResourceManager struct{}
+type UnixResourceManager struct {
+	// Caps the run's memory; nil when there is no limit or cgroups aren't available
+	cgroup *memoryCgroup
+}
 
 func (m *UnixResourceManager) SetupProcess(cmd *exec.Cmd, config models.ExecutionConfig) error {
 	cmd.SysProcAttr = &syscall.SysProcAttr{
 		Setpgid: true, // Allow killing child processes
 	}
 
-	if runtime.GOOS == "linux" && config.MemoryLimitMB > 0 {
-		// TODO: Implement proper memory limits using cgroups
-		// For now, we'll just set basic process attributes
-		// Note: Setting resource limits directly is not supported in Go's syscall package
-		// We would need to use cgroups or other system-specific tools
+	if runtime.GOOS == "linux" && config.MemoryLimitMB > 0 && cgroupsAvailable() {
+		group, err := newMemoryCgroup(config.MemoryLimitMB)
+		if err != nil {
+			return fmt.Errorf("creating memory cgroup: %v", err)
+		}
+		m.cgroup = group
 	}
 	return nil
 }
 
+func (m *UnixResourceManager) AttachProcess(cmd *exec.Cmd) error {
+	if m.cgroup == nil {
+		return nil
+	}
+	return m.cgroup.add(cmd.Process.Pid)
+}
+
 func (m *UnixResourceManager) KillProcess(cmd *exec.Cmd) error {
 	if cmd.Process != nil {
 		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
@@ -45,9 +62,21 @@ func (m *UnixResourceManager) GetMemoryUsage(cmd *exec.Cmd) (int64, error) {
 	if cmd.ProcessState == nil {
 		return 0, fmt.Errorf("process not completed")
 	}
-	// TODO: Implement proper memory usage tracking
-	// For now, return 0 as we need platform-specific implementation
-	return 0, nil
+	if m.cgroup == nil {
+		return 0, nil
+	}
+	return m.cgroup.peak()
+}
+
+func (m *UnixResourceManager) MemoryLimitExceeded() bool {
+	return m.cgroup != nil && m.cgroup.oomKilled()
+}
+
+func (m *UnixResourceManager) Release() {
+	if m.cgroup != nil {
+		m.cgroup.remove()
+		m.cgroup = nil
+	}
 }
 
 // Windows resource manager
@@ -59,6 +88,10 @@ func (m *WindowsResourceManager) SetupProcess(cmd *exec.Cmd, config models.Execu
 	return nil
 }
 
+func (m *WindowsResourceManager) AttachProcess(cmd *exec.Cmd) error {
+	return nil
+}
+
 func (m *WindowsResourceManager) KillProcess(cmd *exec.Cmd) error {
 	if cmd.Process != nil {
 		return cmd.Process.Kill()
@@ -71,6 +104,12 @@ func (m *WindowsResourceManager) GetMemoryUsage(cmd *exec.Cmd) (int64, error) {
 	return 0, nil
 }
 
+func (m *WindowsResourceManager) MemoryLimitExceeded() bool {
+	return false
+}
+
+func (m *WindowsResourceManager) Release() {}
+
 // Get the appropriate resource manager for the current platform
 func getResourceManager() ResourceManager {
 	switch runtime.GOOS {
@@ -92,6 +131,7 @@ func RunCommand(cmd *exec.Cmd, input string, config models.ExecutionConfig) *mod
 			Stderr:   fmt.Sprintf("Error setting up process: %v", err),
 		}
 	}
+	defer resourceManager.Release()
 
 	stdin, err := cmd.StdinPipe()
 	if err != nil {
@@ -117,6 +157,8 @@ func RunCommand(cmd *exec.Cmd, input string, config models.ExecutionConfig) *mod
 		}
 	}
 
+	// ExecutionTime is the wall-clock time from start until the process exits
+	startTime := time.Now()
 	if err := cmd.Start(); err != nil {
 		return &models.ExecutionResult{
 			ExitCode: 1,
@@ -124,6 +166,16 @@ func RunCommand(cmd *exec.Cmd, input string, config models.ExecutionConfig) *mod
 		}
 	}
 
+	// A run that can't be held to its memory limit doesn't run at all
+	if err := resourceManager.AttachProcess(cmd); err != nil {
+		resourceManager.KillProcess(cmd)
+		cmd.Wait()
+		return &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   fmt.Sprintf("Error applying memory limit: %v", err),
+		}
+	}
+
 	// Create channels for stdout and stderr
 	stdoutDone := make(chan []byte, 1)
 	stderrDone := make(chan []byte, 1)
@@ -181,6 +233,7 @@ func RunCommand(cmd *exec.Cmd, input string, config models.ExecutionConfig) *mod
 	case waitErr = <-done:
 		// Process completed normally
 	case <-timeout:
+		executionTime := time.Since(startTime).Seconds()
 		// Process timed out
 		if err := resourceManager.KillProcess(cmd); err != nil {
 			return &models.ExecutionResult{
@@ -189,10 +242,13 @@ func RunCommand(cmd *exec.Cmd, input string, config models.ExecutionConfig) *mod
 			}
 		}
 		return &models.ExecutionResult{
-			ExitCode: 1,
-			Stderr:   fmt.Sprintf("Execution timed out after %d seconds", config.TimeoutSeconds),
+			ExitCode:      1,
+			Stderr:        fmt.Sprintf("Execution timed out after %d seconds", config.TimeoutSeconds),
+			TimedOut:      true,
+			ExecutionTime: executionTime,
 		}
 	}
+	executionTime := time.Since(startTime).Seconds()
 
 	// Wait for stdout and stderr to be read
 	stdoutBytes := <-stdoutDone
@@ -210,10 +266,22 @@ func RunCommand(cmd *exec.Cmd, input string, config models.ExecutionConfig) *mod
 	// Get memory usage
 	memoryUsage, _ := resourceManager.GetMemoryUsage(cmd)
 
-	return &models.ExecutionResult{
-		Stdout:      string(stdoutBytes),
-		Stderr:      string(stderrBytes),
-		ExitCode:    exitCode,
-		MemoryUsage: memoryUsage,
+	result := &models.ExecutionResult{
+		Stdout:        string(stdoutBytes),
+		Stderr:        string(stderrBytes),
+		ExitCode:      exitCode,
+		ExecutionTime: executionTime,
+		MemoryUsage:   memoryUsage,
+	}
+	if resourceManager.MemoryLimitExceeded() {
+		result.MemoryLimitExceeded = true
+		if result.ExitCode == 0 {
+			result.ExitCode = 1
+		}
+		if result.Stderr != "" && !strings.HasSuffix(result.Stderr, "\n") {
+			result.Stderr += "\n"
+		}
+		result.Stderr += fmt.Sprintf("Memory limit of %d MB exceeded", config.MemoryLimitMB)
 	}
+	return result
 }
@@ -0,0 +1,84 @@
+package runners
+
+import (
+	"bytes"
+	"code-executor/models"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// cppCompileTimeoutSeconds bounds g++ separately from the program's own time limit
+const cppCompileTimeoutSeconds = 30
+
+type CppRunner struct{}
+
+func NewCppRunner() *CppRunner {
+	return &CppRunner{}
+}
+
+func (r *CppRunner) Execute(execution *models.CodeExecution, tmpDir string) *models.ExecutionResult {
+	if err := PrepareWorkspace(tmpDir, execution.Config); err != nil {
+		return &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+
+	binaryPath, result := r.compile(execution.Code, tmpDir)
+	if result != nil {
+		return result
+	}
+
+	cmd := exec.Command(binaryPath)
+	applyWorkspace(cmd, tmpDir, execution.Config)
+
+	// RunCommand times only the program's run, so the compile doesn't count
+	result = RunCommand(cmd, execution.Input, execution.Config)
+
+	if result.ExitCode != 0 && result.Stderr != "" {
+		result.Stderr = fmt.Sprintf("C++ Error: %s", result.Stderr)
+	}
+	return result
+}
+
+// compile builds code into tmpDir/main. Every test case of an execution shares
+// tmpDir, so the binary is reused as long as the source hasn't changed. A
+// non-nil result reports a failed compile.
+func (r *CppRunner) compile(code string, tmpDir string) (string, *models.ExecutionResult) {
+	sourcePath := filepath.Join(tmpDir, "main.cpp")
+	binaryPath := filepath.Join(tmpDir, "main")
+
+	if existing, err := os.ReadFile(sourcePath); err == nil && bytes.Equal(existing, []byte(code)) {
+		if _, err := os.Stat(binaryPath); err == nil {
+			return binaryPath, nil
+		}
+	}
+
+	if err := os.WriteFile(sourcePath, []byte(code), 0600); err != nil {
+		return "", &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+	os.Remove(binaryPath)
+
+	// Relative paths keep the temp directory out of compiler messages
+	cmd := exec.Command("g++", "-O2", "-std=c++17", "-o", "main", "main.cpp")
+	cmd.Dir = tmpDir
+	compiled := RunCommand(cmd, "", models.ExecutionConfig{TimeoutSeconds: cppCompileTimeoutSeconds})
+	if compiled.ExitCode != 0 {
+		output := strings.TrimSpace(compiled.Stderr)
+		if output == "" {
+			output = strings.TrimSpace(compiled.Stdout)
+		}
+		return "", &models.ExecutionResult{
+			ExitCode:     compiled.ExitCode,
+			Stderr:       fmt.Sprintf("Compilation Error: %s", output),
+			CompileError: true,
+		}
+	}
+	return binaryPath, nil
+}
@@ -0,0 +1,108 @@
+package runners
+
+import (
+	"bytes"
+	"code-executor/models"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// javaCompileTimeoutSeconds bounds javac separately from the program's own time limit
+const javaCompileTimeoutSeconds = 30
+
+// javaClassDir holds the compiled classes, apart from the program's working files
+const javaClassDir = "java-classes"
+
+// javaPublicClass finds the top-level public class, which javac requires to live
+// in a file of the same name
+var javaPublicClass = regexp.MustCompile(`(?m)^\s*public\s+(?:(?:final|abstract|strictfp)\s+)*class\s+([A-Za-z_$][A-Za-z0-9_$]*)`)
+
+type JavaRunner struct{}
+
+func NewJavaRunner() *JavaRunner {
+	return &JavaRunner{}
+}
+
+func (r *JavaRunner) Execute(execution *models.CodeExecution, tmpDir string) *models.ExecutionResult {
+	if err := PrepareWorkspace(tmpDir, execution.Config); err != nil {
+		return &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+
+	className, result := r.compile(execution.Code, tmpDir)
+	if result != nil {
+		return result
+	}
+
+	cmd := exec.Command("java", "-cp", filepath.Join(tmpDir, javaClassDir), className)
+	applyWorkspace(cmd, tmpDir, execution.Config)
+
+	// RunCommand times only the program's run, so the compile doesn't count
+	result = RunCommand(cmd, execution.Input, execution.Config)
+	if result.ExitCode != 0 && result.Stderr != "" {
+		result.Stderr = fmt.Sprintf("Java Error: %s", result.Stderr)
+	}
+	return result
+}
+
+// javaMainClass is the class to run: the public class if the code declares one,
+// otherwise Main
+func javaMainClass(code string) string {
+	if match := javaPublicClass.FindStringSubmatch(code); match != nil {
+		return match[1]
+	}
+	return "Main"
+}
+
+// compile builds code into tmpDir/java-classes and returns the class to run.
+// Every test case of an execution shares tmpDir, so the classes are reused as
+// long as the source hasn't changed. A non-nil result reports a failed compile.
+func (r *JavaRunner) compile(code string, tmpDir string) (string, *models.ExecutionResult) {
+	className := javaMainClass(code)
+	sourceName := className + ".java"
+	sourcePath := filepath.Join(tmpDir, sourceName)
+	classDir := filepath.Join(tmpDir, javaClassDir)
+
+	if existing, err := os.ReadFile(sourcePath); err == nil && bytes.Equal(existing, []byte(code)) {
+		if _, err := os.Stat(filepath.Join(classDir, className+".class")); err == nil {
+			return className, nil
+		}
+	}
+
+	// Clear out classes from any earlier compile so none of them outlive their source
+	if err := os.RemoveAll(classDir); err != nil {
+		return "", &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+	if err := os.WriteFile(sourcePath, []byte(code), 0600); err != nil {
+		return "", &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+
+	// Relative paths keep the temp directory out of compiler messages
+	cmd := exec.Command("javac", "-encoding", "UTF-8", "-d", javaClassDir, sourceName)
+	cmd.Dir = tmpDir
+	compiled := RunCommand(cmd, "", models.ExecutionConfig{TimeoutSeconds: javaCompileTimeoutSeconds})
+	if compiled.ExitCode != 0 {
+		output := strings.TrimSpace(compiled.Stderr)
+		if output == "" {
+			output = strings.TrimSpace(compiled.Stdout)
+		}
+		return "", &models.ExecutionResult{
+			ExitCode:     compiled.ExitCode,
+			Stderr:       fmt.Sprintf("Compilation Error: %s", output),
+			CompileError: true,
+		}
+	}
+	return className, nil
+}
@@ -6,7 +6,6 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
-	"strings"
 )
 
 type JavaScriptRunner struct{}
@@ -37,6 +36,13 @@ if (logs.length > 0) {
 }
 `, execution.Code)
 
+	if err := PrepareWorkspace(tmpDir, execution.Config); err != nil {
+		return &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+
 	scriptPath := filepath.Join(tmpDir, "script.js")
 	if err := os.WriteFile(scriptPath, []byte(wrapperCode), 0600); err != nil {
 		return &models.ExecutionResult{
@@ -46,10 +52,7 @@ if (logs.length > 0) {
 	}
 
 	cmd := exec.Command("node", scriptPath)
-	result := RunCommand(cmd, execution.Input, execution.Config)
-
-	// Clean up any trailing newlines or whitespace from output for consistent comparison
-	result.Stdout = strings.TrimSpace(result.Stdout)
-
-	return result
+	applyWorkspace(cmd, tmpDir, execution.Config)
+	// Output is normalized by the validator, the same way for every language
+	return RunCommand(cmd, execution.Input, execution.Config)
 }
@@ -19,6 +19,13 @@ func (r *PythonRunner) Execute(execution *models.CodeExecution, tmpDir string) *
 	fmt.Printf("Executing Python code: \n%s\n", execution.Code)
 	fmt.Printf("Input: '%s'\n", execution.Input)
 
+	if err := PrepareWorkspace(tmpDir, execution.Config); err != nil {
+		return &models.ExecutionResult{
+			ExitCode: 1,
+			Stderr:   err.Error(),
+		}
+	}
+
 	// Write the user's code directly to a file
 	scriptPath := filepath.Join(tmpDir, "script.py")
 	if err := os.WriteFile(scriptPath, []byte(execution.Code), 0600); err != nil {
@@ -38,6 +45,7 @@ func (r *PythonRunner) Execute(execution *models.CodeExecution, tmpDir string) *
 
 	// Execute the Python script with unbuffered output (-u flag)
 	cmd := exec.Command(pythonCmd, "-u", scriptPath)
+	applyWorkspace(cmd, tmpDir, execution.Config)
 
 	// Pass any input to the script and the execution config
 	result := RunCommand(cmd, execution.Input, execution.Config)
@@ -0,0 +1,177 @@
+package runners
+
+import (
+	"code-executor/models"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// Limits on what a request may add to a run's environment and working directory
+const (
+	maxEnvVars         = 32
+	maxEnvValueBytes   = 4 * 1024
+	maxSetupFiles      = 16
+	maxSetupFilesBytes = 1024 * 1024
+)
+
+var envKeyPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// Variables that change how the interpreter or loader starts can't be overridden
+var (
+	blockedEnvKeys     = map[string]bool{"PATH": true, "HOME": true, "SHELL": true, "IFS": true, "CLASSPATH": true}
+	blockedEnvPrefixes = []string{"LD_", "DYLD_", "PYTHON", "NODE_", "NPM_", "JAVA_", "JDK_", "_JAVA_"}
+)
+
+// File names the runners write themselves; setup files may not replace them
+var reservedFileNames = map[string]bool{"script.py": true, "script.js": true, "main.cpp": true, "main": true, "Main.java": true, javaClassDir: true}
+
+// ValidateWorkspace checks a config's environment variables and setup files
+// before anything is executed
+func ValidateWorkspace(config models.ExecutionConfig) error {
+	if len(config.Env) > maxEnvVars {
+		return fmt.Errorf("too many environment variables (max %d)", maxEnvVars)
+	}
+	for key, value := range config.Env {
+		if !envKeyPattern.MatchString(key) {
+			return fmt.Errorf("invalid environment variable name %q", key)
+		}
+		if isBlockedEnvKey(key) {
+			return fmt.Errorf("environment variable %q cannot be set", key)
+		}
+		if len(value) > maxEnvValueBytes {
+			return fmt.Errorf("environment variable %q is too long (max %d bytes)", key, maxEnvValueBytes)
+		}
+		if strings.ContainsRune(value, 0) {
+			return fmt.Errorf("environment variable %q contains a NUL byte", key)
+		}
+	}
+
+	if len(config.SetupFiles) > maxSetupFiles {
+		return fmt.Errorf("too many setup files (max %d)", maxSetupFiles)
+	}
+	total := 0
+	seen := make(map[string]bool, len(config.SetupFiles))
+	for _, file := range config.SetupFiles {
+		clean, err := cleanSetupPath(file.Path)
+		if err != nil {
+			return err
+		}
+		if seen[clean] {
+			return fmt.Errorf("duplicate setup file %q", file.Path)
+		}
+		seen[clean] = true
+		total += len(file.Content)
+	}
+	if total > maxSetupFilesBytes {
+		return fmt.Errorf("setup files are too large (max %d bytes in total)", maxSetupFilesBytes)
+	}
+	return nil
+}
+
+func isBlockedEnvKey(key string) bool {
+	upper := strings.ToUpper(key)
+	if blockedEnvKeys[upper] {
+		return true
+	}
+	for _, prefix := range blockedEnvPrefixes {
+		if strings.HasPrefix(upper, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// cleanSetupPath returns path in canonical slash form, rejecting anything that
+// could land outside the working directory
+func cleanSetupPath(path string) (string, error) {
+	if path == "" {
+		return "", fmt.Errorf("setup file path is required")
+	}
+	if strings.ContainsRune(path, 0) || strings.Contains(path, `\`) {
+		return "", fmt.Errorf("invalid setup file path %q", path)
+	}
+	if filepath.IsAbs(path) || strings.HasPrefix(path, "/") || filepath.VolumeName(path) != "" {
+		return "", fmt.Errorf("setup file path %q must be relative", path)
+	}
+	clean := filepath.ToSlash(filepath.Clean(path))
+	if clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", fmt.Errorf("setup file path %q escapes the working directory", path)
+	}
+	if reservedFileNames[clean] || strings.HasPrefix(clean, javaClassDir+"/") {
+		return "", fmt.Errorf("setup file path %q is reserved", path)
+	}
+	return clean, nil
+}
+
+// PrepareWorkspace writes the config's setup files into tmpDir. It runs before
+// every execution so a run can't leave modified files behind for the next one.
+// Test cases share tmpDir, so an earlier run may have replaced a setup file or
+// one of its directories with a symlink; those are refused rather than followed.
+func PrepareWorkspace(tmpDir string, config models.ExecutionConfig) error {
+	for _, file := range config.SetupFiles {
+		clean, err := cleanSetupPath(file.Path)
+		if err != nil {
+			return err
+		}
+		if err := writeSetupFile(tmpDir, clean, []byte(file.Content)); err != nil {
+			return fmt.Errorf("writing setup file %q: %w", file.Path, err)
+		}
+	}
+	return nil
+}
+
+// writeSetupFile writes content to the cleaned path under tmpDir, creating its
+// directories one component at a time and never following a symlink
+func writeSetupFile(tmpDir, clean string, content []byte) error {
+	parts := strings.Split(clean, "/")
+	dir := tmpDir
+	for _, part := range parts[:len(parts)-1] {
+		dir = filepath.Join(dir, part)
+		info, err := os.Lstat(dir)
+		switch {
+		case os.IsNotExist(err):
+			if err := os.Mkdir(dir, 0700); err != nil {
+				return err
+			}
+		case err != nil:
+			return err
+		case !info.IsDir():
+			// Lstat reports a symlink to a directory as a symlink, not a directory
+			return fmt.Errorf("%s is not a directory", part)
+		}
+	}
+
+	// O_NOFOLLOW fails on a symlink in place of the file itself
+	f, err := os.OpenFile(filepath.Join(dir, parts[len(parts)-1]), os.O_WRONLY|os.O_CREATE|os.O_TRUNC|syscall.O_NOFOLLOW, 0600)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(content); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// applyWorkspace runs cmd inside tmpDir with the config's extra environment variables
+func applyWorkspace(cmd *exec.Cmd, tmpDir string, config models.ExecutionConfig) {
+	cmd.Dir = tmpDir
+	if len(config.Env) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(config.Env))
+	for key := range config.Env {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	cmd.Env = os.Environ()
+	for _, key := range keys {
+		cmd.Env = append(cmd.Env, key+"="+config.Env[key])
+	}
+}
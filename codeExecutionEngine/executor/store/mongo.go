@@ -0,0 +1,95 @@
+package store
+
+import (
+	"code-executor/models"
+	"context"
+	"log"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// mongoTimeout bounds every store operation so a slow database can't hold up an execution
+const mongoTimeout = 5 * time.Second
+
+// executionDocument is how an execution is stored: keyed by its ID, with the
+// time of its last status transition for expiry
+type executionDocument struct {
+	ID        string                `bson:"_id"`
+	Execution *models.CodeExecution `bson:"execution"`
+	UpdatedAt time.Time             `bson:"updatedAt"`
+}
+
+// MongoStore keeps executions in MongoDB, so they can still be polled after the
+// executor restarts
+type MongoStore struct {
+	client     *mongo.Client
+	collection *mongo.Collection
+}
+
+// NewMongoStore connects to uri and stores executions in database's executions
+// collection. With a positive retention, executions expire that long after their
+// last status transition.
+func NewMongoStore(uri, database string, retention time.Duration) (*MongoStore, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	client, err := mongo.Connect(ctx, options.Client().ApplyURI(uri))
+	if err != nil {
+		return nil, err
+	}
+	if err := client.Ping(ctx, nil); err != nil {
+		client.Disconnect(context.Background())
+		return nil, err
+	}
+
+	collection := client.Database(database).Collection("executions")
+	if retention > 0 {
+		_, err = collection.Indexes().CreateOne(ctx, mongo.IndexModel{
+			Keys:    bson.D{{Key: "updatedAt", Value: 1}},
+			Options: options.Index().SetExpireAfterSeconds(int32(retention.Seconds())),
+		})
+		if err != nil {
+			log.Printf("Failed to create expiry index on executions: %v", err)
+		}
+	}
+	return &MongoStore{client: client, collection: collection}, nil
+}
+
+// Save writes the execution as it is now, replacing what was stored for its ID.
+// A failed write is logged; the execution itself carries on.
+func (s *MongoStore) Save(execution *models.CodeExecution) {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	doc := executionDocument{ID: execution.ID, Execution: execution, UpdatedAt: time.Now().UTC()}
+	_, err := s.collection.ReplaceOne(ctx, bson.M{"_id": execution.ID}, doc, options.Replace().SetUpsert(true))
+	if err != nil {
+		log.Printf("Failed to save execution %s: %v", execution.ID, err)
+	}
+}
+
+// Get returns the stored execution, or nil if there is none or it can't be read
+func (s *MongoStore) Get(id string) *models.CodeExecution {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+
+	var doc executionDocument
+	err := s.collection.FindOne(ctx, bson.M{"_id": id}).Decode(&doc)
+	if err != nil {
+		if err != mongo.ErrNoDocuments {
+			log.Printf("Failed to fetch execution %s: %v", id, err)
+		}
+		return nil
+	}
+	return doc.Execution
+}
+
+// Close disconnects from MongoDB
+func (s *MongoStore) Close() error {
+	ctx, cancel := context.WithTimeout(context.Background(), mongoTimeout)
+	defer cancel()
+	return s.client.Disconnect(ctx)
+}
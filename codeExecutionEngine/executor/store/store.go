@@ -5,6 +5,15 @@ import (
     "sync"
 )
 
+// Store keeps executions by ID so their status and result can be polled.
+// Save is called on every status transition with the whole execution.
+type Store interface {
+    Save(execution *models.CodeExecution)
+    Get(id string) *models.CodeExecution
+}
+
+// ExecutionStore keeps executions in memory. It is the default, and everything
+// in it is lost when the executor restarts.
 type ExecutionStore struct {
     executions map[string]*models.CodeExecution
     mutex      sync.RWMutex
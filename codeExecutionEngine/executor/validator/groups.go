@@ -0,0 +1,80 @@
+package validator
+
+import (
+	"code-executor/models"
+	"fmt"
+	"math"
+)
+
+// applyGroupRules totals test results per group and zeroes the points of any
+// group whose required groups did not pass every test case. A group passes
+// based on its own test cases only, so gating never cascades and cycles are
+// harmless. Requirements naming a group with no test cases are vacuously met.
+// Returns nil when no test case belongs to a group.
+func applyGroupRules(validationResult *models.ValidationResult, groups []models.TestGroup) []models.GroupResult {
+	grouped := false
+	for _, tc := range validationResult.TestCases {
+		if tc.Group != "" {
+			grouped = true
+			break
+		}
+	}
+	if !grouped {
+		return nil
+	}
+
+	// Tally each group in order of first appearance
+	var order []string
+	tallies := make(map[string]*models.GroupResult)
+	for _, tc := range validationResult.TestCases {
+		tally, ok := tallies[tc.Group]
+		if !ok {
+			tally = &models.GroupResult{Name: tc.Group, Passed: true}
+			tallies[tc.Group] = tally
+			order = append(order, tc.Group)
+		}
+		tally.TotalTests++
+		tally.TotalPoints += tc.PointsAvailable
+		tally.ScoredPoints += tc.PointsScored
+		if tc.Passed {
+			tally.PassedTests++
+		} else {
+			tally.Passed = false
+		}
+	}
+
+	// Decide gating before zeroing anything so the outcome doesn't depend on rule order
+	for _, group := range groups {
+		tally, ok := tallies[group.Name]
+		if !ok {
+			continue
+		}
+		for _, required := range group.Requires {
+			if prerequisite, ok := tallies[required]; ok && !prerequisite.Passed {
+				tally.Gated = true
+				tally.UnmetRequires = append(tally.UnmetRequires, required)
+			}
+		}
+	}
+
+	for i := range validationResult.TestCases {
+		tc := &validationResult.TestCases[i]
+		if tallies[tc.Group].Gated {
+			validationResult.Summary.ScoredPoints -= tc.PointsScored
+			tc.PointsScored = 0
+		}
+	}
+
+	results := make([]models.GroupResult, 0, len(order))
+	for _, name := range order {
+		tally := tallies[name]
+		if tally.Gated {
+			fmt.Printf("  Group %q gated, unmet prerequisites: %v\n", name, tally.UnmetRequires)
+			tally.ScoredPoints = 0
+		}
+		tally.ScoredPoints = math.Round(tally.ScoredPoints*100) / 100
+		results = append(results, *tally)
+	}
+	validationResult.Summary.ScoredPoints = math.Round(validationResult.Summary.ScoredPoints*100) / 100
+	return results
+}
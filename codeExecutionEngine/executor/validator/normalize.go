@@ -0,0 +1,117 @@
+package validator
+
+import (
+	"math/big"
+	"strconv"
+	"strings"
+)
+
+// OutputNormalization selects how output is normalized before comparison, on
+// top of trimming surrounding whitespace
+type OutputNormalization struct {
+	// LineEndings rewrites CRLF line endings to LF
+	LineEndings bool
+	// TrailingWhitespace trims spaces and tabs from the end of every line
+	TrailingWhitespace bool
+}
+
+// apply returns output normalized as n selects
+func (n OutputNormalization) apply(output string) string {
+	if n.LineEndings {
+		output = strings.ReplaceAll(output, "\r\n", "\n")
+	}
+	if n.TrailingWhitespace {
+		lines := strings.Split(output, "\n")
+		for i, line := range lines {
+			lines[i] = strings.TrimRight(line, " \t")
+		}
+		output = strings.Join(lines, "\n")
+	}
+	return output
+}
+
+// canonicalizeNumbers rewrites every numeric token in output to a canonical form
+// (no leading zeros, no trailing fractional zeros, no exponent) and collapses runs
+// of spaces within a line. Non-numeric tokens and line breaks are left as-is.
+func canonicalizeNumbers(output string) string {
+	lines := strings.Split(output, "\n")
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		for j, field := range fields {
+			fields[j] = canonicalNumber(field)
+		}
+		lines[i] = strings.Join(fields, " ")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// canonicalNumber returns the canonical form of token if it is a decimal number,
+// otherwise token unchanged. Arbitrary precision is used so large integers and
+// long decimals are never rounded.
+func canonicalNumber(token string) string {
+	if !looksNumeric(token) {
+		return token
+	}
+
+	rat, ok := new(big.Rat).SetString(token)
+	if !ok {
+		return token
+	}
+	if rat.IsInt() {
+		return rat.Num().String()
+	}
+
+	// Decimal expansion of the exact value, trimmed of trailing zeros
+	formatted := rat.FloatString(decimalPlaces(rat.Denom()))
+	formatted = strings.TrimRight(formatted, "0")
+	return strings.TrimSuffix(formatted, ".")
+}
+
+// decimalPlaces returns how many fractional digits are needed to write 1/denom
+// exactly. denom always comes from a decimal literal, so it only has factors 2 and 5.
+func decimalPlaces(denom *big.Int) int {
+	d := new(big.Int).Set(denom)
+	two, five := big.NewInt(2), big.NewInt(5)
+	rem := new(big.Int)
+	twos, fives := 0, 0
+	for {
+		if q, _ := new(big.Int).QuoRem(d, two, rem); rem.Sign() == 0 {
+			d, twos = q, twos+1
+			continue
+		}
+		if q, _ := new(big.Int).QuoRem(d, five, rem); rem.Sign() == 0 {
+			d, fives = q, fives+1
+			continue
+		}
+		break
+	}
+	return max(twos, fives)
+}
+
+// maxExponentDigits bounds exponents so output like "1e999999999" can't make the
+// arbitrary-precision expansion allocate unbounded memory
+const maxExponentDigits = 3
+
+// looksNumeric accepts optionally signed decimal literals with an optional
+// exponent, rejecting forms big.Rat would otherwise take (fractions like "1/2",
+// hex, inf, nan)
+func looksNumeric(token string) bool {
+	body := strings.TrimLeft(token, "+-")
+	if len(token)-len(body) > 1 || body == "" {
+		return false
+	}
+	if _, err := strconv.ParseFloat(body, 64); err != nil {
+		return false
+	}
+	if idx := strings.IndexAny(body, "eE"); idx >= 0 {
+		if len(strings.TrimLeft(body[idx+1:], "+-")) > maxExponentDigits {
+			return false
+		}
+	}
+	for _, r := range body {
+		if !strings.ContainsRune("0123456789.eE+-", r) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,30 @@
+package validator
+
+import "testing"
+
+func TestOutputNormalizationApply(t *testing.T) {
+	both := OutputNormalization{LineEndings: true, TrailingWhitespace: true}
+
+	tests := []struct {
+		name          string
+		normalization OutputNormalization
+		output        string
+		want          string
+	}{
+		{"crlf becomes lf", OutputNormalization{LineEndings: true}, "1\r\n2\r\n", "1\n2\n"},
+		{"lone cr is kept", OutputNormalization{LineEndings: true}, "1\r2", "1\r2"},
+		{"trailing spaces and tabs are trimmed per line", OutputNormalization{TrailingWhitespace: true}, "1 \t\n2  \n3", "1\n2\n3"},
+		{"leading spaces are kept", OutputNormalization{TrailingWhitespace: true}, "  1\n\t2", "  1\n\t2"},
+		{"crlf with trailing spaces", both, "1  \r\n2 \r\n", "1\n2\n"},
+		{"trailing whitespace alone leaves cr", OutputNormalization{TrailingWhitespace: true}, "1\r\n", "1\r\n"},
+		{"disabled leaves output as-is", OutputNormalization{}, "1 \r\n2 ", "1 \r\n2 "},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.normalization.apply(tt.output); got != tt.want {
+				t.Errorf("apply(%q) = %q, want %q", tt.output, got, tt.want)
+			}
+		})
+	}
+}
@@ -7,10 +7,40 @@ import (
 	"strings"
 )
 
-type CodeValidator struct{}
+type CodeValidator struct {
+	// strictEmptyOutput makes test cases that expect no output fail on any
+	// output at all, including stray whitespace
+	strictEmptyOutput bool
+	// normalization is applied to expected and actual output before comparing
+	normalization OutputNormalization
+}
+
+func NewCodeValidator(strictEmptyOutput bool, normalization OutputNormalization) *CodeValidator {
+	return &CodeValidator{strictEmptyOutput: strictEmptyOutput, normalization: normalization}
+}
 
-func NewCodeValidator() *CodeValidator {
-	return &CodeValidator{}
+// matchesEmptyExpected decides a test case whose expected output is empty or
+// whitespace-only. Similarity is meaningless here, so it is pass or fail.
+func (v *CodeValidator) matchesEmptyExpected(actualOutput string) bool {
+	if v.strictEmptyOutput {
+		return actualOutput == ""
+	}
+	return strings.TrimSpace(actualOutput) == ""
+}
+
+// failureReasonFor classifies why a failed test case's run failed
+func failureReasonFor(run *models.ExecutionResult) string {
+	switch {
+	case run.TimedOut:
+		return models.FailureTimeLimitExceeded
+	case run.MemoryLimitExceeded:
+		return models.FailureMemoryLimitExceeded
+	case run.CompileError:
+		return models.FailureCompilationError
+	case run.ExitCode != 0:
+		return models.FailureRuntimeError
+	}
+	return models.FailureWrongAnswer
 }
 
 // calculateSimilarity computes a similarity score between two strings
@@ -108,7 +138,28 @@ func max(a, b int) int {
 	return b
 }
 
-func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []models.TestCase) *models.ValidationResult {
+// graceThreshold returns the similarity (0-1) from which a failed test case still
+// earns full points, or a value above 1 when config's mode gives no grace at all
+func graceThreshold(config models.ExecutionConfig) float64 {
+	if config.SimilarityMode == models.SimilarityModeExact {
+		return math.Inf(1)
+	}
+	if config.GracePercent > 0 {
+		return config.GracePercent / 100
+	}
+	return models.DefaultGracePercent / 100
+}
+
+// Validate scores each test case run against its test case. When verdicts is
+// non-nil a checker has already decided every test case: its verdict replaces
+// output comparison and the test case scores all or nothing. config's
+// similarity mode decides what failed test cases score: in fuzzy mode (the
+// default) points follow similarity and reach full marks at the grace
+// percentage; in exact mode only byte-identical output passes or scores.
+func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []models.TestCase, groups []models.TestGroup, verdicts []*models.CheckerVerdict, config models.ExecutionConfig) *models.ValidationResult {
+	exact := config.SimilarityMode == models.SimilarityModeExact
+	grace := graceThreshold(config)
+
 	validationResult := &models.ValidationResult{
 		Passed:    true,
 		TestCases: make([]models.Result, 0),
@@ -155,15 +206,51 @@ func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []m
 		fmt.Println("  Character comparison:")
 		fmt.Printf("  Expected length: %d, Actual length: %d\n", len(expectedOutput), len(actualOutput))
 
-		// Trim spaces for comparison only (keep original values for display)
-		trimmedExpected := strings.TrimSpace(expectedOutput)
-		trimmedActual := strings.TrimSpace(actualOutput)
+		// Normalize and trim for comparison only (keep original values for display).
+		// Every runner's output goes through here, so languages compare alike.
+		trimmedExpected := strings.TrimSpace(v.normalization.apply(expectedOutput))
+		trimmedActual := strings.TrimSpace(v.normalization.apply(actualOutput))
+
+		// Number mode compares canonical numeric forms so "07" matches "7" and "1.0" matches "1"
+		if testCase.CompareMode == models.CompareModeNumber {
+			trimmedExpected = canonicalizeNumbers(trimmedExpected)
+			trimmedActual = canonicalizeNumbers(trimmedActual)
+			fmt.Printf("  Canonical expected: '%s'\n", trimmedExpected)
+			fmt.Printf("  Canonical actual: '%s'\n", trimmedActual)
+		}
 
 		// Check for exact match
 		passed := trimmedExpected == trimmedActual
 
 		// Calculate similarity score
-		similarityScore := calculateSimilarity(expectedOutput, actualOutput)
+		similarityScore := calculateSimilarity(trimmedExpected, trimmedActual)
+
+		// Programs expected to print nothing are judged on emptiness alone
+		if trimmedExpected == "" {
+			passed = v.matchesEmptyExpected(actualOutput)
+			similarityScore = 0
+			fmt.Printf("  Empty expected output (strict=%v), passed: %v\n", v.strictEmptyOutput, passed)
+		}
+
+		// Exact mode only passes on byte-equality, before any trimming or normalization
+		if exact {
+			passed = actualOutput == expectedOutput
+		}
+
+		// A checker's verdict is final; similarity means nothing to a special judge
+		checkerMessage := ""
+		if verdicts != nil && verdicts[i] != nil {
+			passed = verdicts[i].Passed
+			checkerMessage = verdicts[i].Message
+			similarityScore = 0
+			fmt.Printf("  Checker verdict: %v\n", passed)
+		}
+
+		// A run that hit its time limit fails outright, whatever it printed first
+		if result[i].TimedOut {
+			passed = false
+			similarityScore = 0
+		}
 		fmt.Printf("  Similarity score: %.2f\n", similarityScore)
 
 		// Set test case points (default to 1 if not specified)
@@ -175,12 +262,13 @@ func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []m
 		// Calculate points scored based on similarity
 		pointsScored := pointsAvailable * similarityScore
 
-		// Only award full points for perfect matches, unless similarity is very high
+		// Only award full points for perfect matches, unless similarity is within the grace margin
 		if passed {
 			pointsScored = pointsAvailable
 			similarityScore = 1.0
-		} else if similarityScore >= 0.9 {
-			// Award full points for 90%+ similarity as a grace margin
+		} else if exact {
+			pointsScored = 0
+		} else if similarityScore >= grace {
 			pointsScored = pointsAvailable
 		}
 
@@ -192,7 +280,7 @@ func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []m
 
 		// Print first mismatch for debugging
 		mismatchFound := false
-		if trimmedExpected != trimmedActual {
+		if verdicts == nil && trimmedExpected != trimmedActual {
 			minLen := len(trimmedExpected)
 			if len(trimmedActual) < minLen {
 				minLen = len(trimmedActual)
@@ -217,11 +305,13 @@ func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []m
 			}
 		}
 
+		failureReason := ""
 		if passed {
 			validationResult.Summary.PassedTests++
 		} else {
 			validationResult.Summary.FailedTests++
 			validationResult.Passed = false
+			failureReason = failureReasonFor(result[i])
 		}
 
 		validationResult.TestCases = append(validationResult.TestCases, models.Result{
@@ -234,9 +324,16 @@ func (v *CodeValidator) Validate(result []*models.ExecutionResult, testCases []m
 			SimilarityScore: similarityScore,
 			PointsAvailable: pointsAvailable,
 			PointsScored:    pointsScored,
+			Group:           testCase.Group,
+			CheckerMessage:  checkerMessage,
+			FailureReason:   failureReason,
+			ExecutionTime:   result[i].ExecutionTime,
 		})
 	}
 
+	// Withhold points of groups whose prerequisite groups didn't fully pass
+	validationResult.Summary.Groups = applyGroupRules(validationResult, groups)
+
 	// Calculate overall percentage score
 	if validationResult.Summary.TotalPoints > 0 {
 		percentage := (validationResult.Summary.ScoredPoints / validationResult.Summary.TotalPoints) * 100
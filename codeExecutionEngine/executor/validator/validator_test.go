@@ -0,0 +1,124 @@
+package validator
+
+import (
+	"code-executor/models"
+	"testing"
+)
+
+// validate runs a single test case through a validator with the default normalization
+func validate(t *testing.T, v *CodeValidator, expected, stdout string, config models.ExecutionConfig) models.Result {
+	t.Helper()
+	result := v.Validate(
+		[]*models.ExecutionResult{{Stdout: stdout}},
+		[]models.TestCase{{ExpectedOutput: expected}},
+		nil, nil, config,
+	)
+	if len(result.TestCases) != 1 {
+		t.Fatalf("got %d test case results, want 1", len(result.TestCases))
+	}
+	return result.TestCases[0]
+}
+
+func TestValidateNormalizesOutputAcrossLanguages(t *testing.T) {
+	v := NewCodeValidator(false, OutputNormalization{LineEndings: true, TrailingWhitespace: true})
+
+	tests := []struct {
+		name     string
+		expected string
+		stdout   string
+	}{
+		{"python print adds a trailing newline", "1\n2", "1\n2\n"},
+		{"javascript output without a trailing newline", "1\n2\n", "1\n2"},
+		{"crlf output against lf expected", "1\n2\n", "1\r\n2\r\n"},
+		{"lf output against crlf expected", "1\r\n2\r\n", "1\n2"},
+		{"trailing spaces on each line", "1 2\n3 4", "1 2 \n3 4  \n"},
+		{"crlf and trailing spaces together", "a\nb", "a \r\nb\t\r\n"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := validate(t, v, tt.expected, tt.stdout, models.ExecutionConfig{}); !got.Passed {
+				t.Errorf("output %q did not pass against %q", tt.stdout, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidatePythonAndJavaScriptOutputBothPass(t *testing.T) {
+	v := NewCodeValidator(false, OutputNormalization{LineEndings: true, TrailingWhitespace: true})
+	testCases := []models.TestCase{{ExpectedOutput: "3\r\n7\r\n"}}
+
+	// Python's print ends with a newline; a JavaScript solution writing a joined
+	// string may not, and expected output authored on Windows has CRLF endings
+	for _, stdout := range []string{"3\n7\n", "3\r\n7"} {
+		result := v.Validate([]*models.ExecutionResult{{Stdout: stdout}}, testCases, nil, nil, models.ExecutionConfig{})
+		if !result.Passed || result.Summary.PercentageScore != 100 {
+			t.Errorf("output %q: passed=%v, score=%v, want a full pass", stdout, result.Passed, result.Summary.PercentageScore)
+		}
+	}
+}
+
+func TestValidateWithoutNormalization(t *testing.T) {
+	v := NewCodeValidator(false, OutputNormalization{})
+
+	if got := validate(t, v, "1\n2", "1\r\n2", models.ExecutionConfig{}); got.Passed {
+		t.Error("crlf output passed with line ending normalization off")
+	}
+	if got := validate(t, v, "1\n2", "1 \n2", models.ExecutionConfig{}); got.Passed {
+		t.Error("trailing spaces passed with trailing whitespace trimming off")
+	}
+	// Surrounding whitespace is always trimmed
+	if got := validate(t, v, "1\n2", "1\n2\n", models.ExecutionConfig{}); !got.Passed {
+		t.Error("a trailing newline failed with normalization off")
+	}
+}
+
+func TestValidateExactModeRequiresIdenticalOutput(t *testing.T) {
+	v := NewCodeValidator(false, OutputNormalization{LineEndings: true, TrailingWhitespace: true})
+	exact := models.ExecutionConfig{SimilarityMode: models.SimilarityModeExact}
+
+	if got := validate(t, v, "1\n2\n", "1\n2\n", exact); !got.Passed || got.PointsScored != 1 {
+		t.Errorf("identical output: passed=%v, points=%v, want a full pass", got.Passed, got.PointsScored)
+	}
+	for _, stdout := range []string{"1\n2", "1\r\n2\r\n", "1 \n2\n"} {
+		if got := validate(t, v, "1\n2\n", stdout, exact); got.Passed || got.PointsScored != 0 {
+			t.Errorf("output %q: passed=%v, points=%v, want a fail scoring nothing", stdout, got.Passed, got.PointsScored)
+		}
+	}
+}
+
+func TestValidateEmptyExpectedOutput(t *testing.T) {
+	tests := []struct {
+		name     string
+		strict   bool
+		expected string
+		stdout   string
+		passed   bool
+	}{
+		{"no output passes", false, "", "", true},
+		{"whitespace-only output passes when lenient", false, "", " \n\t\n", true},
+		{"whitespace-only expected output is empty too", false, "\n", "", true},
+		{"any printed text fails", false, "", "0\n", false},
+		{"no output passes when strict", true, "", "", true},
+		{"whitespace-only output fails when strict", true, "", "\n", false},
+		{"whitespace-only expected output fails on whitespace when strict", true, "  \n", "\n", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := NewCodeValidator(tt.strict, OutputNormalization{LineEndings: true, TrailingWhitespace: true})
+			got := validate(t, v, tt.expected, tt.stdout, models.ExecutionConfig{})
+			if got.Passed != tt.passed {
+				t.Errorf("passed = %v, want %v", got.Passed, tt.passed)
+			}
+			// Pass or fail outright: no partial credit from similarity
+			wantPoints := 0.0
+			if tt.passed {
+				wantPoints = 1
+			}
+			if got.PointsScored != wantPoints {
+				t.Errorf("points = %v, want %v", got.PointsScored, wantPoints)
+			}
+		})
+	}
+}
@@ -14,14 +14,16 @@ type ExecuteHandler struct {
     executor        *executor.Executor
     statusService   *services.StatusService
     executionService *services.ExecutionService
+    queue           *services.ExecutionQueue
 }
 
-func NewExecuteHandler(executor *executor.Executor) *ExecuteHandler {
+func NewExecuteHandler(executor *executor.Executor, queue *services.ExecutionQueue) *ExecuteHandler {
     statusService := services.NewStatusService(executor)
     return &ExecuteHandler{
         executor:         executor,
         statusService:    statusService,
-        executionService: services.NewExecutionService(executor, statusService),
+        executionService: services.NewExecutionService(executor, statusService, queue),
+        queue:            queue,
     }
 }
 
@@ -38,8 +40,23 @@ func (h *ExecuteHandler) ExecuteCode(c *gin.Context) {
         return
     }
 
+    if err := executor.ValidateConfig(request.Config); err != nil {
+        response.FormatErrorResponse(c, http.StatusBadRequest, err)
+        return
+    }
+
+    if err := executor.ValidateChecker(request.Checker); err != nil {
+        response.FormatErrorResponse(c, http.StatusBadRequest, err)
+        return
+    }
+
     execution, err := h.executionService.ExecuteAndWaitForResult(&request)
     if err != nil {
+        if err == services.ErrServerBusy {
+            c.Header("Retry-After", "5")
+            response.FormatErrorResponse(c, http.StatusServiceUnavailable, err)
+            return
+        }
         response.FormatErrorResponse(c, http.StatusInternalServerError, err)
         return
     }
@@ -64,6 +81,14 @@ func (h *ExecuteHandler) GetExecutionStatus(c *gin.Context) {
     response.FormatExecutionResponse(c, execution)
 }
 
+func (h *ExecuteHandler) GetCacheStats(c *gin.Context) {
+    c.JSON(http.StatusOK, h.executor.CacheStats())
+}
+
+func (h *ExecuteHandler) GetQueueStats(c *gin.Context) {
+    c.JSON(http.StatusOK, h.queue.Stats())
+}
+
 func (h *ExecuteHandler) GetSupportedLanguages(c *gin.Context) {
     c.JSON(http.StatusOK, gin.H{
         "languages": executor.GetSupportedLanguages(),
@@ -3,10 +3,14 @@ package main
 import (
     "code-executor/config"
     "code-executor/executor"
+    "code-executor/executor/store"
     "code-executor/handlers"
+    "code-executor/services"
     "github.com/gin-gonic/gin"
     "github.com/gin-contrib/cors"
+    "log"
     "os"
+    "time"
 )
 
 func main() {
@@ -15,18 +19,39 @@ func main() {
     // Set Gin mode
     gin.SetMode(os.Getenv("GIN_MODE"))
     
-    exec := executor.NewExecutor()
-    handler := handlers.NewExecuteHandler(exec)
+    // Executions survive restarts only when they're stored in MongoDB
+    var executionStore store.Store
+    if cfg.ExecutionStoreMongoURI != "" {
+        mongoStore, err := store.NewMongoStore(cfg.ExecutionStoreMongoURI, cfg.ExecutionStoreDatabase,
+            time.Duration(cfg.ExecutionRetentionHours)*time.Hour)
+        if err != nil {
+            log.Fatalf("Failed to connect to the execution store: %v", err)
+        }
+        defer mongoStore.Close()
+        executionStore = mongoStore
+    }
+
+    exec := executor.NewExecutor(cfg, executionStore)
+    queue := services.NewExecutionQueue(cfg.MaxConcurrency, time.Duration(cfg.MaxQueueWait)*time.Second)
+    handler := handlers.NewExecuteHandler(exec, queue)
 
     r := gin.Default()
 
+    // Echo the caller's correlation ID so failures can be matched across services
+    r.Use(func(c *gin.Context) {
+        if requestID := c.GetHeader("X-Request-ID"); requestID != "" {
+            c.Header("X-Request-ID", requestID)
+        }
+        c.Next()
+    })
+
     // Configure CORS if enabled
     if cfg.EnableCORS {
         r.Use(cors.New(cors.Config{
             AllowOrigins:     cfg.AllowedOrigins,
             AllowMethods:     []string{"GET", "POST"},
-            AllowHeaders:     []string{"Content-Type"},
-            ExposeHeaders:    []string{"Content-Length"},
+            AllowHeaders:     []string{"Content-Type", "X-Request-ID"},
+            ExposeHeaders:    []string{"Content-Length", "X-Request-ID"},
             AllowCredentials: true,
             MaxAge:           12 * 60 * 60,
         }))
@@ -35,6 +60,8 @@ func main() {
     r.POST("/execute", handler.ExecuteCode)
     r.GET("/languages", handler.GetSupportedLanguages)
     r.GET("/status/:id", handler.GetExecutionStatus)
+    r.GET("/metrics/cache", handler.GetCacheStats)
+    r.GET("/metrics/queue", handler.GetQueueStats)
 
     r.Run(cfg.Port)
 }
\ No newline at end of file
@@ -9,6 +9,9 @@ type CodeExecution struct {
     Result        *ExecutionResult       `json:"result,omitempty"`
     Config        ExecutionConfig        `json:"config"`
     TestCases     []TestCase            `json:"test_cases,omitempty"`
+    TestGroups    []TestGroup           `json:"test_groups,omitempty"`
+    RunMainInput  bool                   `json:"run_main_input,omitempty"` // Also run with Input when test cases are given
+    Checker       *Checker               `json:"checker,omitempty"`        // Decides test cases instead of output comparison
     Validation    *ValidationResult      `json:"validation,omitempty"`
 }
 
@@ -27,9 +30,24 @@ type ExecutionResult struct {
     ExitCode      int     `json:"exit_code"`
     ExecutionTime float64 `json:"execution_time"`
     MemoryUsage   int64   `json:"memory_usage"`
+    TimedOut      bool    `json:"timed_out,omitempty"`
+    CompileError  bool    `json:"compile_error,omitempty"` // The code didn't compile, so it never ran
+    // The run was killed for exceeding config.memory_limit_mb
+    MemoryLimitExceeded bool `json:"memory_limit_exceeded,omitempty"`
 }
 
 type ExecutionConfig struct {
-    TimeoutSeconds int   `json:"timeout_seconds"`
-    MemoryLimitMB  int64 `json:"memory_limit_mb"`
+    TimeoutSeconds int               `json:"timeout_seconds"`
+    MemoryLimitMB  int64             `json:"memory_limit_mb"`
+    Env            map[string]string `json:"env,omitempty"`         // Extra environment variables for the run
+    SetupFiles     []SetupFile       `json:"setup_files,omitempty"` // Files written into the working directory before each run
+    SimilarityMode string            `json:"similarity_mode,omitempty"` // fuzzy (default) or exact, see validator.Validate
+    GracePercent   float64           `json:"grace_percent,omitempty"`   // Fuzzy mode: similarity (0-100) that still earns full points; 0 means 90
+}
+
+// SetupFile is a file made available to the running code, e.g. a data file it reads.
+// Path is relative to the run's working directory.
+type SetupFile struct {
+    Path    string `json:"path"`
+    Content string `json:"content"`
 }
\ No newline at end of file
@@ -6,4 +6,10 @@ type ExecuteRequest struct {
     Input      string          `json:"input"`
     Config     ExecutionConfig `json:"config"`
     TestCases  []TestCase      `json:"test_cases"`
+    TestGroups []TestGroup     `json:"test_groups,omitempty"`
+    // RunMainInput also runs the code once with Input when test cases are given;
+    // by default only the test cases run and "result" is null
+    RunMainInput bool `json:"run_main_input,omitempty"`
+    // Checker replaces output comparison with a special-judge program
+    Checker *Checker `json:"checker,omitempty"`
 }
\ No newline at end of file
@@ -1,10 +1,74 @@
 package models
 
+// Output comparison modes for a test case
+const (
+	CompareModeExact  = "exact"  // Trimmed string equality (default)
+	CompareModeNumber = "number" // Numeric tokens are canonicalized before comparing
+)
+
 type TestCase struct {
 	Input           string  `json:"input"`
 	ExpectedOutput  string  `json:"expected_output"`
 	Description     string  `json:"description"`
 	PointsAvailable float64 `json:"points_available,omitempty"` // Max points for this test case
+	CompareMode     string  `json:"compare_mode,omitempty"`     // exact (default) or number
+	Group           string  `json:"group,omitempty"`            // Test group name, e.g. "correctness"
+	TimeoutSec      int     `json:"timeout_seconds,omitempty"`  // Overrides config.timeout_seconds for this test case
+}
+
+// Similarity modes decide what a failed test case can still score. Fuzzy awards
+// points in proportion to how similar the output is, and full points from the
+// grace percentage up; exact awards nothing for output that doesn't match.
+const (
+	SimilarityModeFuzzy = "fuzzy"
+	SimilarityModeExact = "exact"
+)
+
+// DefaultGracePercent is the similarity fuzzy mode awards full points from
+// when the config doesn't set one
+const DefaultGracePercent = 90.0
+
+// Reasons a test case failed, so clients can tell a time limit from a wrong answer
+const (
+	FailureTimeLimitExceeded   = "time_limit_exceeded"
+	FailureMemoryLimitExceeded = "memory_limit_exceeded"
+	FailureCompilationError    = "compilation_error"
+	FailureRuntimeError        = "runtime_error"
+	FailureWrongAnswer         = "wrong_answer"
+)
+
+// Checker is a special-judge program that decides test cases instead of output
+// comparison. It runs sandboxed like submitted code, with input.txt,
+// expected.txt and actual.txt in its working directory, and exits 0 to accept
+// the output. Anything it prints is reported back as its message.
+type Checker struct {
+	Language string `json:"language"`
+	Code     string `json:"code"`
+}
+
+// CheckerVerdict is a checker's decision on one test case
+type CheckerVerdict struct {
+	Passed  bool
+	Message string
+}
+
+// TestGroup is a scoring rule for a named group of test cases: the group's points
+// only count once every test case in each Requires group has passed
+type TestGroup struct {
+	Name     string   `json:"name"`
+	Requires []string `json:"requires,omitempty"`
+}
+
+// GroupResult reports how one test group scored
+type GroupResult struct {
+	Name          string   `json:"name"`
+	TotalTests    int      `json:"total_tests"`
+	PassedTests   int      `json:"passed_tests"`
+	Passed        bool     `json:"passed"` // Every test case in the group passed
+	TotalPoints   float64  `json:"total_points"`
+	ScoredPoints  float64  `json:"scored_points"`
+	Gated         bool     `json:"gated,omitempty"` // Points withheld because a required group failed
+	UnmetRequires []string `json:"unmet_requires,omitempty"`
 }
 
 type ValidationResult struct {
@@ -20,6 +84,9 @@ type ValidationSummary struct {
 	TotalPoints     float64 `json:"total_points"`     // Total points available across all tests
 	ScoredPoints    float64 `json:"scored_points"`    // Points actually scored
 	PercentageScore float64 `json:"percentage_score"` // Overall percentage score (0-100)
+
+	// Per-group results, present when any test case belongs to a group
+	Groups []GroupResult `json:"groups,omitempty"`
 }
 
 type Result struct {
@@ -32,4 +99,8 @@ type Result struct {
 	SimilarityScore float64 `json:"similarity_score"` // How closely output matches expected (0-1)
 	PointsAvailable float64 `json:"points_available"` // Max points for this test case
 	PointsScored    float64 `json:"points_scored"`    // Points awarded based on similarity
+	Group           string  `json:"group,omitempty"`
+	CheckerMessage  string  `json:"checker_message,omitempty"` // What the checker printed, when one decided the test case
+	FailureReason   string  `json:"failure_reason,omitempty"`  // Set on failed test cases, see the Failure constants
+	ExecutionTime   float64 `json:"execution_time"`            // Wall-clock seconds this test case's run took
 }
@@ -4,4 +4,5 @@ import "errors"
 
 var (
     ErrExecutionNotFound = errors.New("execution not found")
+    ErrServerBusy        = errors.New("server busy, try again")
 )
\ No newline at end of file
@@ -10,12 +10,14 @@ import (
 type ExecutionService struct {
     executor *executor.Executor
     statusService *StatusService
+    queue *ExecutionQueue
 }
 
-func NewExecutionService(executor *executor.Executor, statusService *StatusService) *ExecutionService {
+func NewExecutionService(executor *executor.Executor, statusService *StatusService, queue *ExecutionQueue) *ExecutionService {
     return &ExecutionService{
         executor: executor,
         statusService: statusService,
+        queue: queue,
     }
 }
 
@@ -29,10 +31,21 @@ func (s *ExecutionService) ExecuteAndWaitForResult(request *models.ExecuteReques
         Status:    models.StatusPending,
         Config:    request.Config,
         TestCases: request.TestCases,
+        TestGroups: request.TestGroups,
+        RunMainInput: request.RunMainInput,
+        Checker:      request.Checker,
     }
 
-    // Start execution
-    go s.executor.Execute(execution)
+    // Wait for a free execution slot, giving up after the configured queue wait
+    if err := s.queue.Acquire(); err != nil {
+        return nil, err
+    }
+
+    // Start execution; the slot is held until the run finishes, even if we stop waiting
+    go func() {
+        defer s.queue.Release()
+        s.executor.Execute(execution)
+    }()
 
     // Wait for execution to complete with timeout
     timeout := time.After(10 * time.Second)
@@ -0,0 +1,74 @@
+package services
+
+import (
+    "sync/atomic"
+    "time"
+)
+
+// ExecutionQueue bounds how many executions run at once. Requests wait for a
+// free slot for at most maxWait before being turned away, so a burst of
+// submissions can't hold HTTP requests open indefinitely.
+type ExecutionQueue struct {
+    slots    chan struct{}
+    maxWait  time.Duration
+    waiting  int64
+    rejected int64
+}
+
+// QueueStats is a snapshot of the execution queue for metrics
+type QueueStats struct {
+    MaxConcurrency      int     `json:"max_concurrency"`
+    Running             int     `json:"running"`
+    Waiting             int64   `json:"waiting"`
+    Rejected            int64   `json:"rejected"`
+    MaxQueueWaitSeconds float64 `json:"max_queue_wait_seconds"`
+}
+
+func NewExecutionQueue(maxConcurrency int, maxWait time.Duration) *ExecutionQueue {
+    if maxConcurrency <= 0 {
+        maxConcurrency = 1
+    }
+    return &ExecutionQueue{
+        slots:   make(chan struct{}, maxConcurrency),
+        maxWait: maxWait,
+    }
+}
+
+// Acquire takes an execution slot, waiting up to maxWait for one to free up.
+// It returns ErrServerBusy if none does; otherwise Release must be called.
+func (q *ExecutionQueue) Acquire() error {
+    select {
+    case q.slots <- struct{}{}:
+        return nil
+    default:
+    }
+
+    atomic.AddInt64(&q.waiting, 1)
+    defer atomic.AddInt64(&q.waiting, -1)
+
+    timer := time.NewTimer(q.maxWait)
+    defer timer.Stop()
+
+    select {
+    case q.slots <- struct{}{}:
+        return nil
+    case <-timer.C:
+        atomic.AddInt64(&q.rejected, 1)
+        return ErrServerBusy
+    }
+}
+
+// Release frees a slot taken by Acquire
+func (q *ExecutionQueue) Release() {
+    <-q.slots
+}
+
+func (q *ExecutionQueue) Stats() QueueStats {
+    return QueueStats{
+        MaxConcurrency:      cap(q.slots),
+        Running:             len(q.slots),
+        Waiting:             atomic.LoadInt64(&q.waiting),
+        Rejected:            atomic.LoadInt64(&q.rejected),
+        MaxQueueWaitSeconds: q.maxWait.Seconds(),
+    }
+}